@@ -69,3 +69,19 @@ func ShowUnableToSaveConfigErrorMessage(err error) {
 	printline(" " + fancy.WithPizzazz(err.Error(), fancy.Red))
 	printline("Settings will be saved for this run, but will need to be reconfigured the next time you start.")
 }
+
+// ShowTransportConfigErrorMessage renders user-messaging when the proxy or TLS settings could not
+// be applied
+func ShowTransportConfigErrorMessage(err error) {
+	printline("I was unable to apply the proxy/TLS settings from the configuration:")
+	printline(" " + fancy.WithPizzazz(err.Error(), fancy.Red))
+	printline("Connections to the ASHIRT servers will be made without these settings.")
+}
+
+// ShowInsecureTLSWarning renders a (loud) warning that server certificates are not being verified
+func ShowInsecureTLSWarning() {
+	printline(fancy.WithPizzazz("!!! WARNING: TLS certificate verification is DISABLED (insecureSkipVerify) !!!", fancy.Bold|fancy.White|fancy.RedBg))
+	printline(fancy.WithBold("Connections to the ASHIRT servers can be intercepted, exposing your credentials and evidence.", fancy.Red))
+	printline(fancy.WithBold("Only use this setting for testing.", fancy.Red))
+	printline()
+}
@@ -0,0 +1,44 @@
+package appdialogs
+
+import (
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/dialog"
+	"github.com/theparanoids/aterm/fancy"
+	"github.com/theparanoids/aterm/network"
+)
+
+// AskForEvidenceDetails reviews the details of new (non-recording) evidence, using the same
+// operation and tag selection as recording uploads. If no operation is provided, one is chosen
+// first; the provided tags are pre-selected, unless a different operation is chosen. Prompts read
+// from the menu's input (i.e. stdin, outside of the menus). Returns ErrCancelled if the user backs
+// out.
+func AskForEvidenceDetails(operationSlug, description string, tags []dtos.Tag) (string, string, []dtos.Tag, error) {
+	if operationSlug == "" {
+		ops := loadOperations(config.ActiveProfile(), "")
+		operationSlug = unwrapOpSlug(askForOperationSlug(ops, ""))
+		if operationSlug == "" {
+			return "", "", nil, ErrCancelled
+		}
+		tags = []dtos.Tag{}
+	}
+
+	description, err := UserQuery("Enter a description for this evidence", &description)
+	if err != nil {
+		return "", "", nil, ErrCancelled
+	}
+
+	var serverTags []dtos.Tag
+	dialog.DoBackgroundLoading(dialog.SyncedFunc(
+		func() {
+			serverTags, err = network.GetTags(operationSlug)
+		}),
+	)
+	if err != nil {
+		printline(fancy.Caution("Unable to get tags", err))
+	} else {
+		tags = askForTags(operationSlug, serverTags, tagsToIDs(tags))
+	}
+
+	return operationSlug, description, tags, nil
+}
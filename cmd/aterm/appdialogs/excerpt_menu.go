@@ -0,0 +1,146 @@
+package appdialogs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/dialog"
+	"github.com/theparanoids/aterm/fancy"
+	"github.com/theparanoids/aterm/network"
+)
+
+// excerptPreviewLines limits how much of an excerpt is shown before it is uploaded
+const excerptPreviewLines = 10
+
+// uploadOutputExcerpt asks for part of the recording's output (between two markers, or a time
+// range), then uploads that output, as text, as a code block
+func uploadOutputExcerpt(metadata recording.RecordingMetadata) {
+	span, defaultDescription, ok := askForOutputSpan(metadata.FilePath)
+	if !ok {
+		return
+	}
+	text, err := recording.RenderOutput(metadata.FilePath, span)
+	if err != nil {
+		printline(fancy.Caution("Unable to read recording", err))
+		return
+	}
+	if text == "" {
+		printfln("No output was found between %v", span)
+		return
+	}
+	previewExcerpt(text)
+
+	operationSlug, description, tags, err := AskForEvidenceDetails(metadata.OperationSlug, defaultDescription, metadata.SelectedTags)
+	if err != nil {
+		printline("Upload cancelled")
+		return
+	}
+	content, err := network.Codeblock{
+		Content: text,
+		Source:  fmt.Sprintf("%v (%v)", filepath.Base(metadata.FilePath), span),
+	}.Encode()
+	if err != nil {
+		printline(fancy.Caution("Unable to prepare the excerpt", err))
+		return
+	}
+
+	progress := dialog.NewProgressBar("Uploading")
+	input := network.UploadInput{
+		OperationSlug: operationSlug,
+		Description:   description,
+		ContentType:   network.ContentTypeCodeblock,
+		Filename:      filepath.Base(metadata.FilePath) + ".txt",
+		TagIDs:        tagsToIDs(tags),
+		Content:       content,
+		OnProgress:    progress.Update,
+	}
+	err = dialog.DoCancellableProgress(progress, func(ctx context.Context) error {
+		_, uploadErr := network.UploadToAshirtWithContext(ctx, input)
+		return uploadErr
+	})
+	if dialog.IsCancelled(err) {
+		printline(fancy.Caution("Upload cancelled", nil))
+	} else if err != nil {
+		printline(fancy.Caution("Unable to upload excerpt", err))
+	} else {
+		printfln("%v Excerpt uploaded", fancy.GreenCheck())
+	}
+}
+
+// askForOutputSpan asks which part of the recording to upload: either the output following a
+// marker (e.g. a command's output), or a time range. The marker's label is returned, to use as the
+// default description. Returns false if the user backs out.
+func askForOutputSpan(path string) (recording.OutputSpan, string, bool) {
+	markers, err := recording.ReadMarkers(path)
+	if err != nil {
+		printline(fancy.Caution("Unable to read markers", err))
+	}
+
+	if len(markers) > 0 {
+		timeRangeOpt := dialog.SimpleOption{Label: "<Time Range>"}
+		options := make([]dialog.SimpleOption, 0, len(markers)+1)
+		for i, m := range markers {
+			options = append(options, dialog.SimpleOption{Label: recording.FormatOffset(m.Seconds) + "  " + m.Label, Data: i})
+		}
+		options = append(options, timeRangeOpt)
+		resp := HandlePlainSelect("Upload the output following which marker", options, func() dialog.SimpleOption {
+			return dialog.InvalidSelection
+		})
+		if !resp.Selection.IsValid() {
+			return recording.OutputSpan{}, "", false
+		}
+		if i, ok := resp.Selection.Data.(int); ok {
+			return recording.SpanFollowing(markers, i), markers[i].Label, true
+		}
+	}
+
+	start, err := askForOffset("Start of the excerpt (e.g. 1:30)", "0:00")
+	if err != nil {
+		return recording.OutputSpan{}, "", false
+	}
+	end, err := askForOffset("End of the excerpt (blank for the end of the recording)", "")
+	if err != nil {
+		return recording.OutputSpan{}, "", false
+	}
+	if end != 0 && end <= start {
+		printline(fancy.Caution("The end of the excerpt must be after its start", nil))
+		return recording.OutputSpan{}, "", false
+	}
+	return recording.OutputSpan{Start: start, End: end}, "", true
+}
+
+// askForOffset asks for an offset into the recording, until a valid offset is provided. A blank
+// answer is zero.
+func askForOffset(prompt, defaultValue string) (float64, error) {
+	for {
+		answer, err := UserQuery(prompt, &defaultValue)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(answer) == "" {
+			return 0, nil
+		}
+		offset, err := recording.ParseOffset(answer)
+		if err == nil {
+			return offset, nil
+		}
+		printline(fancy.Caution("That doesn't look right", err))
+		defaultValue = answer
+	}
+}
+
+// previewExcerpt shows the start of the excerpt
+func previewExcerpt(text string) {
+	lines := strings.Split(text, "\n")
+	printline("Excerpt:")
+	for i, line := range lines {
+		if i == excerptPreviewLines {
+			printfln("  ... (%v more lines)", len(lines)-i)
+			break
+		}
+		printline("  " + line)
+	}
+}
@@ -56,8 +56,9 @@ func FirstRun(primaryConfigFile, pathToCommonConfig string) (config.TermRecorder
 		network.SetSecretKey(*configData.SecretKey)
 
 		var testErr error
+		var suggestion string
 		dialog.DoBackgroundLoading(dialog.SyncedFunc(func() {
-			_, testErr = network.TestConnection()
+			suggestion, testErr = network.TestConnection()
 		}))
 		if testErr == nil {
 			printf("These configurations work.\n")
@@ -79,6 +80,10 @@ func FirstRun(primaryConfigFile, pathToCommonConfig string) (config.TermRecorder
 			} else {
 				checkConnection = false
 			}
+		} else if isTLSError(testErr) {
+			printf("I couldn't establish a secure connection to the server: '%v'.\n", testErr.Error())
+			printf("%v. These settings can be found in the configuration file.\n", suggestion)
+			checkConnection = false
 		} else {
 			printf("I got an error I wasn't expecting. It's: '%v'. "+
 				"This may be due to a network issue with the ASHIRT servers or with your own connection. "+
@@ -5,7 +5,9 @@ import (
 
 	"github.com/OpenPeeDeeP/xdg"
 	"github.com/theparanoids/aterm/dialog"
+	"github.com/theparanoids/aterm/errors"
 	"github.com/theparanoids/aterm/fancy"
+	"github.com/theparanoids/aterm/network"
 )
 
 func queryWithDefault(prompt string, guessValue *string, bailFunc func()) dialog.QueryResponse {
@@ -54,3 +56,12 @@ func strPtr(s string) *string {
 // defaultRecordingHome represents the path to what a first time user would be suggested as a location
 // to store recordings.
 var defaultRecordingHome = filepath.Join(xdg.DataHome(), "aterm", "recordings")
+
+// isTLSError checks if the provided error (from network.TestConnection) is due to a TLS issue
+func isTLSError(err error) bool {
+	return errors.Is(err, network.ErrTLSUnknownAuthority) ||
+		errors.Is(err, network.ErrTLSHostnameMismatch) ||
+		errors.Is(err, network.ErrTLSCertificateInvalid) ||
+		errors.Is(err, network.ErrTLSHandshakeFailed) ||
+		errors.Is(err, network.ErrTLSNotTLS)
+}
@@ -2,6 +2,7 @@ package appdialogs
 
 import (
 	"os"
+	"strings"
 
 	"github.com/theparanoids/ashirt-server/backend/dtos"
 	"github.com/theparanoids/aterm/cmd/aterm/config"
@@ -15,6 +16,7 @@ func renderMainMenu(state MenuState) MenuState {
 	rtnState := state
 	menuOptions := []dialog.SimpleOption{
 		dialogOptionStartRecording,
+		dialogOptionManageUploads,
 		dialogOptionUpdateOps,
 		dialogOptionTestConnection,
 		dialogOptionEditRunningConfig,
@@ -32,6 +34,9 @@ func renderMainMenu(state MenuState) MenuState {
 	case dialogOptionExit == resp.Selection:
 		rtnState.CurrentView = MenuViewExit
 
+	case dialogOptionManageUploads == resp.Selection:
+		manageUploadedRecordings()
+
 	case dialogOptionTestConnection == resp.Selection:
 		testConnection()
 
@@ -41,11 +46,16 @@ func renderMainMenu(state MenuState) MenuState {
 			printline(fancy.Caution("Unable to retrieve operations list", err))
 		} else {
 			rtnState.AvailableOperations = newOps
+			saveCachedOperations(state.InstanceConfig.ActiveProfile, newOps)
 		}
 
 	case dialogOptionEditRunningConfig == resp.Selection:
 		newConfig := editConfig(state.InstanceConfig)
 		rtnState.InstanceConfig = newConfig
+		if newConfig.ActiveProfile != state.InstanceConfig.ActiveProfile {
+			printfln("Switched to profile %v", fancy.WithBold(newConfig.ActiveProfile))
+			rtnState.AvailableOperations = loadOperations(newConfig.ActiveProfile, newConfig.OperationSlug)
+		}
 	default:
 		printline("Hmm, I don't know how to handle that request. This is probably a bug. Could you please report this?")
 	}
@@ -65,7 +75,7 @@ func startNewRecording(state MenuState) MenuState {
 
 	resp := askForOperationSlug(state.AvailableOperations, state.InstanceConfig.OperationSlug)
 
-	recordedMetadata := RecordingMetadata{
+	recordedMetadata := recording.RecordingMetadata{
 		OperationSlug: unwrapOpSlug(resp),
 	}
 	// rtnState.InstanceConfig.OperationSlug = opSlug
@@ -81,14 +91,19 @@ func startNewRecording(state MenuState) MenuState {
 
 	// start the recording
 	rtnState.DialogInput = recording.DialogReader()
-	output, err := recording.StartRecording(rtnState.RecordedMetadata.OperationSlug)
+	output, err := recording.StartCommandRecording(rtnState.RecordedMetadata.OperationSlug, state.Command, state.Title, os.Stdout)
+	rtnState.Command = nil
+	rtnState.Title = ""
 
 	if err != nil {
 		printline(fancy.Fatal("Unable to record", err))
 		rtnState.CurrentView = MenuViewMainMenu
 		return rtnState
 	}
-	rtnState.RecordedMetadata.FilePath = output.FilePath
+	rtnState.RecordedMetadata.ApplyOutput(output)
+	if len(state.Command) > 0 {
+		rtnState.CommandExitCode = output.ExitCode
+	}
 	rtnState.CurrentView = MenuViewUploadMenu
 
 	return rtnState
@@ -131,7 +146,17 @@ func updateOperations() ([]dtos.Operation, error) {
 
 func editConfig(runningConfig config.TermRecorderConfig) config.TermRecorderConfig {
 	rtnConfig := runningConfig
-	overrideCfg := config.CloneConfigAsOverrides(runningConfig)
+	baseConfig, ok := askForProfile(runningConfig)
+	if !ok {
+		printline("Discarding changes...")
+		return rtnConfig
+	}
+	if baseConfig.ActiveProfile != runningConfig.ActiveProfile {
+		if err := baseConfig.ResolveSecretKey(); err != nil {
+			printline(fancy.Caution("Unable to retrieve the secret key for this profile", err))
+		}
+	}
+	overrideCfg := config.CloneConfigAsOverrides(baseConfig)
 
 	// iterate through each question. After each, check if the user backed out via ^d/^c, and if so, stop asking questions and leave the function
 	type FillQuestion struct {
@@ -141,12 +166,19 @@ func editConfig(runningConfig config.TermRecorderConfig) config.TermRecorderConf
 	}
 	questions := []FillQuestion{
 		FillQuestion{AssignTo: overrideCfg.AccessKey, Fields: accessKeyFields, DefaultVal: overrideCfg.AccessKey},
-		FillQuestion{AssignTo: overrideCfg.SecretKey, Fields: secretKeyFields, DefaultVal: overrideCfg.SecretKey},
+	}
+	// secret keys from an external source can only be changed at that source
+	if baseConfig.UsesExternalSecretKey() {
+		printline("Secret key is provided by " + baseConfig.SecretKeySource())
+	} else {
+		questions = append(questions, FillQuestion{AssignTo: overrideCfg.SecretKey, Fields: secretKeyFields, DefaultVal: overrideCfg.SecretKey})
+	}
+	questions = append(questions,
 		FillQuestion{AssignTo: overrideCfg.APIURL, Fields: apiURLFields, DefaultVal: overrideCfg.APIURL},
 
 		FillQuestion{AssignTo: overrideCfg.RecordingShell, Fields: shellFields, DefaultVal: thisOrThat(overrideCfg.RecordingShell, os.Getenv("SHELL"))},
 		FillQuestion{AssignTo: overrideCfg.OutputDir, Fields: savePathFields, DefaultVal: overrideCfg.OutputDir},
-	}
+	)
 
 	stop := false
 	for _, question := range questions {
@@ -155,7 +187,11 @@ func editConfig(runningConfig config.TermRecorderConfig) config.TermRecorderConf
 		}
 	}
 	if !stop {
-		resp := askForOperationSlug(internalMenuState.AvailableOperations, runningConfig.OperationSlug)
+		availableOps := internalMenuState.AvailableOperations
+		if baseConfig.ActiveProfile != runningConfig.ActiveProfile {
+			availableOps = LoadCachedOperations(baseConfig.ActiveProfile)
+		}
+		resp := askForOperationSlug(availableOps, baseConfig.OperationSlug)
 		if resp.IsKillSignal() {
 			stop = true
 		} else {
@@ -169,7 +205,7 @@ func editConfig(runningConfig config.TermRecorderConfig) config.TermRecorderConf
 		return rtnConfig
 	}
 
-	newCfg := config.PreviewUpdatedInstanceConfig(runningConfig, overrideCfg)
+	newCfg := config.PreviewUpdatedInstanceConfig(baseConfig, overrideCfg).SyncActiveProfile()
 
 	config.PrintConfigTo(newCfg, medium)
 	err := config.ValidateConfig(newCfg)
@@ -213,6 +249,50 @@ func editConfig(runningConfig config.TermRecorderConfig) config.TermRecorderConf
 	return rtnConfig
 }
 
+// askForProfile asks the user which server profile to edit (and switch to), or to create a new
+// profile. Returns the running configuration, switched to the chosen profile, or false if the
+// user backed out.
+func askForProfile(runningConfig config.TermRecorderConfig) (config.TermRecorderConfig, bool) {
+	newProfileOpt := dialog.SimpleOption{Label: "<New Profile>"}
+	options := []dialog.SimpleOption{}
+	for _, name := range runningConfig.ProfileNames() {
+		label := name
+		if name == runningConfig.ActiveProfile {
+			label += fancy.AsBold(" (Current)")
+			options = append([]dialog.SimpleOption{dialog.SimpleOption{Label: label, Data: name}}, options...)
+			continue
+		}
+		options = append(options, dialog.SimpleOption{Label: label, Data: name})
+	}
+	options = append(options, newProfileOpt)
+
+	resp := HandlePlainSelect("Which server profile do you want to use", options, func() dialog.SimpleOption {
+		return dialog.InvalidSelection
+	})
+	if resp.IsKillSignal() || resp.Err != nil {
+		return runningConfig, false
+	}
+
+	var profileName string
+	if resp.Selection == newProfileOpt {
+		nameResp := HandleUserQuery("Enter a name for the new profile", nil, func() {})
+		if nameResp.IsKillSignal() || nameResp.Err != nil || strings.TrimSpace(nameResp.SafeValue()) == "" {
+			return runningConfig, false
+		}
+		profileName = strings.TrimSpace(nameResp.SafeValue())
+	} else if name, ok := resp.Selection.Data.(string); ok {
+		profileName = name
+	} else {
+		return runningConfig, false
+	}
+
+	if profileName == runningConfig.ActiveProfile {
+		return runningConfig, true
+	}
+	// keep the current server details, in case we switch back
+	return runningConfig.SyncActiveProfile().WithProfile(profileName), true
+}
+
 func unwrapOpSlug(selectOpResp dialog.SelectResponse) string {
 	if op, ok := selectOpResp.Selection.Data.(dtos.Operation); ok {
 		return op.Slug
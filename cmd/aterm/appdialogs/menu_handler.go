@@ -1,9 +1,7 @@
 package appdialogs
 
 import (
-	"github.com/theparanoids/ashirt-server/backend/dtos"
 	"github.com/theparanoids/aterm/dialog"
-	"github.com/theparanoids/aterm/fancy"
 )
 
 // MenuView is a wrapper around string to represent each of the various primary screens/menus
@@ -27,36 +25,30 @@ var (
 	dialogOptionUpdateOps         = dialog.SimpleOption{Label: "Refresh Operations"}
 	dialogOptionStartRecording    = dialog.SimpleOption{Label: "Start a New Recording"}
 	dialogOptionEditRunningConfig = dialog.SimpleOption{Label: "Update Settings"}
+	dialogOptionManageUploads     = dialog.SimpleOption{Label: "Manage Uploaded Recordings"}
 
 	// upload menu options
 	dialogOptionJumpToMainMenu   = dialog.SimpleOption{Label: "Return to Main Menu"}
 	dialogOptionUploadRecording  = dialog.SimpleOption{Label: "Upload Recording"}
 	dialogOptionDiscardRecording = dialog.SimpleOption{Label: "Discard Recording"}
 	dialogOptionRenameRecording  = dialog.SimpleOption{Label: "Rename Recording File"}
+	dialogOptionUploadExcerpt    = dialog.SimpleOption{Label: "Upload Output Excerpt"}
+
+	// uploaded recording options
+	dialogOptionEditEvidence    = dialog.SimpleOption{Label: "Edit Description and Tags"}
+	dialogOptionReplaceEvidence = dialog.SimpleOption{Label: "Replace Content"}
+	dialogOptionDeleteEvidence  = dialog.SimpleOption{Label: "Delete Evidence"}
 )
 
 // StartMenus starts processing the internal menu state. This produces a run loop, but should
-// be handled in the main thread
-func StartMenus(initialState MenuState) {
+// be handled in the main thread. Returns the exit code of the recorded command, if a command was
+// provided (see MenuState.Command)
+func StartMenus(initialState MenuState) int {
 	internalMenuState = initialState
-	ops, err := updateOperations()
-	if err != nil {
-		printline(fancy.Caution("Unable to get operations", err))
-
-		// if we've previously recorded, assume the current op is still available
-		if defaultSlug := initialState.InstanceConfig.OperationSlug; defaultSlug != "" {
-			internalMenuState.AvailableOperations = []dtos.Operation{
-				dtos.Operation{
-					Slug: defaultSlug,
-					Name: defaultSlug,
-				},
-			}
-		}
-	} else {
-		internalMenuState.AvailableOperations = ops
-	}
+	internalMenuState.AvailableOperations = loadOperations(initialState.InstanceConfig.ActiveProfile, initialState.InstanceConfig.OperationSlug)
 
 	runMenu()
+	return internalMenuState.CommandExitCode
 }
 
 func runMenu() {
@@ -5,28 +5,27 @@ import (
 
 	"github.com/theparanoids/ashirt-server/backend/dtos"
 	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
 )
 
 type MenuState struct {
 	CurrentView         MenuView
 	AvailableOperations []dtos.Operation
 	DialogInput         io.ReadCloser
-	RecordedMetadata    RecordingMetadata
+	RecordedMetadata    recording.RecordingMetadata
 	InstanceConfig      config.TermRecorderConfig
+	// Command is recorded in place of the shell for the next recording only
+	Command []string
+	// Title is noted in the header of the next recording only
+	Title string
+	// CommandExitCode is the exit code of the most recently recorded Command
+	CommandExitCode int
 }
 
 var internalMenuState = MenuState{}
 
-type RecordingMetadata struct {
-	Uploaded      bool       `json:"uploaded"`
-	FilePath      string     `json:"filePath"`
-	OperationSlug string     `json:"operationSlug"`
-	Description   string     `json:"description"`
-	SelectedTags  []dtos.Tag `json:"selectedTags"`
-}
-
 // IsRecordingValid is a small helper function to determine if the last recording was "valid"
 // Typically not important to call
-func IsRecordingValid(metadata RecordingMetadata) bool {
+func IsRecordingValid(metadata recording.RecordingMetadata) bool {
 	return metadata.FilePath != ""
 }
@@ -0,0 +1,65 @@
+package appdialogs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/fancy"
+)
+
+// LoadCachedOperations retrieves the last known list of operations for the given profile.
+// Returns an empty list if no operations have been cached (or the cache cannot be read)
+func LoadCachedOperations(profile string) []dtos.Operation {
+	var ops []dtos.Operation
+	content, err := ioutil.ReadFile(config.OperationsCachePath(profile))
+	if err != nil || json.Unmarshal(content, &ops) != nil {
+		return []dtos.Operation{}
+	}
+	return ops
+}
+
+// saveCachedOperations records the given list of operations for the given profile, so that
+// they can be used when the server cannot be reached
+func saveCachedOperations(profile string, ops []dtos.Operation) error {
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	cachePath := config.OperationsCachePath(profile)
+	if err = os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cachePath, data, 0600)
+}
+
+// loadOperations retrieves the operations for the given profile from the server, falling back
+// to the cached operations for that profile if the server cannot be reached. Assumes the network
+// package is already configured for this profile.
+func loadOperations(profile, defaultSlug string) []dtos.Operation {
+	ops, err := updateOperations()
+	if err == nil {
+		saveCachedOperations(profile, ops)
+		return ops
+	}
+	printline(fancy.Caution("Unable to get operations", err))
+
+	if cached := LoadCachedOperations(profile); len(cached) > 0 {
+		printf("Using previously retrieved operations (%v total)\n", len(cached))
+		return cached
+	}
+
+	// if we've previously recorded, assume the current op is still available
+	if defaultSlug != "" {
+		return []dtos.Operation{
+			dtos.Operation{
+				Slug: defaultSlug,
+				Name: defaultSlug,
+			},
+		}
+	}
+	return []dtos.Operation{}
+}
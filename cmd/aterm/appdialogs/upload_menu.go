@@ -1,16 +1,17 @@
 package appdialogs
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
 	"github.com/theparanoids/aterm/dialog"
 	"github.com/theparanoids/aterm/errors"
 	"github.com/theparanoids/aterm/fancy"
@@ -22,25 +23,33 @@ func renderUploadMenu(state MenuState) MenuState {
 
 	menuOptions := []dialog.SimpleOption{
 		dialogOptionUploadRecording,
+		dialogOptionUploadExcerpt,
 		dialogOptionRenameRecording,
 		dialogOptionDiscardRecording,
 		dialogOptionJumpToMainMenu,
 	}
 
-	resp := HandlePlainSelect("What do you want to do", menuOptions, func() dialog.SimpleOption {
+	prompt := "What do you want to do"
+	if summary := state.RecordedMetadata.ExitSummary(); summary != "" {
+		subject := "The recording shell"
+		if state.RecordedMetadata.Command != "" {
+			subject = "The recorded command"
+		}
+		prompt = fmt.Sprintf("%v %v. %v", subject, summary, prompt)
+	}
+	resp := HandlePlainSelect(prompt, menuOptions, func() dialog.SimpleOption {
 		return dialogOptionJumpToMainMenu
 	})
 
 	switch {
 	case dialogOptionUploadRecording == resp.Selection:
-		isValid, data := validateRecording(state.RecordedMetadata)
-		if !isValid {
+		if !validateRecording(state.RecordedMetadata) {
 			break
 		}
 		newMetadata, doUpload := collectRecordingMetadata(state.RecordedMetadata)
 		rtnState.RecordedMetadata = newMetadata
 		if doUpload {
-			newMetadata := uploadRecording(rtnState.RecordedMetadata, data)
+			newMetadata := uploadRecording(rtnState.RecordedMetadata)
 			rtnState.RecordedMetadata = newMetadata
 			if rtnState.RecordedMetadata.Uploaded {
 				saveCompletedRecording(rtnState.RecordedMetadata)
@@ -48,6 +57,11 @@ func renderUploadMenu(state MenuState) MenuState {
 			}
 		}
 
+	case dialogOptionUploadExcerpt == resp.Selection:
+		if validateRecording(state.RecordedMetadata) {
+			uploadOutputExcerpt(state.RecordedMetadata)
+		}
+
 	case dialogOptionJumpToMainMenu == resp.Selection:
 		saveCompletedRecording(rtnState.RecordedMetadata)
 		rtnState.CurrentView = MenuViewMainMenu
@@ -70,17 +84,11 @@ func renderUploadMenu(state MenuState) MenuState {
 	return rtnState
 }
 
-func saveCompletedRecording(metadata RecordingMetadata) error {
-	data, err := json.MarshalIndent(metadata, "", "  ")
-
-	if err == nil {
-		savePath := metadata.FilePath + ".recordingmeta.json"
-		err = ioutil.WriteFile(savePath, data, 0600)
-	}
-	return err
+func saveCompletedRecording(metadata recording.RecordingMetadata) error {
+	return recording.SaveMetadata(metadata)
 }
 
-func renameRecording(metadata RecordingMetadata) RecordingMetadata {
+func renameRecording(metadata recording.RecordingMetadata) recording.RecordingMetadata {
 	rtnMetadata := metadata
 
 	dir, originalName := filepath.Split(metadata.FilePath)
@@ -96,7 +104,7 @@ func renameRecording(metadata RecordingMetadata) RecordingMetadata {
 			filename += ".cast"
 		}
 		newPath := filepath.Join(dir, filename)
-		err := os.Rename(metadata.FilePath, newPath)
+		err := recording.MoveRecording(metadata.FilePath, newPath)
 		if err != nil {
 			printline(fancy.Fatal("Unable to move file", err))
 		} else {
@@ -108,19 +116,19 @@ func renameRecording(metadata RecordingMetadata) RecordingMetadata {
 	return rtnMetadata
 }
 
-func discardRecording(metadata RecordingMetadata) RecordingMetadata {
+func discardRecording(metadata recording.RecordingMetadata) recording.RecordingMetadata {
 	rtnMetadata := metadata
 
 	selection, err := YesNoSelect("Are you sure you want to delete this recording", "")
 
 	switch {
 	case true == selection:
-		err := os.Remove(metadata.FilePath)
+		err := recording.DeleteRecording(metadata.FilePath)
 		if err != nil {
 			printfln("Unable to delete recording at: %v", fancy.WithBold(metadata.FilePath))
 			printline(fancy.Fatal("Error", err))
 		}
-		rtnMetadata = RecordingMetadata{}
+		rtnMetadata = recording.RecordingMetadata{}
 	case false == selection:
 		break
 	case err != nil:
@@ -131,58 +139,116 @@ func discardRecording(metadata RecordingMetadata) RecordingMetadata {
 	return rtnMetadata
 }
 
-func validateRecording(metadata RecordingMetadata) (bool, []byte) {
+// validateRecording checks that the recording exists, and can be read. The content itself is not
+// read here, as it will be streamed from disk during upload.
+func validateRecording(metadata recording.RecordingMetadata) bool {
 	var err error
-	var data []byte
 	dialog.DoBackgroundLoadingWithMessage("Validating file",
 		dialog.SyncedFunc(func() {
-			data, err = ioutil.ReadFile(metadata.FilePath)
+			var f *os.File
+			f, err = os.Open(metadata.FilePath)
+			if err == nil {
+				err = f.Close()
+			}
 		}),
 	)
 
 	if err != nil {
 		printline(fancy.Fatal("Couldn't validate file", err))
-		return false, data
+		return false
 	}
 	printfln("%v File Validated", fancy.GreenCheck())
-	return true, data
+	return true
 }
 
-func uploadRecording(metadata RecordingMetadata, content []byte) RecordingMetadata {
+func uploadRecording(metadata recording.RecordingMetadata) recording.RecordingMetadata {
 	rtnMetadata := metadata
 	//TODO print summary of future upload
 
+	var ok bool
+	if rtnMetadata, ok = checkForDuplicateUpload(rtnMetadata); !ok {
+		return rtnMetadata
+	}
 	doContinue, err := dialog.YesNoPrompt("Do you want to continue?", "", internalMenuState.DialogInput)
 	if err != nil {
 		printfln("I got an error handling that respone: %v", fancy.WithBold(err.Error()))
 		return rtnMetadata
 	}
 	if doContinue {
+		if titled, err := recording.ApplyDescriptionTitle(rtnMetadata); err != nil {
+			printline(fancy.Caution("Unable to set the recording's title", err))
+		} else {
+			rtnMetadata = titled
+		}
+		content, err := os.Open(metadata.FilePath)
+		if err != nil {
+			printline(fancy.Caution("Unable to read recording", err))
+			return rtnMetadata
+		}
+		defer content.Close()
+
+		progress := dialog.NewProgressBar("Uploading")
 		input := network.UploadInput{
 			OperationSlug: metadata.OperationSlug,
-			Description:   metadata.Description,
+			Description:   recording.EvidenceDescription(metadata, config.OmitContext()),
 			ContentType:   network.ContentTypeTerminalRecording,
 			Filename:      filepath.Base(metadata.FilePath),
 			TagIDs:        tagsToIDs(metadata.SelectedTags), // TODO: filter out what doesn't exist anymore
-			Content:       bytes.NewReader(content),
+			Content:       content,
+			OnProgress:    progress.Update,
 		}
-		dialog.DoBackgroundLoading(dialog.SyncedFunc(
-			func() {
-				_, err = network.UploadToAshirt(input)
-			}),
-		)
-		if err != nil {
+		var evidence *dtos.Evidence
+		err = dialog.DoCancellableProgress(progress, func(ctx context.Context) error {
+			var uploadErr error
+			evidence, uploadErr = network.UploadToAshirtWithContext(ctx, input)
+			return uploadErr
+		})
+		if dialog.IsCancelled(err) {
+			printline(fancy.Caution("Upload cancelled", nil))
+		} else if err != nil {
 			printline(fancy.Caution("Unable to upload recording", err))
 		} else {
 			printfln("%v File uploaded", fancy.GreenCheck())
 			rtnMetadata.Uploaded = true
+			if evidence != nil {
+				rtnMetadata.EvidenceUUID = evidence.UUID
+			}
 		}
 	}
 
 	return rtnMetadata
 }
 
-func collectRecordingMetadata(metadata RecordingMetadata) (RecordingMetadata, bool) {
+// checkForDuplicateUpload notes the recording's current content hash, and checks whether that
+// content was already uploaded to the operation (see config.DuplicateUploads). Returns false if
+// the upload should not proceed.
+func checkForDuplicateUpload(metadata recording.RecordingMetadata) (recording.RecordingMetadata, bool) {
+	hash, err := recording.HashRecording(metadata.FilePath)
+	if err != nil {
+		printline(fancy.Caution("Unable to read recording", err))
+		return metadata, false
+	}
+	metadata.ContentHash = hash
+	if config.DuplicateUploads() == config.DuplicateUploadsAllow {
+		return metadata, true
+	}
+
+	duplicates, err := recording.FindUploadedDuplicates(config.OutputDir(), metadata)
+	if err != nil || len(duplicates) == 0 {
+		return metadata, true
+	}
+	printline(fancy.Caution("This recording was already uploaded to this operation", nil))
+	for _, duplicate := range duplicates {
+		printfln("  %v", fancy.WithBold(duplicate.FilePath))
+	}
+	if config.DuplicateUploads() == config.DuplicateUploadsRefuse {
+		printline("Duplicate uploads are refused (see the duplicateUploads setting)")
+		return metadata, false
+	}
+	return metadata, true
+}
+
+func collectRecordingMetadata(metadata recording.RecordingMetadata) (recording.RecordingMetadata, bool) {
 	// collect data
 	rtnMetadata := metadata
 	continueUpload := true
@@ -190,7 +256,15 @@ func collectRecordingMetadata(metadata RecordingMetadata) (RecordingMetadata, bo
 	collectedErrors.ErrorFormat = errors.MultiErrorPrintFormat
 	var err error
 
-	rtnMetadata.Description, err = UserQuery("Enter a description for this recording", &metadata.Description)
+	defaultDescription := metadata.Description
+	if defaultDescription == "" && config.DescriptionTemplate() != "" {
+		rendered, err := recording.RenderDescription(config.DescriptionTemplate(), recording.NewDescriptionFields(metadata))
+		if err != nil {
+			printline(fancy.Caution("Unable to fill in the description", err))
+		}
+		defaultDescription = rendered
+	}
+	rtnMetadata.Description, err = UserQuery("Enter a description for this recording", &defaultDescription)
 	collectedErrors = multierror.Append(collectedErrors, err)
 
 	var serverTags []dtos.Tag
@@ -202,7 +276,11 @@ func collectRecordingMetadata(metadata RecordingMetadata) (RecordingMetadata, bo
 	if err != nil {
 		printline(fancy.Caution("Unable to get tags", err))
 	} else {
-		rtnMetadata.SelectedTags = askForTags(metadata.OperationSlug, serverTags, tagsToIDs(metadata.SelectedTags))
+		selectedTagIDs := tagsToIDs(metadata.SelectedTags)
+		if len(selectedTagIDs) == 0 {
+			selectedTagIDs = suggestTags(metadata, serverTags)
+		}
+		rtnMetadata.SelectedTags = askForTags(metadata.OperationSlug, serverTags, selectedTagIDs)
 	}
 	collectedErrors = multierror.Append(collectedErrors, err)
 
@@ -218,15 +296,23 @@ func collectRecordingMetadata(metadata RecordingMetadata) (RecordingMetadata, bo
 func askForTags(operationSlug string, allTags []dtos.Tag, selectedTagIDs []int64) []dtos.Tag {
 	doneOpt := dialog.SimpleOption{Label: "<Done>"}
 	createOpt := dialog.SimpleOption{Label: "<New>"}
+	searchOpt := dialog.SimpleOption{Label: "<Search>"}
+	clearOpt := dialog.SimpleOption{Label: "<Deselect All>"}
 
 	for {
-		selection := askForSingleTag(allTags, selectedTagIDs, []dialog.SimpleOption{doneOpt, createOpt})
+		selection := askForSingleTag(allTags, selectedTagIDs, []dialog.SimpleOption{doneOpt, createOpt, searchOpt, clearOpt})
 		if !selection.IsValid() {
 			return []dtos.Tag{}
 		}
 
 		if selection == doneOpt {
 			break
+		} else if selection == clearOpt {
+			selectedTagIDs = []int64{}
+		} else if selection == searchOpt {
+			if tagID, ok := searchForTag(allTags, selectedTagIDs); ok {
+				toggleValue(&selectedTagIDs, tagID)
+			}
 		} else if selection == createOpt {
 			newTag, err := askForNewTag(operationSlug, allTags)
 			if err != nil {
@@ -263,6 +349,86 @@ func askForTags(operationSlug string, allTags []dtos.Tag, selectedTagIDs []int64
 	return submitTags
 }
 
+// searchForTag asks for a search term, then presents the tags that fuzzily match that term (best
+// match first), returning the ID of the chosen tag. Returns false if no tag was chosen.
+func searchForTag(allTags []dtos.Tag, selectedTagIDs []int64) (int64, bool) {
+	query, err := UserQuery("Search for a tag", nil)
+	if err != nil {
+		return 0, false
+	}
+
+	type match struct {
+		tag   dtos.Tag
+		score int
+	}
+	matches := []match{}
+	for _, tag := range allTags {
+		if score, ok := dialog.FuzzyScore(query, tag.Name); ok {
+			matches = append(matches, match{tag, score})
+		}
+	}
+	if len(matches) == 0 {
+		printfln("No tags match %v", fancy.WithBold(query, 0))
+		return 0, false
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+
+	backOpt := dialog.SimpleOption{Label: "<Back>"}
+	options := []dialog.SimpleOption{backOpt}
+	for _, m := range matches {
+		label := m.tag.Name
+		if findIndex(selectedTagIDs, m.tag.ID) > -1 {
+			label += " (Deselect)"
+		}
+		options = append(options, dialog.SimpleOption{Label: label, Data: m.tag.ID})
+	}
+	resp := HandlePlainSelect(fmt.Sprintf("Tags matching %q", query), options, func() dialog.SimpleOption {
+		return backOpt
+	})
+	tagID, ok := resp.Selection.Data.(int64)
+	return tagID, ok
+}
+
+// suggestTags applies the configured tag rules to the recording, returning the IDs of the
+// suggested tags. Suggested tags that do not exist in the operation are only noted, so that the
+// user can choose whether to create them.
+func suggestTags(metadata recording.RecordingMetadata, serverTags []dtos.Tag) []int64 {
+	suggestedIDs := []int64{}
+	rules, err := recording.CompileTagRules(config.TagRules())
+	if err != nil {
+		printline(fancy.Caution("Unable to suggest tags", err))
+		return suggestedIDs
+	}
+	names, err := rules.SuggestTags(metadata)
+	if err != nil {
+		printline(fancy.Caution("Unable to suggest tags", err))
+		return suggestedIDs
+	}
+
+	found, missing := []string{}, []string{}
+	for _, name := range names {
+		matched := false
+		for _, tag := range serverTags {
+			if strings.EqualFold(tag.Name, name) {
+				suggestedIDs = append(suggestedIDs, tag.ID)
+				found = append(found, tag.Name)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			missing = append(missing, name)
+		}
+	}
+	if len(found) > 0 {
+		printfln("Selected suggested tags: %v", fancy.WithBold(strings.Join(found, ", "), 0))
+	}
+	if len(missing) > 0 {
+		printfln("Suggested tags not found in this operation (choose <New> to create them): %v", strings.Join(missing, ", "))
+	}
+	return suggestedIDs
+}
+
 func askForNewTag(operationSlug string, allTags []dtos.Tag) (*dtos.Tag, error) {
 	name, err := UserQuery("Enter a new tag name", nil)
 	if err != nil {
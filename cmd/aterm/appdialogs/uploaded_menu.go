@@ -0,0 +1,190 @@
+package appdialogs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/dialog"
+	"github.com/theparanoids/aterm/fancy"
+	"github.com/theparanoids/aterm/network"
+)
+
+// manageUploadedRecordings lists the recordings that have been uploaded (as noted in their
+// metadata), then offers to change or delete the evidence for the chosen recording
+func manageUploadedRecordings() {
+	summaries, err := recording.FindRecordings(config.OutputDir())
+	if err != nil {
+		printline(fancy.Caution("Unable to find recordings", err))
+		return
+	}
+
+	backOpt := dialog.SimpleOption{Label: "<Back>"}
+	options := []dialog.SimpleOption{}
+	for _, summary := range summaries {
+		if summary.EvidenceUUID == "" {
+			continue
+		}
+		label := fmt.Sprintf("%v/%v", summary.OperationSlug, filepath.Base(summary.FilePath))
+		if summary.Description != "" {
+			label += " - " + summary.Description
+		}
+		options = append(options, dialog.SimpleOption{Label: label, Data: summary.FilePath})
+	}
+	if len(options) == 0 {
+		printline("No uploaded recordings were found")
+		return
+	}
+	options = append(options, backOpt)
+
+	resp := HandlePlainSelect("Which recording's evidence do you want to change", options, func() dialog.SimpleOption {
+		return backOpt
+	})
+	path, ok := resp.Selection.Data.(string)
+	if !ok {
+		return
+	}
+	metadata, err := recording.LoadMetadata(path)
+	if err != nil {
+		printline(fancy.Caution("Unable to read the recording's details", err))
+		return
+	}
+	metadata.FilePath = path
+	manageUploadedRecording(metadata)
+}
+
+// manageUploadedRecording offers to change or delete the evidence for a single recording, until
+// the user goes back or the evidence is deleted
+func manageUploadedRecording(metadata recording.RecordingMetadata) {
+	backOpt := dialog.SimpleOption{Label: "<Back>"}
+	options := []dialog.SimpleOption{
+		dialogOptionEditEvidence,
+		dialogOptionReplaceEvidence,
+		dialogOptionDeleteEvidence,
+		backOpt,
+	}
+	for metadata.EvidenceUUID != "" {
+		resp := HandlePlainSelect("What do you want to do with this evidence", options, func() dialog.SimpleOption {
+			return backOpt
+		})
+		switch {
+		case dialogOptionEditEvidence == resp.Selection:
+			metadata = editUploadedEvidence(metadata)
+		case dialogOptionReplaceEvidence == resp.Selection:
+			metadata = replaceUploadedEvidence(metadata)
+		case dialogOptionDeleteEvidence == resp.Selection:
+			metadata = deleteUploadedEvidence(metadata)
+		default:
+			return
+		}
+	}
+}
+
+func editUploadedEvidence(metadata recording.RecordingMetadata) recording.RecordingMetadata {
+	description, err := UserQuery("Enter a description for this recording", &metadata.Description)
+	if err != nil {
+		return metadata
+	}
+
+	var serverTags []dtos.Tag
+	dialog.DoBackgroundLoading(dialog.SyncedFunc(func() {
+		serverTags, err = network.GetTags(metadata.OperationSlug)
+	}))
+	if err != nil {
+		printline(fancy.Caution("Unable to get tags", err))
+		return metadata
+	}
+	selectedTags := askForTags(metadata.OperationSlug, serverTags, tagsToIDs(metadata.SelectedTags))
+
+	updated := metadata
+	updated.Description = description
+	evidenceDescription := recording.EvidenceDescription(updated, config.OmitContext())
+	update := network.EvidenceUpdate{
+		OperationSlug: metadata.OperationSlug,
+		EvidenceUUID:  metadata.EvidenceUUID,
+		Description:   &evidenceDescription,
+	}
+	oldIDs, newIDs := tagsToIDs(metadata.SelectedTags), tagsToIDs(selectedTags)
+	for _, id := range newIDs {
+		if findIndex(oldIDs, id) == -1 {
+			update.TagsToAdd = append(update.TagsToAdd, id)
+		}
+	}
+	for _, id := range oldIDs {
+		if findIndex(newIDs, id) == -1 {
+			update.TagsToRemove = append(update.TagsToRemove, id)
+		}
+	}
+
+	dialog.DoBackgroundLoading(dialog.SyncedFunc(func() {
+		err = network.UpdateEvidence(update)
+	}))
+	if err != nil {
+		printline(fancy.Caution("Unable to update evidence", err))
+		return metadata
+	}
+	printfln("%v Evidence updated", fancy.GreenCheck())
+	metadata.Description = description
+	metadata.SelectedTags = selectedTags
+	saveCompletedRecording(metadata)
+	return metadata
+}
+
+func replaceUploadedEvidence(metadata recording.RecordingMetadata) recording.RecordingMetadata {
+	confirmed, err := YesNoSelect("Replace the evidence's content with this recording", "")
+	if err != nil || !confirmed {
+		return metadata
+	}
+	content, err := os.Open(metadata.FilePath)
+	if err != nil {
+		printline(fancy.Caution("Unable to read recording", err))
+		return metadata
+	}
+	defer content.Close()
+
+	progress := dialog.NewProgressBar("Uploading")
+	update := network.EvidenceUpdate{
+		OperationSlug: metadata.OperationSlug,
+		EvidenceUUID:  metadata.EvidenceUUID,
+		Content:       content,
+		Filename:      filepath.Base(metadata.FilePath),
+		OnProgress:    progress.Update,
+	}
+	err = dialog.DoCancellableProgress(progress, func(ctx context.Context) error {
+		return network.UpdateEvidenceWithContext(ctx, update)
+	})
+	if dialog.IsCancelled(err) {
+		printline(fancy.Caution("Upload cancelled", nil))
+	} else if err != nil {
+		printline(fancy.Caution("Unable to replace evidence", err))
+	} else {
+		printfln("%v Evidence replaced", fancy.GreenCheck())
+		metadata.ContentHash, _ = recording.HashRecording(metadata.FilePath)
+		saveCompletedRecording(metadata)
+	}
+	return metadata
+}
+
+func deleteUploadedEvidence(metadata recording.RecordingMetadata) recording.RecordingMetadata {
+	confirmed, err := YesNoSelect("Are you sure you want to delete this evidence", "The recording itself will be kept")
+	if err != nil || !confirmed {
+		return metadata
+	}
+
+	dialog.DoBackgroundLoading(dialog.SyncedFunc(func() {
+		err = network.DeleteEvidence(metadata.OperationSlug, metadata.EvidenceUUID)
+	}))
+	if err != nil {
+		printline(fancy.Caution("Unable to delete evidence", err))
+		return metadata
+	}
+	printfln("%v Evidence deleted", fancy.GreenCheck())
+	metadata.Uploaded = false
+	metadata.EvidenceUUID = ""
+	saveCompletedRecording(metadata)
+	return metadata
+}
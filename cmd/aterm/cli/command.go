@@ -0,0 +1,230 @@
+// Package cli provides a small framework for building subcommand-based command line interfaces,
+// with per-command help text and shell completion. Only the standard library is used.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Exit codes used by the framework itself. Commands are free to return any other code.
+const (
+	ExitSuccess = 0
+	ExitUsage   = 2
+)
+
+// Completer suggests values for a flag or positional argument, given the partial value typed so
+// far. Returning nil indicates that the shell should fall back to completing file names.
+type Completer func(prefix string) []string
+
+// Command describes a single (sub)command.
+type Command struct {
+	// Name is the word used to invoke this command
+	Name string
+	// Args describes the positional arguments, for help text (e.g. "[FILE]")
+	Args string
+	// Summary is a one-line description, shown when listing commands
+	Summary string
+	// Description is the full description, shown in this command's help text
+	Description string
+	// Hidden commands are not listed in help text, nor offered as completions
+	Hidden bool
+	// RawArgs commands receive all of their arguments as-is, without any flag parsing
+	RawArgs bool
+
+	// SetFlags registers the flags for this command. Flags are not inherited by subcommands.
+	SetFlags func(fs *FlagSet)
+	// Run executes this command with the positional arguments, returning the exit code. Commands
+	// without a Run function simply print their help text (i.e. list their subcommands).
+	Run func(args []string) int
+	// CompleteArgs suggests values for the positional arguments. If nil, file names are suggested
+	// for commands that accept arguments (i.e. have Args set).
+	CompleteArgs Completer
+
+	Subcommands []*Command
+
+	parent *Command
+	flags  *FlagSet
+}
+
+// Stdout and Stderr are where help text and errors are written
+var (
+	Stdout io.Writer
+	Stderr io.Writer
+)
+
+// Flags returns the flags for this command, registering them on first use
+func (c *Command) Flags() *FlagSet {
+	if c.flags == nil {
+		c.flags = newFlagSet(c.Name)
+		if c.SetFlags != nil {
+			c.SetFlags(c.flags)
+		}
+	}
+	return c.flags
+}
+
+// Path returns the full invocation for this command (e.g. "aterm config get")
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.Path() + " " + c.Name
+}
+
+// Subcommand finds the named (direct) subcommand, or nil if no such subcommand exists
+func (c *Command) Subcommand(name string) *Command {
+	for _, sub := range c.Subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// link records the parent of every subcommand, so that each command knows its full Path
+func (c *Command) link() {
+	for _, sub := range c.Subcommands {
+		sub.parent = c
+		sub.link()
+	}
+}
+
+// resolve walks the leading arguments to find the subcommand being invoked, returning that command
+// along with the remaining arguments
+func (c *Command) resolve(args []string) (*Command, []string) {
+	cmd := c
+	for len(args) > 0 {
+		sub := cmd.Subcommand(args[0])
+		if sub == nil {
+			break
+		}
+		cmd, args = sub, args[1:]
+	}
+	return cmd, args
+}
+
+// Execute runs the command identified by args (which should not include the program name),
+// writing help text and errors to the provided writers. Returns the exit code.
+func Execute(root *Command, args []string, stdout, stderr io.Writer) int {
+	Stdout, Stderr = stdout, stderr
+	root.link()
+	cmd, args := root.resolve(args)
+	if cmd.RawArgs && cmd.Run != nil {
+		return cmd.Run(args)
+	}
+
+	positional, err := cmd.Flags().parse(args)
+	if err == flag.ErrHelp {
+		cmd.PrintHelp(stdout)
+		return ExitSuccess
+	} else if err != nil {
+		fmt.Fprintf(stderr, "%v: %v\n", cmd.Path(), err)
+		fmt.Fprintf(stderr, "Run '%v --help' for usage.\n", cmd.Path())
+		return ExitUsage
+	}
+
+	if cmd.Run == nil {
+		cmd.PrintHelp(stdout)
+		if len(positional) > 0 {
+			fmt.Fprintf(stderr, "\nUnknown command: %v\n", positional[0])
+			return ExitUsage
+		}
+		return ExitSuccess
+	}
+	return cmd.Run(positional)
+}
+
+// UsageError reports a problem with the way a command was invoked, and returns ExitUsage
+func (c *Command) UsageError(format string, vals ...interface{}) int {
+	fmt.Fprintf(Stderr, "%v: %v\n", c.Path(), fmt.Sprintf(format, vals...))
+	fmt.Fprintf(Stderr, "Run '%v --help' for usage.\n", c.Path())
+	return ExitUsage
+}
+
+// PrintHelp writes the help text for this command to w
+func (c *Command) PrintHelp(w io.Writer) {
+	desc := c.Description
+	if desc == "" {
+		desc = c.Summary
+	}
+	if desc != "" {
+		fmt.Fprintln(w, desc)
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "Usage:")
+	if c.Run != nil {
+		usage := "  " + c.Path()
+		if c.Flags().HasFlags() {
+			usage += " [flags]"
+		}
+		if c.Args != "" {
+			usage += " " + c.Args
+		}
+		fmt.Fprintln(w, usage)
+	}
+	visible := c.visibleSubcommands()
+	if len(visible) > 0 {
+		fmt.Fprintf(w, "  %v <command>\n", c.Path())
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Commands:")
+		width := 0
+		for _, sub := range visible {
+			if len(sub.Name) > width {
+				width = len(sub.Name)
+			}
+		}
+		for _, sub := range visible {
+			fmt.Fprintf(w, "  %-*v   %v\n", width, sub.Name, sub.Summary)
+		}
+	}
+
+	if c.Flags().HasFlags() {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		c.Flags().printDefaults(w)
+	}
+	if len(visible) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Run '%v <command> --help' for more information on a command.\n", c.Path())
+	}
+}
+
+func (c *Command) visibleSubcommands() []*Command {
+	visible := []*Command{}
+	for _, sub := range c.Subcommands {
+		if !sub.Hidden {
+			visible = append(visible, sub)
+		}
+	}
+	return visible
+}
+
+// HelpCommand produces a "help" command, which prints the help text for the named command
+// (e.g. "aterm help config set")
+func HelpCommand(root *Command) *Command {
+	return &Command{
+		Name:    "help",
+		Args:    "[COMMAND...]",
+		Summary: "Show help for a command",
+		Run: func(args []string) int {
+			cmd, rest := root.resolve(args)
+			if len(rest) > 0 {
+				fmt.Fprintf(Stderr, "Unknown command: %v\n", strings.Join(args, " "))
+				return ExitUsage
+			}
+			cmd.PrintHelp(Stdout)
+			return ExitSuccess
+		},
+		CompleteArgs: func(prefix string) []string {
+			names := []string{}
+			for _, sub := range root.visibleSubcommands() {
+				names = append(names, sub.Name)
+			}
+			return names
+		},
+	}
+}
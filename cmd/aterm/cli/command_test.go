@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testOpts struct {
+	name    string
+	verbose bool
+	tags    []string
+	args    []string
+	ran     string
+}
+
+func newTestRoot(opts *testOpts) *Command {
+	return &Command{
+		Name: "tool",
+		SetFlags: func(fs *FlagSet) {
+			fs.BoolVar(&opts.verbose, "verbose", "v", false, "Be chatty")
+		},
+		Run: func(args []string) int {
+			opts.ran, opts.args = "tool", args
+			return ExitSuccess
+		},
+		Subcommands: []*Command{
+			{
+				Name:    "run",
+				Args:    "[FILE]",
+				Summary: "Run a thing",
+				SetFlags: func(fs *FlagSet) {
+					fs.StringVar(&opts.name, "name", "n", "", "The name to use")
+					fs.StringListVar(&opts.tags, "tag", "t", "A tag (may be repeated)")
+					fs.CompleteValues("name", func(prefix string) []string { return []string{"alpha", "beta"} })
+				},
+				Run: func(args []string) int {
+					opts.ran, opts.args = "run", args
+					return 7
+				},
+			},
+			{
+				Name:    "group",
+				Summary: "Holds other commands",
+				Subcommands: []*Command{
+					{Name: "inner", Summary: "An inner command", Run: func(args []string) int {
+						opts.ran = "inner"
+						return ExitSuccess
+					}},
+					{Name: "secret", Hidden: true, Run: func(args []string) int { return ExitSuccess }},
+				},
+			},
+		},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	var opts testOpts
+	var stdout, stderr bytes.Buffer
+	root := newTestRoot(&opts)
+
+	code := Execute(root, []string{"run", "a", "--name", "x", "-t", "one", "b", "--tag=two", "--", "-c", "--name"}, &stdout, &stderr)
+	require.Equal(t, 7, code)
+	require.Equal(t, "run", opts.ran)
+	require.Equal(t, "x", opts.name)
+	require.Equal(t, []string{"one", "two"}, opts.tags)
+	require.Equal(t, []string{"a", "b", "-c", "--name"}, opts.args)
+
+	opts = testOpts{}
+	require.Equal(t, ExitSuccess, Execute(root, []string{"-v"}, &stdout, &stderr))
+	require.Equal(t, "tool", opts.ran)
+	require.True(t, opts.verbose)
+
+	require.Equal(t, ExitSuccess, Execute(root, []string{"group", "inner"}, &stdout, &stderr))
+	require.Equal(t, "inner", opts.ran)
+
+	stderr.Reset()
+	require.Equal(t, ExitUsage, Execute(root, []string{"run", "--nope"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "tool run --help")
+}
+
+func TestHelp(t *testing.T) {
+	var opts testOpts
+	var stdout, stderr bytes.Buffer
+	root := newTestRoot(&opts)
+
+	require.Equal(t, ExitSuccess, Execute(root, []string{"run", "--help"}, &stdout, &stderr))
+	require.Empty(t, opts.ran)
+	help := stdout.String()
+	require.Contains(t, help, "tool run [flags] [FILE]")
+	require.Contains(t, help, "-n, --name string")
+	require.Contains(t, help, "-t, --tag string")
+
+	// group commands without a Run show their help
+	stdout.Reset()
+	require.Equal(t, ExitSuccess, Execute(root, []string{"group"}, &stdout, &stderr))
+	require.Contains(t, stdout.String(), "inner")
+	require.NotContains(t, stdout.String(), "secret")
+
+	stdout.Reset()
+	require.Equal(t, ExitUsage, Execute(root, []string{"group", "bogus"}, &stdout, &stderr))
+}
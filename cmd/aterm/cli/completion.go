@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// completeCommandName is the hidden command the shell scripts call to retrieve completions
+const completeCommandName = "__complete"
+
+// filesDirective is printed as the last line of completion output when the shell should also
+// offer file names
+const filesDirective = ":files"
+
+// Complete determines the completions for the provided words (which should not include the program
+// name). The last word is the (possibly empty) word being completed. The returned boolean is true
+// if the shell should also offer file names.
+func Complete(root *Command, words []string) ([]string, bool) {
+	root.link()
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current, preceding := words[len(words)-1], words[:len(words)-1]
+
+	// walk the preceding words, tracking the current command and whether the next word is a
+	// flag's value
+	cmd := root
+	var pendingFlag string
+	afterDashes := false
+	positionalCount := 0
+	for _, word := range preceding {
+		switch {
+		case pendingFlag != "":
+			pendingFlag = ""
+		case afterDashes:
+			positionalCount++
+		case word == "--":
+			afterDashes = true
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			name := strings.TrimLeft(word, "-")
+			if !strings.Contains(name, "=") && cmd.Flags().takesValue(name) {
+				pendingFlag = name
+			}
+		default:
+			if sub := cmd.Subcommand(word); sub != nil && positionalCount == 0 {
+				cmd = sub
+			} else {
+				positionalCount++
+			}
+		}
+	}
+
+	if pendingFlag != "" {
+		_, long := cmd.Flags().lookup(pendingFlag)
+		if completer, ok := cmd.Flags().values[long]; ok {
+			return complete(completer, current)
+		}
+		return nil, true
+	}
+	if afterDashes {
+		return nil, true
+	}
+
+	if strings.HasPrefix(current, "-") {
+		candidates := append(cmd.Flags().names(), "--help")
+		return filterPrefix(candidates, current), false
+	}
+
+	candidates := []string{}
+	if positionalCount == 0 {
+		for _, sub := range cmd.visibleSubcommands() {
+			candidates = append(candidates, sub.Name)
+		}
+	}
+	if cmd.Run == nil {
+		return filterPrefix(candidates, current), false
+	}
+	if cmd.CompleteArgs == nil {
+		return filterPrefix(candidates, current), cmd.Args != ""
+	}
+	argCandidates, files := complete(cmd.CompleteArgs, current)
+	return append(filterPrefix(candidates, current), argCandidates...), files
+}
+
+func complete(completer Completer, current string) ([]string, bool) {
+	candidates := completer(current)
+	if candidates == nil {
+		return nil, true
+	}
+	return filterPrefix(candidates, current), false
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	matching := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matching = append(matching, c)
+		}
+	}
+	sort.Strings(matching)
+	return matching
+}
+
+// CompleteCommand produces the hidden command that the completion scripts call to retrieve
+// completions. Completions are printed one per line.
+func CompleteCommand(root *Command) *Command {
+	return &Command{
+		Name:    completeCommandName,
+		Hidden:  true,
+		RawArgs: true,
+		Run: func(args []string) int {
+			candidates, files := Complete(root, args)
+			for _, c := range candidates {
+				fmt.Fprintln(Stdout, c)
+			}
+			if files {
+				fmt.Fprintln(Stdout, filesDirective)
+			}
+			return ExitSuccess
+		},
+	}
+}
+
+// CompletionCommand produces a "completion" command, which prints the completion script for the
+// requested shell
+func CompletionCommand(root *Command) *Command {
+	return &Command{
+		Name:    "completion",
+		Args:    "bash|zsh|fish",
+		Summary: "Generate a shell completion script",
+		Description: "Generate a shell completion script. For example:\n" +
+			"  bash: source <(" + root.Name + " completion bash)\n" +
+			"  zsh:  " + root.Name + " completion zsh > \"${fpath[1]}/_" + root.Name + "\"\n" +
+			"  fish: " + root.Name + " completion fish > ~/.config/fish/completions/" + root.Name + ".fish",
+		Run: func(args []string) int {
+			if len(args) != 1 {
+				fmt.Fprintln(Stderr, "Expected a single shell name: bash, zsh or fish")
+				return ExitUsage
+			}
+			if err := WriteCompletionScript(Stdout, root.Name, args[0]); err != nil {
+				fmt.Fprintln(Stderr, err)
+				return ExitUsage
+			}
+			return ExitSuccess
+		},
+		CompleteArgs: func(prefix string) []string { return []string{"bash", "zsh", "fish"} },
+	}
+}
+
+// WriteCompletionScript writes the completion script for the named shell. The scripts defer to the
+// program itself (via the hidden __complete command) to determine completions.
+func WriteCompletionScript(w io.Writer, program, shell string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("Unsupported shell %q (expected bash, zsh or fish)", shell)
+	}
+	replacer := strings.NewReplacer("{{PROGRAM}}", program, "{{COMPLETE}}", completeCommandName, "{{FILES}}", filesDirective)
+	_, err := io.WriteString(w, replacer.Replace(script))
+	return err
+}
+
+const bashCompletion = `# bash completion for {{PROGRAM}}
+_{{PROGRAM}}_complete() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    local out=($({{PROGRAM}} {{COMPLETE}} "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    local files=0
+    local last=$((${#out[@]} - 1))
+    if [[ $last -ge 0 && "${out[$last]}" == "{{FILES}}" ]]; then
+        files=1
+        unset "out[$last]"
+    fi
+    COMPREPLY=($(compgen -W "${out[*]}" -- "$cur"))
+    if [[ $files -eq 1 ]]; then
+        COMPREPLY+=($(compgen -f -- "$cur"))
+    fi
+}
+complete -o filenames -F _{{PROGRAM}}_complete {{PROGRAM}}
+`
+
+const zshCompletion = `#compdef {{PROGRAM}}
+# zsh completion for {{PROGRAM}}
+_{{PROGRAM}}() {
+    local -a out
+    out=("${(@f)$({{PROGRAM}} {{COMPLETE}} "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    out=(${out:#})
+    if [[ ${#out} -gt 0 && "${out[-1]}" == "{{FILES}}" ]]; then
+        out[-1]=()
+        _files
+    fi
+    if [[ ${#out} -gt 0 ]]; then
+        compadd -- "${out[@]}"
+    fi
+}
+compdef _{{PROGRAM}} {{PROGRAM}}
+`
+
+const fishCompletion = `# fish completion for {{PROGRAM}}
+function __{{PROGRAM}}_complete
+    set -l args (commandline -opc)[2..-1] (commandline -ct)
+    set -l out ({{PROGRAM}} {{COMPLETE}} $args 2>/dev/null)
+    if test (count $out) -gt 0; and test "$out[-1]" = "{{FILES}}"
+        set -e out[-1]
+        __fish_complete_path (commandline -ct)
+    end
+    printf '%s\n' $out
+end
+complete -c {{PROGRAM}} -f -a '(__{{PROGRAM}}_complete)'
+`
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplete(t *testing.T) {
+	root := newTestRoot(&testOpts{})
+
+	tests := []struct {
+		words     []string
+		expected  []string
+		withFiles bool
+	}{
+		{words: []string{""}, expected: []string{"group", "run"}},
+		{words: []string{"r"}, expected: []string{"run"}},
+		{words: []string{"--v"}, expected: []string{"--verbose"}},
+		{words: []string{"group", ""}, expected: []string{"inner"}},
+		{words: []string{"run", "--n"}, expected: []string{"--name"}},
+		{words: []string{"run", "--name", ""}, expected: []string{"alpha", "beta"}},
+		{words: []string{"run", "-n", "b"}, expected: []string{"beta"}},
+		{words: []string{"run", "--tag", ""}, expected: nil, withFiles: true},
+		{words: []string{"run", ""}, expected: []string{}, withFiles: true},
+		{words: []string{"run", "--", "-"}, expected: nil, withFiles: true},
+	}
+	for _, tc := range tests {
+		candidates, files := Complete(root, tc.words)
+		require.Equal(t, tc.expected, candidates, "words: %q", tc.words)
+		require.Equal(t, tc.withFiles, files, "words: %q", tc.words)
+	}
+}
+
+func TestWriteCompletionScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var buf bytes.Buffer
+		require.NoError(t, WriteCompletionScript(&buf, "tool", shell))
+		require.Contains(t, buf.String(), "tool __complete")
+		require.NotContains(t, buf.String(), "{{")
+	}
+	require.Error(t, WriteCompletionScript(&bytes.Buffer{}, "tool", "tcsh"))
+}
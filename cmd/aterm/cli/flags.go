@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FlagSet wraps flag.FlagSet to support flags with both a long and a short name (e.g. --name and
+// -n), while still printing a single entry for each flag in the help text.
+type FlagSet struct {
+	fs      *flag.FlagSet
+	aliases map[string]string // short name => long name
+	shorts  map[string]string // long name => short name
+	values  map[string]Completer
+}
+
+func newFlagSet(name string) *FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return &FlagSet{
+		fs:      fs,
+		aliases: map[string]string{},
+		shorts:  map[string]string{},
+		values:  map[string]Completer{},
+	}
+}
+
+// StringVar defines a string flag. The short name may be blank.
+func (f *FlagSet) StringVar(p *string, long, short, value, usage string) {
+	f.fs.StringVar(p, long, value, usage)
+	f.addAlias(long, short)
+}
+
+// BoolVar defines a bool flag. The short name may be blank.
+func (f *FlagSet) BoolVar(p *bool, long, short string, value bool, usage string) {
+	f.fs.BoolVar(p, long, value, usage)
+	f.addAlias(long, short)
+}
+
+// Float64Var defines a float64 flag. The short name may be blank.
+func (f *FlagSet) Float64Var(p *float64, long, short string, value float64, usage string) {
+	f.fs.Float64Var(p, long, value, usage)
+	f.addAlias(long, short)
+}
+
+// DurationVar defines a time.Duration flag. The short name may be blank.
+func (f *FlagSet) DurationVar(p *time.Duration, long, short string, value time.Duration, usage string) {
+	f.fs.DurationVar(p, long, value, usage)
+	f.addAlias(long, short)
+}
+
+// StringListVar defines a string flag that may be repeated. Each use is appended to the list.
+func (f *FlagSet) StringListVar(p *[]string, long, short, usage string) {
+	f.fs.Var((*stringList)(p), long, usage)
+	f.addAlias(long, short)
+}
+
+// CompleteValues registers a completer for the value of the named (long) flag
+func (f *FlagSet) CompleteValues(long string, completer Completer) {
+	f.values[long] = completer
+}
+
+func (f *FlagSet) addAlias(long, short string) {
+	if short == "" {
+		return
+	}
+	f.fs.Var(f.fs.Lookup(long).Value, short, "")
+	f.aliases[short] = long
+	f.shorts[long] = short
+}
+
+// lookup finds a flag by either its long or short name, returning the long name
+func (f *FlagSet) lookup(name string) (*flag.Flag, string) {
+	if long, ok := f.aliases[name]; ok {
+		name = long
+	}
+	return f.fs.Lookup(name), name
+}
+
+// takesValue returns true if the named flag requires a value (i.e. is not a boolean flag)
+func (f *FlagSet) takesValue(name string) bool {
+	fl, _ := f.lookup(name)
+	if fl == nil {
+		return false
+	}
+	boolFlag, ok := fl.Value.(interface{ IsBoolFlag() bool })
+	return !(ok && boolFlag.IsBoolFlag())
+}
+
+// names returns every flag name (long and short), formatted as they would be on the command line
+func (f *FlagSet) names() []string {
+	names := []string{}
+	f.fs.VisitAll(func(fl *flag.Flag) {
+		if _, isAlias := f.aliases[fl.Name]; isAlias {
+			return
+		}
+		names = append(names, "--"+fl.Name)
+		if short, ok := f.shorts[fl.Name]; ok {
+			names = append(names, "-"+short)
+		}
+	})
+	return names
+}
+
+// parse parses the provided arguments. Unlike flag.FlagSet, flags and positional arguments may be
+// mixed. All arguments after a "--" are treated as positional arguments.
+func (f *FlagSet) parse(args []string) ([]string, error) {
+	var trailing []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, trailing = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	positional := []string{}
+	for {
+		if err := f.fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if f.fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, f.fs.Arg(0))
+		args = f.fs.Args()[1:]
+	}
+	if trailing != nil {
+		positional = append(positional, trailing...)
+	}
+	return positional, nil
+}
+
+// HasFlags returns true if any flags have been defined
+func (f *FlagSet) HasFlags() bool {
+	return len(f.names()) > 0
+}
+
+// printDefaults writes the help text for each flag to w, in the form:
+//
+//	-n, --name string   The description (default "value")
+func (f *FlagSet) printDefaults(w io.Writer) {
+	type line struct{ names, usage string }
+	lines := []line{}
+	width, shortWidth := 0, 0
+	for _, short := range f.shorts {
+		if len(short)+3 > shortWidth {
+			shortWidth = len(short) + 3 // i.e. "-s, "
+		}
+	}
+	f.fs.VisitAll(func(fl *flag.Flag) {
+		if _, isAlias := f.aliases[fl.Name]; isAlias {
+			return
+		}
+		short := ""
+		if s, ok := f.shorts[fl.Name]; ok {
+			short = "-" + s + ","
+		}
+		names := fmt.Sprintf("%-*v--%v", shortWidth, short, fl.Name)
+		typeName, usage := flag.UnquoteUsage(fl)
+		if _, ok := fl.Value.(*stringList); ok {
+			typeName = "string"
+		}
+		if typeName != "" {
+			names += " " + typeName
+		}
+		if fl.DefValue != "" && fl.DefValue != "false" && fl.DefValue != "0" && fl.DefValue != "0s" {
+			usage += fmt.Sprintf(" (default %q)", fl.DefValue)
+		}
+		if len(names) > width {
+			width = len(names)
+		}
+		lines = append(lines, line{names, usage})
+	})
+	for _, l := range lines {
+		fmt.Fprintf(w, "  %-*v   %v\n", width, l.names, l.usage)
+	}
+}
+
+// stringList is a flag.Value that collects every use of a repeated flag
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
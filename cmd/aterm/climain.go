@@ -4,23 +4,26 @@ import (
 	"os"
 
 	"github.com/theparanoids/aterm/cmd/aterm/appdialogs"
+	"github.com/theparanoids/aterm/cmd/aterm/cli"
 	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/headless"
 	"github.com/theparanoids/aterm/cmd/aterm/recording"
 	"github.com/theparanoids/aterm/errors"
 	"github.com/theparanoids/aterm/network"
-
-	"github.com/jrozner/go-info"
 )
 
 func main() {
-	// Parse CLI for overrides
-	opts := config.ParseCLI()
+	os.Exit(cli.Execute(newRootCommand(), os.Args[1:], os.Stdout, os.Stderr))
+}
 
+// runInteractive is the default action: record, then show the upload menu (or show the main
+// menu first, if requested)
+func runInteractive(opts config.CLIOptions) int {
 	appdialogs.PrintVersion()
 
-	if info.Flag() || opts.PrintVersion {
+	if opts.PrintVersion {
 		appdialogs.PrintExtendedVersion()
-		return // exit if they ask to print the version
+		return headless.ExitSuccess // exit if they ask to print the version
 	}
 
 	// Parse env/config file for base values
@@ -32,6 +35,13 @@ func main() {
 		err = config.ParseConfig(opts)
 	}
 
+	if transportErr := network.ConfigureTransport(config.TransportOptions()); transportErr != nil {
+		appdialogs.ShowTransportConfigErrorMessage(transportErr)
+	}
+	if config.InsecureSkipVerify() {
+		appdialogs.ShowInsecureTLSWarning()
+	}
+
 	// Check if first run to set up configuration
 	if errors.Is(err, config.ErrConfigFileDoesNotExist) || opts.ForceFirstRun {
 		configData, _ := appdialogs.FirstRun(config.ATermConfigPath(), config.ASHIRTConfigPath())
@@ -46,6 +56,10 @@ func main() {
 
 	network.SetBaseURL(config.APIURL())
 	network.SetAccessKey(config.AccessKey())
+	network.SetRequestTimeout(config.RequestTimeout())
+	network.SetUploadTimeout(config.UploadTimeout())
+	network.SetMaxRetries(int(config.MaxRetries()))
+	network.SetRetryDelay(config.RetryDelay())
 
 	validationErr := config.ValidateLoadedConfig()
 	if validationErr != nil {
@@ -58,13 +72,15 @@ func main() {
 	// Check CLI flags
 	if opts.PrintConfig {
 		config.PrintLoadedConfig(os.Stdout)
-		return
+		return headless.ExitSuccess
 	}
 
 	recording.InitializeRecordings()
 
 	menuState := appdialogs.MenuState{
 		InstanceConfig: config.CurrentConfig(),
+		Command:        opts.Command,
+		Title:          opts.Title,
 	}
 
 	if opts.ShowMenu {
@@ -72,6 +88,5 @@ func main() {
 	} else {
 		menuState.CurrentView = appdialogs.MenuViewRecording
 	}
-	appdialogs.StartMenus(menuState)
-
+	return appdialogs.StartMenus(menuState)
 }
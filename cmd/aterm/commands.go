@@ -0,0 +1,695 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/theparanoids/aterm/cmd/aterm/appdialogs"
+	"github.com/theparanoids/aterm/cmd/aterm/cli"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/headless"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// newRootCommand builds the full command tree. Running aterm without a command behaves like
+// "aterm record".
+func newRootCommand() *cli.Command {
+	var rootOpts config.CLIOptions
+	root := &cli.Command{
+		Name:        "aterm",
+		Summary:     "Record terminal sessions and upload them to ASHIRT",
+		Description: "ATerm records terminal sessions, and uploads them to ASHIRT as evidence. Without a command, a new recording is started.",
+		SetFlags: func(fs *cli.FlagSet) {
+			bindRecordFlags(fs, &rootOpts)
+			fs.BoolVar(&rootOpts.ShowMenu, "menu", "m", false, "Show the main menu, rather than starting a recording")
+			fs.BoolVar(&rootOpts.PrintConfig, "print-config", "pc", false, "Print the current configuration (post-command line arguments), then exit")
+			fs.BoolVar(&rootOpts.ForceFirstRun, "reset", "", false, "Rerun first run to set up initial values")
+			fs.BoolVar(&rootOpts.HardReset, "reset-hard", "", false, "Ignore the config file and rerun first run")
+			fs.BoolVar(&rootOpts.PrintVersion, "version", "v", false, "Print the software version and build information, then exit")
+		},
+		CompleteArgs: noArgs,
+	}
+	root.Run = func(args []string) int {
+		if len(args) > 0 {
+			return root.UsageError("unknown command %q", args[0])
+		}
+		return runRecord(rootOpts)
+	}
+
+	root.Subcommands = []*cli.Command{
+		newRecordCommand(),
+		newUploadCommand(),
+		newListCommand(),
+		newPlayCommand(),
+		newSearchCommand(),
+		newVerifyCommand(),
+		newConfigCommand(),
+		newOpsCommand(),
+		newTagsCommand(),
+		newEvidenceCommand(),
+		newShellIntegrationCommand(),
+		newVersionCommand(),
+		cli.CompletionCommand(root),
+		cli.HelpCommand(root),
+		cli.CompleteCommand(root),
+	}
+	return root
+}
+
+// bindRecordFlags registers the flags shared by the root and record commands
+func bindRecordFlags(fs *cli.FlagSet, opts *config.CLIOptions) {
+	fs.StringVar(&opts.OperationSlug, "operation", "", "", "Operation slug to record to")
+	fs.StringVar(&opts.OutputFileNamePrefix, "name", "n", "", "The filename prefix of the next recording")
+	fs.StringVar(&opts.Profile, "profile", "p", "", "Name of the server profile to use")
+	fs.StringVar(&opts.RecordingShell, "shell", "s", "", "Path to the shell to use for recording")
+	fs.StringVar(&opts.Title, "title", "", "", "The title of the next recording (defaults to its upload description)")
+	fs.BoolVar(&opts.NoMenu, "no-menu", "", false, "Record, print the result as JSON, then exit without showing any menus")
+	fs.CompleteValues("operation", completeOperations)
+	fs.CompleteValues("profile", completeProfiles)
+}
+
+func runRecord(opts config.CLIOptions) int {
+	if opts.NoMenu {
+		return headless.Record(opts, os.Stdout)
+	}
+	return runInteractive(opts)
+}
+
+func newRecordCommand() *cli.Command {
+	var opts config.CLIOptions
+	return &cli.Command{
+		Name:    "record",
+		Args:    "[-- COMMAND [ARGS...]]",
+		Summary: "Start a new recording",
+		Description: "Start a new recording. Once the recording shell exits, the upload menu is shown.\n" +
+			"With --no-menu, aterm exits once recording completes, and prints the result as JSON.\n\n" +
+			"If a command is provided, that command is recorded instead of an interactive shell, and\n" +
+			"aterm exits with the command's exit code. Place the command after \"--\", so that its\n" +
+			"flags are not mistaken for aterm's (e.g. aterm record --operation op -- nmap -sV host).",
+		SetFlags: func(fs *cli.FlagSet) {
+			bindRecordFlags(fs, &opts)
+		},
+		Run: func(args []string) int {
+			opts.Command = args
+			return runRecord(opts)
+		},
+	}
+}
+
+func newUploadCommand() *cli.Command {
+	var opts config.UploadCLIOptions
+	cmd := &cli.Command{
+		Name:    "upload",
+		Args:    "[FILE]",
+		Summary: "Upload a recording without any prompts",
+		Description: "Upload a recording, without any prompts, and print the result as JSON.\n" +
+			"The operation and description default to those saved alongside the recording.",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.FilePath, "file", "f", "", "The recording to upload")
+			fs.StringVar(&opts.Description, "description", "d", "", "The description for the uploaded evidence")
+			fs.StringListVar(&opts.Tags, "tag", "t", "A tag name to apply to the uploaded evidence (may be repeated)")
+			fs.BoolVar(&opts.CreateTags, "create-tags", "", false, "Create any tags that do not already exist")
+			fs.BoolVar(&opts.SuggestTags, "suggest-tags", "", false, "Also apply the tags suggested by the configured tag rules")
+			fs.BoolVar(&opts.AllowDuplicate, "allow-duplicate", "", false, "Upload even if the same content was already uploaded to the operation")
+			fs.StringVar(&opts.OperationSlug, "operation", "", "", "Operation slug to upload to")
+			fs.StringVar(&opts.Profile, "profile", "p", "", "Name of the server profile to use")
+			fs.CompleteValues("operation", completeOperations)
+			fs.CompleteValues("profile", completeProfiles)
+		},
+	}
+	cmd.Run = func(args []string) int {
+		if opts.FilePath == "" && len(args) == 1 {
+			opts.FilePath = args[0]
+		} else if len(args) > 0 {
+			return headless.UsageError(os.Stdout, fmt.Errorf("Unexpected arguments: %v", strings.Join(args, " ")))
+		}
+		if opts.FilePath == "" {
+			return headless.UsageError(os.Stdout, fmt.Errorf("No recording specified (use --file)"))
+		}
+		return headless.Upload(opts, os.Stdout)
+	}
+	return cmd
+}
+
+func newListCommand() *cli.Command {
+	var opts headless.ListOptions
+	return &cli.Command{
+		Name:    "list",
+		Summary: "List local recordings",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.OperationSlug, "operation", "", "", "Only list recordings for this operation")
+			fs.BoolVar(&opts.JSON, "json", "", false, "Print the list as JSON")
+			fs.CompleteValues("operation", completeOperations)
+		},
+		Run: func(args []string) int {
+			return headless.ListRecordings(opts, os.Stdout)
+		},
+		CompleteArgs: noArgs,
+	}
+}
+
+func newPlayCommand() *cli.Command {
+	var opts recording.PlaybackOptions
+	cmd := &cli.Command{
+		Name:    "play",
+		Args:    "FILE",
+		Summary: "Replay a recording in this terminal",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.Float64Var(&opts.Speed, "speed", "", 1, "Playback speed multiplier")
+			fs.DurationVar(&opts.MaxIdle, "max-idle", "", 0, "Limit pauses between output to this duration")
+		},
+	}
+	cmd.Run = func(args []string) int {
+		if len(args) != 1 {
+			return cmd.UsageError("expected a single recording to play")
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return headless.ExitUsage
+		}
+		defer f.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := recording.Play(ctx, f, os.Stdout, opts); err != nil && err != context.Canceled {
+			fmt.Fprintln(os.Stderr, "\nError:", err)
+			return headless.ExitFailure
+		}
+		return headless.ExitSuccess
+	}
+	return cmd
+}
+
+func newSearchCommand() *cli.Command {
+	var opts headless.SearchOptions
+	cmd := &cli.Command{
+		Name:        "search",
+		Args:        "TEXT",
+		Summary:     "Find the local recordings containing some text",
+		Description: "Searches the commands, output, descriptions and tags of local recordings (ignoring case), and lists when each match occurred. An index of the recordings is kept in the output directory, and is updated before each search.",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.OperationSlug, "operation", "", "", "Only search recordings for this operation")
+			fs.BoolVar(&opts.JSON, "json", "", false, "Print the results as JSON")
+			fs.BoolVar(&opts.Rebuild, "reindex", "", false, "Rebuild the index from scratch before searching")
+			fs.CompleteValues("operation", completeOperations)
+		},
+		CompleteArgs: noArgs,
+	}
+	cmd.Run = func(args []string) int {
+		if len(args) != 1 || args[0] == "" {
+			return cmd.UsageError("expected a single search term (quote terms containing spaces)")
+		}
+		return headless.Search(args[0], opts, os.Stdout)
+	}
+	return cmd
+}
+
+func newVerifyCommand() *cli.Command {
+	var opts headless.VerifyOptions
+	cmd := &cli.Command{
+		Name:    "verify",
+		Args:    "FILE",
+		Summary: "Check that a recording has not changed since it was recorded",
+		Description: "Check a recording against the hash chain saved alongside it (see hashChain), and report the\n" +
+			"first line that has changed. Signed recordings must be signed by the key given by\n" +
+			"--public-key, or by the configured signing key. Exits with 1 if the recording was changed.",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.PublicKeyFile, "public-key", "", "", "PEM public key the recording must be signed with")
+			fs.StringVar(&opts.Profile, "profile", "p", "", "Name of the server profile to use")
+			fs.BoolVar(&opts.JSON, "json", "", false, "Print the result as JSON")
+			fs.CompleteValues("profile", completeProfiles)
+		},
+	}
+	cmd.Run = func(args []string) int {
+		if len(args) != 1 {
+			return cmd.UsageError("expected a single recording to verify")
+		}
+		return headless.Verify(args[0], opts, os.Stdout)
+	}
+	return cmd
+}
+
+func newConfigCommand() *cli.Command {
+	var profile string
+	bindProfile := func(fs *cli.FlagSet) {
+		fs.StringVar(&profile, "profile", "p", "", "Name of the server profile to use")
+		fs.CompleteValues("profile", completeProfiles)
+	}
+	completeSettings := func(prefix string) []string { return config.SettingNames() }
+
+	var reveal bool
+	get := &cli.Command{
+		Name:    "get",
+		Args:    "KEY",
+		Summary: "Print a single setting",
+		Description: "Print the current value of a single setting, after applying the environment.\n" +
+			"Keys match the names used in the config file.",
+		SetFlags: func(fs *cli.FlagSet) {
+			bindProfile(fs)
+			fs.BoolVar(&reveal, "reveal", "", false, "Print the secret key, rather than masking it")
+		},
+		CompleteArgs: completeSettings,
+	}
+	get.Run = func(args []string) int {
+		if len(args) != 1 {
+			return get.UsageError("expected a single setting name")
+		}
+		cfg, err := parseConfigForProfile(profile)
+		if err = ignoreMissingConfig(err); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return headless.ExitConfig
+		}
+		value, err := cfg.GetSetting(args[0])
+		if err != nil {
+			return get.UsageError("%v", err)
+		}
+		if args[0] == "secretKey" && !reveal && value != "" {
+			value = "********"
+		}
+		fmt.Println(value)
+		return headless.ExitSuccess
+	}
+
+	set := &cli.Command{
+		Name:    "set",
+		Args:    "KEY VALUE",
+		Summary: "Change a single setting in the config file",
+		Description: "Change a single setting in the config file. Server details (apiURL, accessKey, secretKey,\n" +
+			"operationSlug and the secret key source) are changed on the active (or --profile) profile.",
+		SetFlags:     bindProfile,
+		CompleteArgs: completeSettings,
+	}
+	set.Run = func(args []string) int {
+		if len(args) != 2 {
+			return set.UsageError("expected a setting name and a value")
+		}
+		cfg, err := config.ParseConfigFileOnly(profile)
+		if err = ignoreMissingConfig(err); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return headless.ExitConfig
+		}
+		if err := cfg.SetSetting(args[0], args[1]); err != nil {
+			return set.UsageError("%v", err)
+		}
+		if err := cfg.WriteConfigToFile(config.ATermConfigPath()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return headless.ExitConfig
+		}
+		return headless.ExitSuccess
+	}
+
+	return &cli.Command{
+		Name:    "config",
+		Summary: "View or change settings",
+		Subcommands: []*cli.Command{
+			get,
+			set,
+			{
+				Name:     "print",
+				Summary:  "Print the full configuration (after applying the environment)",
+				SetFlags: bindProfile,
+				Run: func(args []string) int {
+					cfg, err := parseConfigForProfile(profile)
+					if err = ignoreMissingConfig(err); err != nil {
+						fmt.Fprintln(os.Stderr, "Error:", err)
+					}
+					config.PrintConfigTo(cfg, os.Stdout)
+					return headless.ExitSuccess
+				},
+				CompleteArgs: noArgs,
+			},
+			{
+				Name:    "path",
+				Summary: "Print the location of the config file",
+				Run: func(args []string) int {
+					fmt.Println(config.ATermConfigPath())
+					return headless.ExitSuccess
+				},
+				CompleteArgs: noArgs,
+			},
+		},
+	}
+}
+
+func newOpsCommand() *cli.Command {
+	var opts headless.ListOptions
+	return &cli.Command{
+		Name:    "ops",
+		Summary: "List the operations available on the server",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.Profile, "profile", "p", "", "Name of the server profile to use")
+			fs.BoolVar(&opts.JSON, "json", "", false, "Print the list as JSON")
+			fs.CompleteValues("profile", completeProfiles)
+		},
+		Run: func(args []string) int {
+			return headless.ListOperations(opts, os.Stdout)
+		},
+		CompleteArgs: noArgs,
+	}
+}
+
+func newTagsCommand() *cli.Command {
+	var opts headless.TagOptions
+	bindTagFlags := func(withColor bool) func(fs *cli.FlagSet) {
+		return func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.OperationSlug, "operation", "", "", "Operation slug to manage tags for (defaults to the configured operation)")
+			fs.StringVar(&opts.Profile, "profile", "p", "", "Name of the server profile to use")
+			fs.BoolVar(&opts.JSON, "json", "", false, "Print the result as JSON")
+			if withColor {
+				fs.StringVar(&opts.Color, "color", "", "", "Tag color (e.g. blue, lightRed)")
+			}
+			fs.CompleteValues("operation", completeOperations)
+			fs.CompleteValues("profile", completeProfiles)
+		}
+	}
+	listTags := func(args []string) int {
+		return headless.ListTags(opts.ListOptions, os.Stdout)
+	}
+
+	create := &cli.Command{
+		Name:         "create",
+		Args:         "NAME",
+		Summary:      "Create a tag",
+		SetFlags:     bindTagFlags(true),
+		CompleteArgs: noArgs,
+	}
+	create.Run = func(args []string) int {
+		if len(args) != 1 || args[0] == "" {
+			return create.UsageError("expected a single tag name (quote names containing spaces)")
+		}
+		return headless.CreateTag(args[0], opts, os.Stdout)
+	}
+
+	rename := &cli.Command{
+		Name:         "rename",
+		Args:         "TAG NEW_NAME",
+		Summary:      "Rename (or recolor) a tag, identified by name or ID",
+		SetFlags:     bindTagFlags(true),
+		CompleteArgs: noArgs,
+	}
+	rename.Run = func(args []string) int {
+		if len(args) != 2 || args[1] == "" {
+			return rename.UsageError("expected the tag to rename, and its new name")
+		}
+		return headless.RenameTag(args[0], args[1], opts, os.Stdout)
+	}
+
+	var confirmed bool
+	del := &cli.Command{
+		Name:    "delete",
+		Args:    "TAG",
+		Summary: "Delete a tag, identified by name or ID, removing it from all evidence",
+		SetFlags: func(fs *cli.FlagSet) {
+			bindTagFlags(false)(fs)
+			fs.BoolVar(&confirmed, "yes", "y", false, "Confirm the deletion")
+		},
+		CompleteArgs: noArgs,
+	}
+	del.Run = func(args []string) int {
+		if len(args) != 1 {
+			return del.UsageError("expected a single tag to delete")
+		}
+		if !confirmed {
+			return del.UsageError("deleting a tag removes it from all evidence; use --yes to confirm")
+		}
+		return headless.DeleteTag(args[0], opts, os.Stdout)
+	}
+
+	return &cli.Command{
+		Name:        "tags",
+		Summary:     "List or manage the tags of an operation",
+		Description: "List or manage the tags of an operation. Without a command, the tags are listed.",
+		SetFlags:    bindTagFlags(false),
+		Run:         listTags,
+		Subcommands: []*cli.Command{
+			{
+				Name:         "list",
+				Summary:      "List the tags available for an operation",
+				SetFlags:     bindTagFlags(false),
+				Run:          listTags,
+				CompleteArgs: noArgs,
+			},
+			create,
+			rename,
+			del,
+		},
+		CompleteArgs: noArgs,
+	}
+}
+
+func newEvidenceCommand() *cli.Command {
+	var opts headless.EvidenceOptions
+	var noPrompt bool
+	bindEvidenceFlags := func(withLanguage bool) func(fs *cli.FlagSet) {
+		return func(fs *cli.FlagSet) {
+			fs.StringVar(&opts.Description, "description", "d", "", "The description for the evidence")
+			fs.StringListVar(&opts.Tags, "tag", "t", "A tag name to apply to the evidence (may be repeated)")
+			fs.BoolVar(&opts.CreateTags, "create-tags", "", false, "Create any tags that do not already exist")
+			fs.StringVar(&opts.OperationSlug, "operation", "", "", "Operation slug to upload to")
+			fs.StringVar(&opts.Profile, "profile", "p", "", "Name of the server profile to use")
+			fs.BoolVar(&noPrompt, "no-prompt", "", false, "Upload without reviewing the operation, description and tags")
+			if withLanguage {
+				fs.StringVar(&opts.Language, "language", "l", "", "The code block language (e.g. python, sh)")
+				fs.StringVar(&opts.Source, "source", "", "", "Where the code block came from (e.g. a URL or command)")
+			}
+			fs.CompleteValues("operation", completeOperations)
+			fs.CompleteValues("profile", completeProfiles)
+		}
+	}
+	addEvidence := func(kind string) int {
+		opts.Kind = kind
+		var prompt headless.EvidencePrompter
+		// prompts need a terminal, which is not available when the code block is piped in
+		if !noPrompt && terminal.IsTerminal(int(os.Stdin.Fd())) {
+			prompt = askForEvidenceDetails
+			if kind == headless.EvidenceCode {
+				fmt.Fprintln(os.Stderr, "Enter the code block, then press ^D")
+			}
+		}
+		return headless.AddEvidence(opts, os.Stdin, prompt, os.Stdout)
+	}
+
+	file := &cli.Command{
+		Name:    "file",
+		Args:    "FILE",
+		Summary: "Upload a file",
+		Description: "Upload a file as evidence. Images (png, jpg, gif) are uploaded as screenshots,\n" +
+			"recordings (.cast) as terminal recordings, and other text files as code blocks, with the\n" +
+			"language guessed from the file extension (or provided with --language).",
+		SetFlags: bindEvidenceFlags(true),
+	}
+	file.Run = func(args []string) int {
+		if len(args) != 1 {
+			return file.UsageError("expected a single file to upload")
+		}
+		opts.FilePath = args[0]
+		return addEvidence(headless.EvidenceFile)
+	}
+
+	code := &cli.Command{
+		Name:    "code",
+		Summary: "Upload a code block read from stdin",
+		Description: "Upload a code block, read from stdin, as evidence (e.g. cat exploit.py | aterm evidence\n" +
+			"add code --language python --operation op -d \"Exploit\"). Piped code blocks are uploaded\n" +
+			"without prompts, so the operation must be provided or configured.",
+		SetFlags:     bindEvidenceFlags(true),
+		CompleteArgs: noArgs,
+	}
+	code.Run = func(args []string) int {
+		if len(args) > 0 {
+			return code.UsageError("unexpected arguments: %v", strings.Join(args, " "))
+		}
+		return addEvidence(headless.EvidenceCode)
+	}
+
+	output := &cli.Command{
+		Name:    "output",
+		Args:    "RECORDING",
+		Summary: "Upload part of a recording's output as a code block",
+		Description: "Upload part of a recording's output, as the text that was displayed, as a code block.\n" +
+			"Select the output with --start and --end (e.g. 1:30, 90 or 1m30s), or with --marker, which\n" +
+			"selects the output from a marker to the next marker. With shell integration, each command\n" +
+			"is marked, so --marker \"nmap -sV host\" selects that command's output.",
+		SetFlags: func(fs *cli.FlagSet) {
+			bindEvidenceFlags(true)(fs)
+			fs.StringVar(&opts.Start, "start", "", "", "Where the excerpt starts (defaults to the start of the recording)")
+			fs.StringVar(&opts.End, "end", "", "", "Where the excerpt ends (defaults to the end of the recording)")
+			fs.StringVar(&opts.Marker, "marker", "m", "", "The label (or number) of the marker the excerpt starts at")
+		},
+	}
+	output.Run = func(args []string) int {
+		if len(args) != 1 {
+			return output.UsageError("expected a single recording")
+		}
+		opts.FilePath = args[0]
+		return addEvidence(headless.EvidenceOutput)
+	}
+
+	note := &cli.Command{
+		Name:         "note",
+		Summary:      "Upload a description, without any content",
+		SetFlags:     bindEvidenceFlags(false),
+		CompleteArgs: noArgs,
+	}
+	note.Run = func(args []string) int {
+		if len(args) > 0 {
+			return note.UsageError("unexpected arguments: %v", strings.Join(args, " "))
+		}
+		return addEvidence(headless.EvidenceNote)
+	}
+
+	var updateOpts headless.EvidenceUpdateOptions
+	update := &cli.Command{
+		Name:    "update",
+		Args:    "RECORDING",
+		Summary: "Change the evidence created by uploading a recording",
+		Description: "Change the description or tags of the evidence created by uploading a recording, or\n" +
+			"replace its content with the recording (e.g. after trimming it), and print the result as JSON.",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&updateOpts.Description, "description", "d", "", "The new description for the evidence")
+			fs.StringListVar(&updateOpts.AddTags, "tag", "t", "A tag name to add to the evidence (may be repeated)")
+			fs.StringListVar(&updateOpts.RemoveTags, "remove-tag", "", "A tag name to remove from the evidence (may be repeated)")
+			fs.BoolVar(&updateOpts.CreateTags, "create-tags", "", false, "Create any added tags that do not already exist")
+			fs.BoolVar(&updateOpts.ReplaceContent, "replace-content", "", false, "Upload the recording again, replacing the evidence's content")
+			fs.StringVar(&updateOpts.Profile, "profile", "p", "", "Name of the server profile to use")
+			fs.CompleteValues("profile", completeProfiles)
+		},
+	}
+	update.Run = func(args []string) int {
+		if len(args) != 1 {
+			return update.UsageError("expected a single recording")
+		}
+		updateOpts.FilePath = args[0]
+		return headless.UpdateEvidence(updateOpts, os.Stdout)
+	}
+
+	var deleteProfile string
+	var confirmed bool
+	del := &cli.Command{
+		Name:    "delete",
+		Args:    "RECORDING",
+		Summary: "Delete the evidence created by uploading a recording",
+		Description: "Delete the evidence created by uploading a recording, and print the result as JSON.\n" +
+			"The recording itself is kept, so can be uploaded again.",
+		SetFlags: func(fs *cli.FlagSet) {
+			fs.StringVar(&deleteProfile, "profile", "p", "", "Name of the server profile to use")
+			fs.BoolVar(&confirmed, "yes", "y", false, "Confirm the deletion")
+			fs.CompleteValues("profile", completeProfiles)
+		},
+	}
+	del.Run = func(args []string) int {
+		if len(args) != 1 {
+			return del.UsageError("expected a single recording")
+		}
+		if !confirmed {
+			return del.UsageError("the evidence cannot be recovered once deleted; use --yes to confirm")
+		}
+		return headless.DeleteEvidence(args[0], deleteProfile, os.Stdout)
+	}
+
+	return &cli.Command{
+		Name:    "evidence",
+		Summary: "Upload evidence other than recordings, or change uploaded recordings",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "add",
+				Summary: "Upload a file, code block, note or recording excerpt",
+				Description: "Upload a file, code block, note or recording excerpt as evidence, and print the result\n" +
+					"as JSON. Unless --no-prompt is provided, the operation, description and tags are reviewed\n" +
+					"before uploading.",
+				Subcommands: []*cli.Command{file, code, note, output},
+			},
+			update,
+			del,
+		},
+	}
+}
+
+// askForEvidenceDetails adapts appdialogs.AskForEvidenceDetails for headless.AddEvidence
+func askForEvidenceDetails(details headless.EvidenceDetails) (headless.EvidenceDetails, error) {
+	var err error
+	details.OperationSlug, details.Description, details.Tags, err = appdialogs.AskForEvidenceDetails(details.OperationSlug, details.Description, details.Tags)
+	if err == appdialogs.ErrCancelled {
+		err = context.Canceled
+	}
+	return details, err
+}
+
+func newShellIntegrationCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:        "shell-integration",
+		Args:        "SHELL",
+		Summary:     "Print the shell integration script for bash, zsh or fish",
+		Description: "Prints a script which, when loaded into a recorded shell, notes where each command starts and ends. Add eval \"$(aterm shell-integration bash)\" (or similar) to your shell's rc file, or set shellIntegration in the config to load the script into recording shells automatically.",
+		CompleteArgs: func(prefix string) []string {
+			return recording.IntegratedShells
+		},
+	}
+	cmd.Run = func(args []string) int {
+		if len(args) != 1 {
+			return cmd.UsageError("expected a single shell name")
+		}
+		script, err := recording.ShellIntegrationScript(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return headless.ExitUsage
+		}
+		fmt.Print(script)
+		return headless.ExitSuccess
+	}
+	return cmd
+}
+
+func newVersionCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "version",
+		Summary: "Print the software version and build information",
+		Run: func(args []string) int {
+			appdialogs.PrintVersion()
+			appdialogs.PrintExtendedVersion()
+			return headless.ExitSuccess
+		},
+		CompleteArgs: noArgs,
+	}
+}
+
+func parseConfigForProfile(profile string) (config.TermRecorderConfig, error) {
+	err := config.ParseConfig(config.CLIOptions{Profile: profile})
+	return config.CurrentConfig(), err
+}
+
+// ignoreMissingConfig drops the error returned when no config file exists, as the defaults and
+// environment are enough for these commands
+func ignoreMissingConfig(err error) error {
+	if errors.Is(err, config.ErrConfigFileDoesNotExist) {
+		return nil
+	}
+	return err
+}
+
+// noArgs is used by commands that do not accept positional arguments, to prevent completing files
+func noArgs(prefix string) []string {
+	return []string{}
+}
+
+// completeOperations suggests the operations last retrieved for the configured profile. The server
+// is not contacted, so that completion remains fast.
+func completeOperations(prefix string) []string {
+	cfg, _ := config.PeekConfigFile("")
+	slugs := []string{}
+	for _, op := range appdialogs.LoadCachedOperations(cfg.ActiveProfile) {
+		slugs = append(slugs, op.Slug)
+	}
+	return slugs
+}
+
+func completeProfiles(prefix string) []string {
+	cfg, _ := config.PeekConfigFile("")
+	return cfg.ProfileNames()
+}
@@ -1,5 +1,12 @@
 package config
 
+import (
+	"strings"
+	"time"
+
+	"github.com/theparanoids/aterm/common"
+)
+
 var loadedConfig TermRecorderConfig
 
 func CurrentConfig() TermRecorderConfig {
@@ -29,17 +36,53 @@ func PreviewUpdatedInstanceConfig(cfg TermRecorderConfig, overrides TermRecorder
 	}
 
 	return TermRecorderConfig{
-		ConfigVersion:  cfg.ConfigVersion,
-		APIURL:         selectVal(overrides.APIURL, cfg.APIURL),
-		OutputDir:      selectVal(overrides.OutputDir, cfg.OutputDir),
-		AccessKey:      selectVal(overrides.AccessKey, cfg.AccessKey),
-		SecretKey:      selectVal(overrides.SecretKey, cfg.SecretKey),
-		OutputFileName: selectVal(overrides.OutputFileName, cfg.OutputFileName),
-		OperationSlug:  selectVal(overrides.OperationSlug, cfg.OperationSlug),
-		RecordingShell: selectVal(overrides.RecordingShell, cfg.RecordingShell),
+		ConfigVersion:    cfg.ConfigVersion,
+		ActiveProfile:    cfg.ActiveProfile,
+		Profiles:         cloneProfiles(cfg.Profiles),
+		APIURL:           selectVal(overrides.APIURL, cfg.APIURL),
+		OutputDir:        selectVal(overrides.OutputDir, cfg.OutputDir),
+		AccessKey:        selectVal(overrides.AccessKey, cfg.AccessKey),
+		SecretKey:        selectVal(overrides.SecretKey, cfg.SecretKey),
+		SecretKeyFile:    cfg.SecretKeyFile,
+		SecretKeyCommand: cfg.SecretKeyCommand,
+		SecretKeyEnvOnly: cfg.SecretKeyEnvOnly,
+		OutputFileName:   selectVal(overrides.OutputFileName, cfg.OutputFileName),
+		OperationSlug:    selectVal(overrides.OperationSlug, cfg.OperationSlug),
+		RecordingShell:   selectVal(overrides.RecordingShell, cfg.RecordingShell),
+
+		RecordingWorkingDir: cfg.RecordingWorkingDir,
+		RecordingEnv:        cloneEnv(cfg.RecordingEnv),
+		PromptMarker:        cfg.PromptMarker,
+		ShellIntegration:    cfg.ShellIntegration,
+		HeaderEnv:           append([]string(nil), cfg.HeaderEnv...),
+
+		TagRules:            cloneEnv(cfg.TagRules),
+		DescriptionTemplate: cfg.DescriptionTemplate,
+		DuplicateUploads:    cfg.DuplicateUploads,
+		HashChain:           cfg.HashChain,
+		SigningKeyFile:      cfg.SigningKeyFile,
+		OmitContext:         append([]string(nil), cfg.OmitContext...),
+
+		RequestTimeout: cfg.RequestTimeout,
+		UploadTimeout:  cfg.UploadTimeout,
+		MaxRetries:     cfg.MaxRetries,
+		RetryDelay:     cfg.RetryDelay,
+
+		ProxyURL:           cfg.ProxyURL,
+		CACertPath:         cfg.CACertPath,
+		ClientCertPath:     cfg.ClientCertPath,
+		ClientKeyPath:      cfg.ClientKeyPath,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+
+		loadedSecrets: cfg.loadedSecrets,
 	}
 }
 
+// ActiveProfile is an accessor for the name of the currently loaded server profile
+func ActiveProfile() string {
+	return loadedConfig.ActiveProfile
+}
+
 // APIURL is an accessor for the currently loaded value of APIURL
 func APIURL() string {
 	return loadedConfig.APIURL
@@ -74,3 +117,105 @@ func OperationSlug() string {
 func RecordingShell() string {
 	return loadedConfig.RecordingShell
 }
+
+// RecordingWorkingDir is an accessor for the currently loaded value of RecordingWorkingDir
+func RecordingWorkingDir() string {
+	return loadedConfig.RecordingWorkingDir
+}
+
+// RecordingEnv is an accessor for (a copy of) the currently loaded value of RecordingEnv
+func RecordingEnv() map[string]string {
+	return cloneEnv(loadedConfig.RecordingEnv)
+}
+
+// PromptMarker is an accessor for the currently loaded value of PromptMarker
+func PromptMarker() string {
+	return loadedConfig.PromptMarker
+}
+
+// ShellIntegration is an accessor for the currently loaded value of ShellIntegration
+func ShellIntegration() bool {
+	return loadedConfig.ShellIntegration
+}
+
+// HeaderEnv is an accessor for (a copy of) the currently loaded value of HeaderEnv
+func HeaderEnv() []string {
+	return append([]string(nil), loadedConfig.HeaderEnv...)
+}
+
+// TagRules is an accessor for (a copy of) the currently loaded value of TagRules
+func TagRules() map[string]string {
+	return cloneEnv(loadedConfig.TagRules)
+}
+
+// DescriptionTemplate is an accessor for the currently loaded value of DescriptionTemplate
+func DescriptionTemplate() string {
+	return loadedConfig.DescriptionTemplate
+}
+
+// Values for the DuplicateUploads setting
+const (
+	// DuplicateUploadsWarn notes duplicate uploads, but still uploads them
+	DuplicateUploadsWarn = "warn"
+	// DuplicateUploadsRefuse declines to upload duplicates
+	DuplicateUploadsRefuse = "refuse"
+	// DuplicateUploadsAllow uploads duplicates without comment
+	DuplicateUploadsAllow = "allow"
+)
+
+// DuplicateUploads is an accessor for the currently loaded value of DuplicateUploads. Unset (or
+// unrecognized) values are treated as DuplicateUploadsWarn.
+func DuplicateUploads() string {
+	switch value := strings.ToLower(loadedConfig.DuplicateUploads); value {
+	case DuplicateUploadsRefuse, DuplicateUploadsAllow:
+		return value
+	}
+	return DuplicateUploadsWarn
+}
+
+// HashChain is an accessor for the currently loaded value of HashChain
+func HashChain() bool {
+	return loadedConfig.HashChain
+}
+
+// OmitContext is an accessor for (a copy of) the currently loaded value of OmitContext
+func OmitContext() []string {
+	return append([]string(nil), loadedConfig.OmitContext...)
+}
+
+// RequestTimeout is an accessor for the currently loaded value of RequestTimeout
+func RequestTimeout() time.Duration {
+	return loadedConfig.RequestTimeout
+}
+
+// UploadTimeout is an accessor for the currently loaded value of UploadTimeout
+func UploadTimeout() time.Duration {
+	return loadedConfig.UploadTimeout
+}
+
+// MaxRetries is an accessor for the currently loaded value of MaxRetries
+func MaxRetries() int64 {
+	return loadedConfig.MaxRetries
+}
+
+// RetryDelay is an accessor for the currently loaded value of RetryDelay
+func RetryDelay() time.Duration {
+	return loadedConfig.RetryDelay
+}
+
+// InsecureSkipVerify is an accessor for the currently loaded value of InsecureSkipVerify
+func InsecureSkipVerify() bool {
+	return loadedConfig.InsecureSkipVerify
+}
+
+// TransportOptions collects the currently loaded connection-related values into a form that the
+// network package can use (see network.ConfigureTransport)
+func TransportOptions() common.TransportOptions {
+	return common.TransportOptions{
+		ProxyURL:           loadedConfig.ProxyURL,
+		CACertPath:         loadedConfig.CACertPath,
+		ClientCertPath:     loadedConfig.ClientCertPath,
+		ClientKeyPath:      loadedConfig.ClientKeyPath,
+		InsecureSkipVerify: loadedConfig.InsecureSkipVerify,
+	}
+}
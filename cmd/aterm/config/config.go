@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -9,6 +10,8 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/OpenPeeDeeP/xdg"
 	"github.com/kelseyhightower/envconfig"
@@ -43,7 +46,7 @@ func ATermConfigPath() string {
 // system environment configuration, and CLI overrides, in that order. See ParseConfigNoOverrides
 // for a version with CLI overrides.
 func ParseConfig(overrides CLIOptions) error {
-	cfg, err := ParseConfigNoOverrides()
+	cfg, err := parseConfig(overrides.Profile)
 
 	applyCLIOverrides(&cfg, overrides)
 
@@ -56,24 +59,78 @@ func ParseConfig(overrides CLIOptions) error {
 // config file values and system environment configuration, in that order.
 // Note that this parses and returns the configuration, rather than storing it for later use
 func ParseConfigNoOverrides() (TermRecorderConfig, error) {
+	return parseConfig("")
+}
+
+// ParseConfigFileOnly returns the configuration based on built-in defaults and config file values
+// only (i.e. the environment is ignored), using the named server profile (or the config file's
+// active profile, if blank). This is intended for editing the config file.
+// Note that this parses and returns the configuration, rather than storing it for later use
+func ParseConfigFileOnly(profile string) (TermRecorderConfig, error) {
+	cfg := TermRecorderConfigWithDefaults()
+	fileParseErr := parseConfigFile(&cfg)
+	profileErr := cfg.selectProfile(profile)
+	return cfg, errors.Append(fileParseErr, profileErr)
+}
+
+// PeekConfigFile is identical to ParseConfigFileOnly, except that nothing is ever written: config
+// files from older versions are migrated in memory only. This is intended for reading the config
+// where changing it would be unexpected (e.g. shell completion).
+func PeekConfigFile(profile string) (TermRecorderConfig, error) {
+	cfg := TermRecorderConfigWithDefaults()
+	fileParseErr := readConfigFile(&cfg, false)
+	profileErr := cfg.selectProfile(profile)
+	return cfg, errors.Append(fileParseErr, profileErr)
+}
+
+// parseConfig parses the config file, selects the server profile to use, applies environment
+// overrides, then retrieves the secret key from its source. The profile is selected before
+// applying the environment, so that individual server details can still be overridden via the
+// environment.
+func parseConfig(profile string) (TermRecorderConfig, error) {
 	cfg := TermRecorderConfigWithDefaults()
 
 	fileParseErr := parseConfigFile(&cfg)
+	profileErr := cfg.selectProfile(profile)
+	fromFile := cfg.activeServerProfile()
 	envParseErr := cfg.parseEnv()
+	secretKeyErr := cfg.ResolveSecretKey()
+	cfg.loadedSecrets = &secretOrigin{profile: cfg.activeProfileName(), file: fromFile, loaded: cfg.activeServerProfile()}
 
-	return cfg, errors.Append(fileParseErr, envParseErr)
+	return cfg, errors.Append(fileParseErr, profileErr, envParseErr, secretKeyErr)
 }
 
+// parseConfigFile reads the config file into the provided config. Config files from older
+// versions are migrated to the current version, and the original file is backed up. Config files
+// from newer versions are read as-is, but ErrConfigVersionTooNew is returned.
 func parseConfigFile(cfg *TermRecorderConfig) error {
-	f, err := os.Open(ATermConfigPath())
-	defer f.Close()
+	return readConfigFile(cfg, true)
+}
+
+// readConfigFile reads the config file into the provided config (see parseConfigFile). The migrated
+// config (and backup) is only saved if saveMigrated is true.
+func readConfigFile(cfg *TermRecorderConfig, saveMigrated bool) error {
+	configFilePath := ATermConfigPath()
+	content, err := ioutil.ReadFile(configFilePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return ErrConfigFileDoesNotExist
 		}
 		return errors.Wrap(err, "Unable to read config file")
 	}
-	return cfg.parseFileContent(f)
+
+	migrated, fromVersion, migrateErr := migrateConfigContent(content)
+	if migrateErr != nil && !errors.Is(migrateErr, ErrConfigVersionTooNew) {
+		return migrateErr
+	}
+	if err = cfg.parseFileContent(bytes.NewReader(migrated)); err != nil {
+		return errors.Append(migrateErr, err)
+	}
+	if saveMigrated && migrateErr == nil && fromVersion < CurrentConfigVersion {
+		_, err = saveMigratedConfig(configFilePath, content, migrated, fromVersion)
+		return err
+	}
+	return migrateErr
 }
 
 // applyCLIOverrides provides a mechansim to alter the provided TermRecorderConfig for known CLIOptions
@@ -88,6 +145,9 @@ func applyCLIOverrides(cfg *TermRecorderConfig, overrides CLIOptions) {
 	if overrides.RecordingShell != "" {
 		(*cfg).RecordingShell = overrides.RecordingShell
 	}
+	if overrides.OutputFileNamePrefix != "" {
+		(*cfg).OutputFileName = overrides.OutputFileNamePrefix
+	}
 }
 
 // ValidateLoadedConfig is shorthand for calling ValidateConfig(loadedConfig). i.e. it validates
@@ -123,15 +183,55 @@ func ValidateConfig(tConfig TermRecorderConfig) error {
 	return validationErr.ErrorOrNil()
 }
 
+// CurrentConfigVersion is the version of the configuration file written by this application
+const CurrentConfigVersion int64 = 2
+
+// TermRecorderConfig contains the complete configuration for the application.
+//
+// The server details (APIURL, AccessKey, SecretKey, OperationSlug) always reflect the active
+// profile. When written to a file, these details are stored within the active profile, rather than
+// at the top level (which is only read for compatibility with version 1 configuration files)
 type TermRecorderConfig struct {
-	ConfigVersion  int64  `yaml:"configVersion"`
-	APIURL         string `yaml:"apiURL"         split_words:"true" envconfig:"api_url"`
-	OutputDir      string `yaml:"outputDir"      split_words:"true"`
-	AccessKey      string `yaml:"accessKey"      split_words:"true"`
-	SecretKey      string `yaml:"secretKey"      split_words:"true" envconfig:"secret_key"`
-	OutputFileName string `yaml:"-"              split_words:"true"`
-	OperationSlug  string `yaml:"operationSlug"  split_words:"true"`
-	RecordingShell string `yaml:"recordingShell" split_words:"true"`
+	ConfigVersion    int64                    `yaml:"configVersion"`
+	ActiveProfile    string                   `yaml:"activeProfile"                      ignored:"true"`
+	Profiles         map[string]ServerProfile `yaml:"profiles,omitempty"                 ignored:"true"`
+	APIURL           string                   `yaml:"apiURL,omitempty"        split_words:"true" envconfig:"api_url"`
+	OutputDir        string                   `yaml:"outputDir"               split_words:"true"`
+	AccessKey        string                   `yaml:"accessKey,omitempty"     split_words:"true"`
+	SecretKey        string                   `yaml:"secretKey,omitempty"     split_words:"true" envconfig:"secret_key"`
+	SecretKeyFile    string                   `yaml:"secretKeyFile,omitempty"    split_words:"true" envconfig:"secret_key_file"`
+	SecretKeyCommand string                   `yaml:"secretKeyCommand,omitempty" split_words:"true" envconfig:"secret_key_command"`
+	SecretKeyEnvOnly bool                     `yaml:"secretKeyEnvOnly,omitempty" split_words:"true" envconfig:"secret_key_env_only"`
+	OutputFileName   string                   `yaml:"-"                       split_words:"true"`
+	OperationSlug    string                   `yaml:"operationSlug,omitempty" split_words:"true"`
+	RecordingShell   string                   `yaml:"recordingShell"          split_words:"true"`
+
+	RecordingWorkingDir string     `yaml:"recordingWorkingDir,omitempty" split_words:"true"`
+	RecordingEnv        SettingMap `yaml:"recordingEnv,omitempty"        split_words:"true"`
+	PromptMarker        string     `yaml:"promptMarker,omitempty"        split_words:"true"`
+	ShellIntegration    bool       `yaml:"shellIntegration,omitempty"    split_words:"true"`
+	HeaderEnv           []string   `yaml:"headerEnv,omitempty"           split_words:"true"`
+
+	TagRules            SettingMap `yaml:"tagRules,omitempty"            split_words:"true"`
+	DescriptionTemplate string     `yaml:"descriptionTemplate,omitempty" split_words:"true"`
+	DuplicateUploads    string     `yaml:"duplicateUploads,omitempty"    split_words:"true"`
+	HashChain           bool       `yaml:"hashChain,omitempty"           split_words:"true"`
+	SigningKeyFile      string     `yaml:"signingKeyFile,omitempty"      split_words:"true"`
+	OmitContext         []string   `yaml:"omitContext,omitempty"         split_words:"true"`
+
+	RequestTimeout time.Duration `yaml:"requestTimeout" split_words:"true"`
+	UploadTimeout  time.Duration `yaml:"uploadTimeout"  split_words:"true"`
+	MaxRetries     int64         `yaml:"maxRetries"     split_words:"true"`
+	RetryDelay     time.Duration `yaml:"retryDelay"     split_words:"true"`
+
+	ProxyURL           string `yaml:"proxyURL"           split_words:"true" envconfig:"proxy_url"`
+	CACertPath         string `yaml:"caCertPath"         split_words:"true" envconfig:"ca_cert_path"`
+	ClientCertPath     string `yaml:"clientCertPath"     split_words:"true"`
+	ClientKeyPath      string `yaml:"clientKeyPath"      split_words:"true"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" split_words:"true"`
+
+	// loadedSecrets notes where the secret key settings were loaded from (see parseConfig)
+	loadedSecrets *secretOrigin
 }
 
 type TermRecorderConfigOverrides struct {
@@ -161,7 +261,28 @@ func CloneLoadedConfigAsOverrides() TermRecorderConfigOverrides {
 	return CloneConfigAsOverrides(loadedConfig)
 }
 
+// WriteConfigToFile writes the configuration to the given path, in the current config version.
+// The active server details are saved to the active profile. Files written by newer versions of
+// the application are not overwritten; ErrConfigVersionTooNew is returned instead.
 func (t *TermRecorderConfig) WriteConfigToFile(configFilePath string) error {
+	existingVersion, err := configFileVersion(configFilePath)
+	if err == nil && existingVersion > CurrentConfigVersion {
+		return errors.Wrap(ErrConfigVersionTooNew,
+			fmt.Sprintf("Refusing to overwrite version %v config file with version %v", existingVersion, CurrentConfigVersion))
+	}
+
+	toWrite := t.SyncActiveProfile()
+	toWrite.ConfigVersion = CurrentConfigVersion
+	for name, profile := range toWrite.Profiles {
+		profile = t.loadedSecrets.restore(name, profile)
+		if profile.usesExternalSecretKey() {
+			profile.SecretKey = ""
+		}
+		toWrite.Profiles[name] = profile
+	}
+	toWrite.APIURL, toWrite.AccessKey, toWrite.SecretKey, toWrite.OperationSlug = "", "", "", ""
+	toWrite.SecretKeyFile, toWrite.SecretKeyCommand, toWrite.SecretKeyEnvOnly = "", "", false
+
 	os.MkdirAll(path.Dir(configFilePath), 0755)
 	outFile, err := os.Create(configFilePath)
 	if err != nil {
@@ -169,7 +290,7 @@ func (t *TermRecorderConfig) WriteConfigToFile(configFilePath string) error {
 	}
 	defer outFile.Close()
 
-	if err = yaml.NewEncoder(outFile).Encode(t); err != nil {
+	if err = yaml.NewEncoder(outFile).Encode(toWrite); err != nil {
 		return errors.Wrap(err, "Unable to write config file")
 	}
 	return errors.MaybeWrap(outFile.Close(), "Could not close config file")
@@ -219,19 +340,50 @@ func PrintConfigWithHeaderTo(header string, t TermRecorderConfig, w io.Writer) {
 	writeLine("\r" + fancy.Clear)
 	writeLine(header + ":")
 	writeLine(fmt.Sprintf("\tConfig Version:  %v", t.ConfigVersion))
+	writeLine(fmt.Sprintf("\tActive Profile:  %v", t.ActiveProfile))
+	writeLine(fmt.Sprintf("\tAll Profiles:    %v", strings.Join(t.ProfileNames(), ", ")))
 	writeLine(fmt.Sprintf("\tAPI Host:        %v", t.APIURL))
 	writeLine(fmt.Sprintf("\tOutput Base:     %v", t.OutputDir))
 	writeLine(fmt.Sprintf("\tAccess Key:      %v", t.AccessKey))
-	writeLine(fmt.Sprintf("\tSecret Key:      %v", t.SecretKey))
+	writeLine(fmt.Sprintf("\tSecret Key:      %v", maskSecret(t.SecretKey)))
+	writeLine(fmt.Sprintf("\tSecret Source:   %v", t.SecretKeySource()))
 	writeLine(fmt.Sprintf("\tOutput Prefix:   %v", t.OutputFileName))
 	writeLine(fmt.Sprintf("\tOperation Slug:  %v", t.OperationSlug))
 	writeLine(fmt.Sprintf("\tRecording Shell: %v", t.RecordingShell))
+	writeLine(fmt.Sprintf("\tWorking Dir:     %v", t.RecordingWorkingDir))
+	writeLine(fmt.Sprintf("\tRecording Env:   %v", formatEnv(t.RecordingEnv)))
+	writeLine(fmt.Sprintf("\tPrompt Marker:   %v", t.PromptMarker))
+	writeLine(fmt.Sprintf("\tShell Hooks:     %v", t.ShellIntegration))
+	writeLine(fmt.Sprintf("\tHeader Env:      %v", strings.Join(t.HeaderEnv, ", ")))
+	writeLine(fmt.Sprintf("\tTag Rules:       %v", formatEnv(t.TagRules)))
+	writeLine(fmt.Sprintf("\tDescription:     %q", t.DescriptionTemplate))
+	writeLine(fmt.Sprintf("\tDuplicates:      %v", t.DuplicateUploads))
+	writeLine(fmt.Sprintf("\tHash Chain:      %v", t.HashChain))
+	writeLine(fmt.Sprintf("\tSigning Key:     %v", t.SigningKeyFile))
+	writeLine(fmt.Sprintf("\tOmit Context:    %v", strings.Join(t.OmitContext, ", ")))
+	writeLine(fmt.Sprintf("\tRequest Timeout: %v", t.RequestTimeout))
+	writeLine(fmt.Sprintf("\tUpload Timeout:  %v", t.UploadTimeout))
+	writeLine(fmt.Sprintf("\tMax Retries:     %v", t.MaxRetries))
+	writeLine(fmt.Sprintf("\tRetry Delay:     %v", t.RetryDelay))
+	writeLine(fmt.Sprintf("\tProxy URL:       %v", t.ProxyURL))
+	writeLine(fmt.Sprintf("\tCA Bundle:       %v", t.CACertPath))
+	writeLine(fmt.Sprintf("\tClient Cert:     %v", t.ClientCertPath))
+	writeLine(fmt.Sprintf("\tClient Key:      %v", t.ClientKeyPath))
+	if t.InsecureSkipVerify {
+		writeLine("\tSkip TLS Verify: " + fancy.WithBold("true (INSECURE)", fancy.Red))
+	} else {
+		writeLine("\tSkip TLS Verify: false")
+	}
 }
 
 // TermRecorderConfigWithDefaults generates a TermRecorderConfig struct with some common default values
 func TermRecorderConfigWithDefaults() TermRecorderConfig {
 	return TermRecorderConfig{
-		ConfigVersion:  1,
+		ConfigVersion:  CurrentConfigVersion,
+		ActiveProfile:  DefaultProfileName,
 		RecordingShell: os.Getenv("SHELL"),
+		RequestTimeout: 30 * time.Second,
+		MaxRetries:     3,
+		RetryDelay:     500 * time.Millisecond,
 	}
 }
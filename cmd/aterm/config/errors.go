@@ -17,3 +17,29 @@ var ErrSecretKeyMalformed = errors.New("Secret Key is malformed")
 
 // ErrAPIURLUnparsable is the error returned when the given APIURL cannot be parsed
 var ErrAPIURLUnparsable = errors.New("Unable to parse API URL")
+
+// ErrProfileNotFound is the error returned when the requested server profile does not exist
+var ErrProfileNotFound = errors.New("Server profile does not exist")
+
+// ErrConfigVersionTooNew is the error returned when the config file was written by a newer version
+// of the application than the one currently running
+var ErrConfigVersionTooNew = errors.New("Config file is from a newer version of aterm")
+
+// ErrSecretKeyFileInsecure is the error returned when the secret key file can be read by users
+// other than its owner
+var ErrSecretKeyFileInsecure = errors.New("Secret Key file is readable by other users")
+
+// ErrSecretKeyCommandFailed is the error returned when the secret key command cannot be run, or
+// does not produce a secret key
+var ErrSecretKeyCommandFailed = errors.New("Secret Key command failed")
+
+// ErrSigningKeyFileInsecure is the error returned when the signing key file can be read by users
+// other than its owner
+var ErrSigningKeyFileInsecure = errors.New("Signing key file is readable by other users")
+
+// ErrSigningKeyInvalid is the error returned when the signing key file does not contain an ed25519
+// private key
+var ErrSigningKeyInvalid = errors.New("Signing key is invalid")
+
+// ErrUnknownSetting is the error returned when the requested setting does not exist
+var ErrUnknownSetting = errors.New("Unknown setting")
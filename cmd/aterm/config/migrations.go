@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/theparanoids/aterm/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// configMigration upgrades a configuration document by a single version. Migrations operate on the
+// raw YAML document (rather than TermRecorderConfig), so that fields which no longer exist on the
+// struct can still be read, and so that unknown fields and field order are preserved. Migrations do
+// not need to update the configVersion field; this is handled by migrateConfigContent.
+type configMigration func(doc yaml.MapSlice) (yaml.MapSlice, error)
+
+// migrations lists every config migration, in order. migrations[i] upgrades a version i+1 document
+// into a version i+2 document, so the final entry must produce a CurrentConfigVersion document.
+var migrations = []configMigration{
+	migrateV1ToV2,
+}
+
+// migrateConfigContent upgrades the provided config file content to the current config version,
+// returning the upgraded content, along with the version the content was originally in. Content
+// already at the current version is returned unchanged. Content from a newer version is also
+// returned unchanged, along with ErrConfigVersionTooNew.
+func migrateConfigContent(content []byte) ([]byte, int64, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return content, 0, errors.Wrap(err, "Unable to interpret config file as YAML document")
+	}
+	version, err := readConfigVersion(doc)
+	if err != nil {
+		return content, 0, err
+	}
+	if version > CurrentConfigVersion {
+		return content, version, errors.Wrap(ErrConfigVersionTooNew,
+			fmt.Sprintf("Config file is version %v, but this version of aterm only supports up to version %v", version, CurrentConfigVersion))
+	}
+	if version == CurrentConfigVersion {
+		return content, version, nil
+	}
+
+	for v := version; v < CurrentConfigVersion; v++ {
+		doc, err = migrations[v-1](doc)
+		if err != nil {
+			return content, version, errors.Wrap(err, fmt.Sprintf("Unable to migrate config file from version %v to %v", v, v+1))
+		}
+		doc = setConfigVersion(doc, v+1)
+	}
+
+	migrated, err := yaml.Marshal(doc)
+	if err != nil {
+		return content, version, errors.Wrap(err, "Unable to encode migrated config file")
+	}
+	return migrated, version, nil
+}
+
+// readConfigVersion retrieves the configVersion field from the provided document. Documents without
+// a version are assumed to be version 1 (the first released version)
+func readConfigVersion(doc yaml.MapSlice) (int64, error) {
+	for _, item := range doc {
+		if item.Key != "configVersion" {
+			continue
+		}
+		switch v := item.Value.(type) {
+		case nil:
+			return 1, nil
+		case int:
+			if v < 1 {
+				return 1, nil
+			}
+			return int64(v), nil
+		default:
+			return 0, errors.New(fmt.Sprintf("Config file has an unrecognized configVersion: %v", item.Value))
+		}
+	}
+	return 1, nil
+}
+
+// setConfigVersion updates (or adds) the configVersion field on the provided document
+func setConfigVersion(doc yaml.MapSlice, version int64) yaml.MapSlice {
+	for i, item := range doc {
+		if item.Key == "configVersion" {
+			doc[i].Value = version
+			return doc
+		}
+	}
+	return append(yaml.MapSlice{{Key: "configVersion", Value: version}}, doc...)
+}
+
+// configFileVersion retrieves the version of the config file at the given path. Returns 0 if
+// the file does not exist.
+func configFileVersion(configFilePath string) (int64, error) {
+	content, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "Unable to read config file")
+	}
+	var doc yaml.MapSlice
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return 0, errors.Wrap(err, "Unable to interpret config file as YAML document")
+	}
+	return readConfigVersion(doc)
+}
+
+// saveMigratedConfig backs up the original config file content, then replaces the config file
+// with the migrated content. Returns the path to the backup.
+func saveMigratedConfig(configFilePath string, original, migrated []byte, fromVersion int64) (string, error) {
+	backupPath := fmt.Sprintf("%v.v%v-%v.bak", configFilePath, fromVersion, time.Now().Format("20060102-150405"))
+	if err := ioutil.WriteFile(backupPath, original, 0600); err != nil {
+		return "", errors.Wrap(err, "Unable to back up config file before migrating")
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(configFilePath), filepath.Base(configFilePath)+".*.tmp")
+	if err != nil {
+		return backupPath, errors.Wrap(err, "Unable to save migrated config file")
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(migrated)
+	err = errors.Append(err, tmpFile.Close())
+	if err != nil {
+		return backupPath, errors.Wrap(err, "Unable to save migrated config file")
+	}
+	return backupPath, errors.MaybeWrap(os.Rename(tmpFile.Name(), configFilePath), "Unable to replace config file with migrated version")
+}
+
+// migrateV1ToV2 moves the server details (apiURL, accessKey, secretKey, operationSlug) from the
+// top level of the document into a profile named "default", which is made active.
+func migrateV1ToV2(doc yaml.MapSlice) (yaml.MapSlice, error) {
+	isServerKey := map[interface{}]bool{"apiURL": true, "accessKey": true, "secretKey": true, "operationSlug": true}
+
+	profile := yaml.MapSlice{}
+	rest := yaml.MapSlice{}
+	for _, item := range doc {
+		if isServerKey[item.Key] {
+			profile = append(profile, item)
+		} else if item.Key != "configVersion" && item.Key != "activeProfile" && item.Key != "profiles" {
+			rest = append(rest, item)
+		}
+	}
+
+	migrated := yaml.MapSlice{
+		{Key: "configVersion", Value: 1},
+		{Key: "activeProfile", Value: DefaultProfileName},
+	}
+	if len(profile) > 0 {
+		migrated = append(migrated, yaml.MapItem{
+			Key:   "profiles",
+			Value: yaml.MapSlice{{Key: DefaultProfileName, Value: profile}},
+		})
+	}
+	return append(migrated, rest...), nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/migrations")
+
+// migrationCases finds the test cases for the given migration step. Each case is a pair of files in
+// testdata/migrations/v<from>_to_v<to>: <name>.input.yaml and <name>.golden.yaml
+func migrationCases(t *testing.T, from int64) map[string]string {
+	stepDir := filepath.Join("testdata", "migrations", fmt.Sprintf("v%v_to_v%v", from, from+1))
+	inputs, err := filepath.Glob(filepath.Join(stepDir, "*.input.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs, "migration from version %v has no test cases in %v", from, stepDir)
+
+	cases := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		cases[strings.TrimSuffix(filepath.Base(input), ".input.yaml")] = input
+	}
+	return cases
+}
+
+func TestMigrationsReachCurrentVersion(t *testing.T) {
+	require.Equal(t, CurrentConfigVersion-1, int64(len(migrations)),
+		"every config version must have a migration to the next version")
+}
+
+func TestMigrationSteps(t *testing.T) {
+	for from := int64(1); from < CurrentConfigVersion; from++ {
+		for name, inputPath := range migrationCases(t, from) {
+			t.Run(fmt.Sprintf("v%v/%v", from, name), func(t *testing.T) {
+				input, err := ioutil.ReadFile(inputPath)
+				require.NoError(t, err)
+				var doc yaml.MapSlice
+				require.NoError(t, yaml.Unmarshal(input, &doc))
+
+				doc, err = migrations[from-1](doc)
+				require.NoError(t, err)
+				actual, err := yaml.Marshal(setConfigVersion(doc, from+1))
+				require.NoError(t, err)
+
+				goldenPath := strings.TrimSuffix(inputPath, ".input.yaml") + ".golden.yaml"
+				if *updateGolden {
+					require.NoError(t, ioutil.WriteFile(goldenPath, actual, 0644))
+				}
+				expected, err := ioutil.ReadFile(goldenPath)
+				require.NoError(t, err)
+				require.Equal(t, string(expected), string(actual))
+			})
+		}
+	}
+}
+
+func TestMigratedConfigParses(t *testing.T) {
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "v1_to_v2", "full.input.yaml"))
+	require.NoError(t, err)
+
+	migrated, fromVersion, err := migrateConfigContent(input)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), fromVersion)
+
+	cfg := TermRecorderConfigWithDefaults()
+	require.NoError(t, cfg.parseFileContent(strings.NewReader(string(migrated))))
+	require.NoError(t, cfg.selectProfile(""))
+
+	require.Equal(t, CurrentConfigVersion, cfg.ConfigVersion)
+	require.Equal(t, []string{DefaultProfileName}, cfg.ProfileNames())
+	require.Equal(t, "http://localhost:3000", cfg.APIURL)
+	require.Equal(t, "some-op", cfg.OperationSlug)
+	require.Equal(t, "/home/user/aterm", cfg.OutputDir)
+}
+
+func TestCurrentVersionIsUnchanged(t *testing.T) {
+	content := []byte("configVersion: 2\nactiveProfile: default\noutputDir: /tmp\n")
+
+	migrated, fromVersion, err := migrateConfigContent(content)
+
+	require.NoError(t, err)
+	require.Equal(t, CurrentConfigVersion, fromVersion)
+	require.Equal(t, content, migrated)
+}
+
+func TestNewerVersionIsRejected(t *testing.T) {
+	content := []byte(fmt.Sprintf("configVersion: %v\noutputDir: /tmp\n", CurrentConfigVersion+1))
+
+	migrated, fromVersion, err := migrateConfigContent(content)
+
+	require.ErrorIs(t, err, ErrConfigVersionTooNew)
+	require.Equal(t, CurrentConfigVersion+1, fromVersion)
+	require.Equal(t, content, migrated)
+}
+
+func TestUnrecognizedVersionIsRejected(t *testing.T) {
+	_, _, err := migrateConfigContent([]byte("configVersion: two\n"))
+	require.Error(t, err)
+}
+
+func TestParseConfigFileMigratesAndBacksUp(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	configPath := ATermConfigPath()
+	original, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "v1_to_v2", "full.input.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, ioutil.WriteFile(configPath, original, 0600))
+
+	cfg := TermRecorderConfigWithDefaults()
+	require.NoError(t, parseConfigFile(&cfg))
+
+	backups, err := filepath.Glob(configPath + ".v1-*.bak")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	backup, err := ioutil.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, original, backup)
+
+	version, err := configFileVersion(configPath)
+	require.NoError(t, err)
+	require.Equal(t, CurrentConfigVersion, version)
+}
+
+func TestPeekConfigFileDoesNotMigrate(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	configPath := ATermConfigPath()
+	original, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "v1_to_v2", "full.input.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, ioutil.WriteFile(configPath, original, 0600))
+
+	cfg, err := PeekConfigFile("")
+	require.NoError(t, err)
+	require.Equal(t, CurrentConfigVersion, cfg.ConfigVersion)
+	require.NotEmpty(t, cfg.ProfileNames())
+
+	content, err := ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	require.Equal(t, original, content)
+	entries, err := ioutil.ReadDir(filepath.Dir(configPath))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no backup should be made")
+}
+
+func TestNewerConfigFileIsNotOverwritten(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	configPath := ATermConfigPath()
+	newer := []byte(fmt.Sprintf("configVersion: %v\noutputDir: /tmp\nsomeFutureSetting: true\n", CurrentConfigVersion+1))
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, ioutil.WriteFile(configPath, newer, 0600))
+
+	cfg := TermRecorderConfigWithDefaults()
+	require.ErrorIs(t, parseConfigFile(&cfg), ErrConfigVersionTooNew)
+	require.Equal(t, "/tmp", cfg.OutputDir)
+
+	require.ErrorIs(t, cfg.WriteConfigToFile(configPath), ErrConfigVersionTooNew)
+	content, err := ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	require.Equal(t, newer, content)
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/OpenPeeDeeP/xdg"
+)
+
+// DefaultProfileName is the name of the profile used when no other profile has been specified.
+// Configuration files that predate profiles have their server details moved into this profile.
+const DefaultProfileName = "default"
+
+// profileEnvVar allows the active profile to be selected from the environment. This is handled
+// separately from the other environment variables, as the profile must be selected before the
+// remaining variables are applied.
+const profileEnvVar = "ASHIRT_TERM_RECORDER_PROFILE"
+
+// ServerProfile captures the details needed to work with a single ASHIRT server
+type ServerProfile struct {
+	APIURL           string `yaml:"apiURL"`
+	AccessKey        string `yaml:"accessKey"`
+	SecretKey        string `yaml:"secretKey,omitempty"`
+	SecretKeyFile    string `yaml:"secretKeyFile,omitempty"`
+	SecretKeyCommand string `yaml:"secretKeyCommand,omitempty"`
+	SecretKeyEnvOnly bool   `yaml:"secretKeyEnvOnly,omitempty"`
+	OperationSlug    string `yaml:"operationSlug,omitempty"`
+}
+
+// ProfileNames returns the names of all known profiles, in sorted order
+func (t TermRecorderConfig) ProfileNames() []string {
+	names := make([]string, 0, len(t.Profiles))
+	for name := range t.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseProfile replaces the active server details (APIURL, AccessKey, SecretKey and its source,
+// OperationSlug) with those of the named profile. Returns ErrProfileNotFound if no such profile
+// exists. Note: this does not save the current server details to the previously active profile.
+// See SyncActiveProfile to do this.
+func (t *TermRecorderConfig) UseProfile(name string) error {
+	profile, ok := t.Profiles[name]
+	if !ok {
+		return ErrProfileNotFound
+	}
+	t.ActiveProfile = name
+	t.APIURL = profile.APIURL
+	t.AccessKey = profile.AccessKey
+	t.SecretKey = profile.SecretKey
+	t.SecretKeyFile = profile.SecretKeyFile
+	t.SecretKeyCommand = profile.SecretKeyCommand
+	t.SecretKeyEnvOnly = profile.SecretKeyEnvOnly
+	t.OperationSlug = profile.OperationSlug
+	return nil
+}
+
+// SyncActiveProfile returns a copy of this configuration, where the active profile has been
+// updated to match the active server details. If no profile is active, the default profile is
+// used.
+func (t TermRecorderConfig) SyncActiveProfile() TermRecorderConfig {
+	t.ActiveProfile = t.activeProfileName()
+	t.Profiles = cloneProfiles(t.Profiles)
+	t.Profiles[t.ActiveProfile] = t.activeServerProfile()
+	return t
+}
+
+// activeProfileName returns the name of the active profile, or the default profile if none is
+// active
+func (t TermRecorderConfig) activeProfileName() string {
+	if t.ActiveProfile == "" {
+		return DefaultProfileName
+	}
+	return t.ActiveProfile
+}
+
+// activeServerProfile collects the active server details into a ServerProfile
+func (t TermRecorderConfig) activeServerProfile() ServerProfile {
+	return ServerProfile{
+		APIURL:           t.APIURL,
+		AccessKey:        t.AccessKey,
+		SecretKey:        t.SecretKey,
+		SecretKeyFile:    t.SecretKeyFile,
+		SecretKeyCommand: t.SecretKeyCommand,
+		SecretKeyEnvOnly: t.SecretKeyEnvOnly,
+		OperationSlug:    t.OperationSlug,
+	}
+}
+
+// selectProfile determines which profile to use (CLI first, then environment, then config file),
+// and applies it. A missing profile is only an error if one was explicitly requested.
+func (t *TermRecorderConfig) selectProfile(cliProfile string) error {
+	name := cliProfile
+	if name == "" {
+		name = os.Getenv(profileEnvVar)
+	}
+	if name == "" {
+		name = t.ActiveProfile
+		if _, ok := t.Profiles[name]; !ok {
+			return nil
+		}
+	}
+	if err := t.UseProfile(name); err != nil {
+		return ErrProfileNotFound
+	}
+	return nil
+}
+
+func cloneProfiles(profiles map[string]ServerProfile) map[string]ServerProfile {
+	clone := make(map[string]ServerProfile, len(profiles))
+	for k, v := range profiles {
+		clone[k] = v
+	}
+	return clone
+}
+
+// OperationsCachePath points to where the list of operations for the given profile is cached
+func OperationsCachePath(profile string) string {
+	if profile == "" {
+		profile = DefaultProfileName
+	}
+	safeName := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, profile)
+	return filepath.Join(xdg.CacheHome(), "aterm", "operations-"+safeName+".json")
+}
+
+// WithProfile returns a copy of this configuration, switched to the named profile. If the profile
+// does not exist, a new, blank profile is created.
+func (t TermRecorderConfig) WithProfile(name string) TermRecorderConfig {
+	t.Profiles = cloneProfiles(t.Profiles)
+	if _, ok := t.Profiles[name]; !ok {
+		t.Profiles[name] = ServerProfile{}
+	}
+	t.UseProfile(name)
+	return t
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SettingMap holds a map setting. In the config file, it is written as a map. Elsewhere (i.e. in
+// environment variables, and via SetSetting) it is written as a comma-separated list of key=value
+// pairs (see parseEnvList).
+type SettingMap map[string]string
+
+// Decode parses the map from a comma-separated list of key=value pairs, for envconfig
+func (m *SettingMap) Decode(value string) error {
+	env, err := parseEnvList(value)
+	if err != nil {
+		return err
+	}
+	*m = env
+	return nil
+}
+
+func cloneEnv(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(env))
+	for k, v := range env {
+		clone[k] = v
+	}
+	return clone
+}
+
+// formatEnv renders the provided environment variables as a sorted, comma-separated list of
+// key=value pairs
+func formatEnv(env map[string]string) string {
+	pairs := make([]string, 0, len(env))
+	for k, v := range env {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// formatEnvList renders the provided map as a sorted, comma-separated list of key=value pairs, as
+// read by parseEnvList. Commas within pairs are escaped.
+func formatEnvList(env map[string]string) string {
+	pairs := make([]string, 0, len(env))
+	for k, v := range env {
+		pairs = append(pairs, strings.ReplaceAll(k+"="+v, ",", `\,`))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// parseEnvList parses a comma-separated list of key=value pairs (the reverse of formatEnvList).
+// Commas preceded by a backslash are part of the pair (e.g. for patterns such as `\d{1\,3}`); no
+// other escapes are recognized, so other backslashes are kept as-is.
+func parseEnvList(value string) (map[string]string, error) {
+	env := map[string]string{}
+	for _, pair := range splitUnescaped(value, ',') {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		env[kv[0]] = kv[1]
+	}
+	return env, nil
+}
+
+// splitUnescaped splits the value at each separator not preceded by a backslash. The backslash
+// is removed from escaped separators.
+func splitUnescaped(value string, sep byte) []string {
+	parts := []string{}
+	var part strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == sep:
+			part.WriteByte(sep)
+			i++
+		case value[i] == sep:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(value[i])
+		}
+	}
+	return append(parts, part.String())
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/aterm/errors"
+)
+
+// secretKeyEnvVar is the environment variable that provides the secret key. When set, this
+// takes priority over all other secret key sources.
+const secretKeyEnvVar = "ASHIRT_TERM_RECORDER_SECRET_KEY"
+
+// secretKeyCommandTimeout limits how long the secret key command may run. This is fairly generous,
+// as some password managers prompt the user to unlock their vault.
+const secretKeyCommandTimeout = 2 * time.Minute
+
+// usesExternalSecretKey returns true if the secret key for this profile is retrieved from
+// somewhere other than the config file
+func (p ServerProfile) usesExternalSecretKey() bool {
+	return p.SecretKeyEnvOnly || p.SecretKeyFile != "" || p.SecretKeyCommand != ""
+}
+
+// UsesExternalSecretKey returns true if the active secret key is retrieved from somewhere other
+// than the config file (i.e. from a file, a command, or only from the environment). In these
+// cases, the secret key is never written to the config file.
+func (t TermRecorderConfig) UsesExternalSecretKey() bool {
+	return t.activeServerProfile().usesExternalSecretKey()
+}
+
+// secretOrigin notes the secret key settings of a profile as read from the config file, and as
+// loaded, i.e. once the environment was applied and the secret key retrieved from its source.
+// Settings that still have their loaded value when the config is written are saved with their
+// value from the config file, so that values from the environment (or an external source) are
+// never saved.
+type secretOrigin struct {
+	profile      string
+	file, loaded ServerProfile
+}
+
+// restore returns the named profile, with any secret key settings that are unchanged since they
+// were loaded replaced by their value from the config file. Other profiles are returned as-is.
+func (o *secretOrigin) restore(name string, profile ServerProfile) ServerProfile {
+	if o == nil || name != o.profile {
+		return profile
+	}
+	if profile.SecretKey == o.loaded.SecretKey {
+		profile.SecretKey = o.file.SecretKey
+	}
+	if profile.SecretKeyFile == o.loaded.SecretKeyFile {
+		profile.SecretKeyFile = o.file.SecretKeyFile
+	}
+	if profile.SecretKeyCommand == o.loaded.SecretKeyCommand {
+		profile.SecretKeyCommand = o.file.SecretKeyCommand
+	}
+	if profile.SecretKeyEnvOnly == o.loaded.SecretKeyEnvOnly {
+		profile.SecretKeyEnvOnly = o.file.SecretKeyEnvOnly
+	}
+	return profile
+}
+
+// SecretKeySource describes where the active secret key is retrieved from
+func (t TermRecorderConfig) SecretKeySource() string {
+	switch {
+	case os.Getenv(secretKeyEnvVar) != "":
+		return "environment (" + secretKeyEnvVar + ")"
+	case t.SecretKeyEnvOnly:
+		return "environment only (" + secretKeyEnvVar + " is not set)"
+	case t.SecretKeyFile != "":
+		return "file (" + t.SecretKeyFile + ")"
+	case t.SecretKeyCommand != "":
+		return "command (" + t.SecretKeyCommand + ")"
+	}
+	return "config file"
+}
+
+// ResolveSecretKey retrieves the secret key from its configured source, replacing the current
+// SecretKey. The environment always takes priority; otherwise the secret key file or command
+// is used. If no external source is configured, the SecretKey is left as-is.
+func (t *TermRecorderConfig) ResolveSecretKey() error {
+	if envKey := os.Getenv(secretKeyEnvVar); envKey != "" {
+		t.SecretKey = envKey
+		return nil
+	}
+
+	var err error
+	switch {
+	case t.SecretKeyEnvOnly:
+		t.SecretKey = ""
+	case t.SecretKeyFile != "":
+		t.SecretKey, err = readSecretKeyFile(t.SecretKeyFile)
+	case t.SecretKeyCommand != "":
+		t.SecretKey, err = runSecretKeyCommand(t.SecretKeyCommand)
+	}
+	return err
+}
+
+// readSecretKeyFile reads the secret key from the given file. The file must only be accessible
+// by its owner (i.e. mode 0600 or stricter). This check is skipped on Windows, which does not
+// use unix-style permissions.
+func readSecretKeyFile(path string) (string, error) {
+	content, err := readOwnerOnlyFile(path, ErrSecretKeyFileInsecure)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to read secret key file")
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// readOwnerOnlyFile reads the given file, returning errInsecure if the file is accessible by
+// users other than its owner (skipped on Windows)
+func readOwnerOnlyFile(path string, errInsecure error) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, errors.Wrap(errInsecure,
+			fmt.Sprintf("%v has permissions %v (try: chmod 600 %v)", path, info.Mode().Perm(), path))
+	}
+	return ioutil.ReadFile(path)
+}
+
+// SigningKey reads the key used to sign recordings (see SigningKeyFile). The file must hold a PEM
+// encoded (PKCS #8) ed25519 private key, e.g. from "openssl genpkey -algorithm ed25519", and,
+// like the secret key file, must only be accessible by its owner. Returns nil if no signing key
+// is configured.
+func SigningKey() (ed25519.PrivateKey, error) {
+	path := loadedConfig.SigningKeyFile
+	if path == "" {
+		return nil, nil
+	}
+	content, err := readOwnerOnlyFile(path, ErrSigningKeyFileInsecure)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read signing key file")
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.Wrap(ErrSigningKeyInvalid, path+" is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(ErrSigningKeyInvalid, err.Error())
+	}
+	signingKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.Wrap(ErrSigningKeyInvalid, path+" is not an ed25519 key")
+	}
+	return signingKey, nil
+}
+
+// runSecretKeyCommand runs the given command via the system shell, and uses the first line of
+// its output as the secret key (matching tools like `pass show`, which place extra details on
+// subsequent lines)
+func runSecretKeyCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretKeyCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = os.Stdin // allows password managers to prompt for a passphrase
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.Wrap(ErrSecretKeyCommandFailed, msg)
+	}
+
+	firstLine := strings.SplitN(stdout.String(), "\n", 2)[0]
+	secretKey := strings.TrimSpace(firstLine)
+	if secretKey == "" {
+		return "", errors.Wrap(ErrSecretKeyCommandFailed, "Command did not print a secret key")
+	}
+	return secretKey, nil
+}
+
+// maskSecret hides the provided secret for display. Blank secrets are left blank, so that it is
+// still clear when a secret has not been set.
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretKeyFile(t *testing.T) {
+	t.Setenv(secretKeyEnvVar, "")
+	keyPath := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte("c2VjcmV0LWtleQ==\n"), 0600))
+
+	cfg := TermRecorderConfig{SecretKeyFile: keyPath}
+	require.NoError(t, cfg.ResolveSecretKey())
+	require.Equal(t, "c2VjcmV0LWtleQ==", cfg.SecretKey)
+}
+
+func TestSecretKeyFileMustBePrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not enforced on windows")
+	}
+	t.Setenv(secretKeyEnvVar, "")
+	keyPath := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte("c2VjcmV0LWtleQ=="), 0644))
+
+	cfg := TermRecorderConfig{SecretKeyFile: keyPath}
+	require.ErrorIs(t, cfg.ResolveSecretKey(), ErrSecretKeyFileInsecure)
+	require.Equal(t, "", cfg.SecretKey)
+}
+
+func TestSecretKeyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a unix shell")
+	}
+	t.Setenv(secretKeyEnvVar, "")
+
+	cfg := TermRecorderConfig{SecretKeyCommand: `printf 'c2VjcmV0LWtleQ==\nlogin: someone\n'`}
+	require.NoError(t, cfg.ResolveSecretKey())
+	require.Equal(t, "c2VjcmV0LWtleQ==", cfg.SecretKey)
+
+	cfg = TermRecorderConfig{SecretKeyCommand: "echo 'vault is locked' >&2; exit 1"}
+	err := cfg.ResolveSecretKey()
+	require.ErrorIs(t, err, ErrSecretKeyCommandFailed)
+	require.Contains(t, err.Error(), "vault is locked")
+
+	cfg = TermRecorderConfig{SecretKeyCommand: "true"}
+	require.ErrorIs(t, cfg.ResolveSecretKey(), ErrSecretKeyCommandFailed)
+}
+
+func TestSecretKeyEnvOnly(t *testing.T) {
+	t.Setenv(secretKeyEnvVar, "")
+	cfg := TermRecorderConfig{SecretKey: "from-config-file", SecretKeyEnvOnly: true}
+	require.NoError(t, cfg.ResolveSecretKey())
+	require.Equal(t, "", cfg.SecretKey)
+
+	t.Setenv(secretKeyEnvVar, "from-env")
+	require.NoError(t, cfg.ResolveSecretKey())
+	require.Equal(t, "from-env", cfg.SecretKey)
+}
+
+func TestExternalSecretKeyIsNotWritten(t *testing.T) {
+	t.Setenv(secretKeyEnvVar, "")
+	cfg := TermRecorderConfigWithDefaults()
+	cfg.APIURL = "http://localhost:3000"
+	cfg.SecretKey = "c2VjcmV0LWtleQ=="
+	cfg.SecretKeyCommand = "pass show ashirt"
+	cfg.Profiles = map[string]ServerProfile{
+		"plain": {APIURL: "http://example.com", SecretKey: "cGxhaW4="},
+	}
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	require.NoError(t, cfg.WriteConfigToFile(configPath))
+
+	content, err := ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "c2VjcmV0LWtleQ==")
+	require.Contains(t, string(content), "secretKeyCommand: pass show ashirt")
+	require.Contains(t, string(content), "cGxhaW4=", "profiles without an external source keep their secret key")
+}
+
+func TestSecretKeySettingsFromEnvAreNotWritten(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(secretKeyEnvVar, "")
+	configPath := ATermConfigPath()
+	cfg := TermRecorderConfigWithDefaults()
+	cfg.APIURL, cfg.SecretKey = "http://localhost:3000", "ZmlsZS1rZXk="
+	require.NoError(t, cfg.WriteConfigToFile(configPath))
+
+	t.Setenv(secretKeyEnvVar, "ZW52LWtleQ==")
+	t.Setenv("ASHIRT_TERM_RECORDER_SECRET_KEY_COMMAND", "pass show ashirt")
+	loaded, err := parseConfig("")
+	require.NoError(t, err)
+	require.Equal(t, "ZW52LWtleQ==", loaded.SecretKey)
+
+	require.NoError(t, loaded.WriteConfigToFile(configPath))
+	content, err := ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "ZmlsZS1rZXk=")
+	require.NotContains(t, string(content), "ZW52LWtleQ==")
+	require.NotContains(t, string(content), "secretKeyCommand")
+
+	// values entered by the user are saved
+	newKey := "bmV3LWtleQ=="
+	edited := PreviewUpdatedInstanceConfig(loaded, TermRecorderConfigOverrides{SecretKey: &newKey})
+	require.NoError(t, edited.WriteConfigToFile(configPath))
+	content, err = ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "bmV3LWtleQ==")
+	require.NotContains(t, string(content), "secretKeyCommand")
+}
+
+func TestPrintConfigMasksSecretKey(t *testing.T) {
+	t.Setenv(secretKeyEnvVar, "")
+	var buf bytes.Buffer
+	PrintConfigTo(TermRecorderConfig{SecretKey: "c2VjcmV0LWtleQ==", SecretKeyFile: "/home/user/.aterm-secret"}, &buf)
+
+	require.NotContains(t, buf.String(), "c2VjcmV0LWtleQ==")
+	require.Contains(t, buf.String(), "********")
+	require.Contains(t, buf.String(), "file (/home/user/.aterm-secret)")
+}
+
+func TestSigningKey(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "signing.pem")
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	defer SetConfig(loadedConfig)
+	SetConfig(TermRecorderConfig{})
+	key, err := SigningKey()
+	require.NoError(t, err)
+	require.Nil(t, key)
+	require.False(t, HashChain())
+
+	SetConfig(TermRecorderConfig{SigningKeyFile: keyPath})
+	key, err = SigningKey()
+	require.NoError(t, err)
+	require.Equal(t, publicKey, key.Public())
+	require.False(t, HashChain(), "the signing key is only used once hashChain is enabled")
+
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte("not a key"), 0600))
+	_, err = SigningKey()
+	require.ErrorIs(t, err, ErrSigningKeyInvalid)
+}
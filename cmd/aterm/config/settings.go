@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/aterm/errors"
+)
+
+// unsettableSettings are config file fields that cannot be read or changed individually
+var unsettableSettings = map[string]bool{
+	"configVersion": true,
+	"profiles":      true,
+}
+
+// SettingNames lists the name of every setting that can be read or changed via GetSetting and
+// SetSetting. Names match those used in the config file.
+func SettingNames() []string {
+	names := []string{}
+	forEachSetting(reflect.ValueOf(&TermRecorderConfig{}).Elem(), func(name string, _ reflect.Value) {
+		names = append(names, name)
+	})
+	return names
+}
+
+// GetSetting returns the current value of the named setting, formatted as it would be written in
+// the config file. Lists are comma-separated, and maps are written as comma-separated key=value
+// pairs, with any commas within a pair escaped with a backslash (e.g. `pattern=\d{1\,3}`)
+func (t TermRecorderConfig) GetSetting(name string) (string, error) {
+	field, err := findSetting(reflect.ValueOf(&t).Elem(), name)
+	if err != nil {
+		return "", err
+	}
+	switch v := field.Interface().(type) {
+	case time.Duration:
+		return v.String(), nil
+	case []string:
+		return strings.Join(v, ","), nil
+	case SettingMap:
+		return formatEnvList(v), nil
+	}
+	return fmt.Sprint(field.Interface()), nil
+}
+
+// SetSetting parses and applies the provided value to the named setting (see GetSetting for the
+// format of lists and maps). Server details (e.g.
+// apiURL) apply to the active profile. Setting activeProfile switches to that (existing) profile.
+func (t *TermRecorderConfig) SetSetting(name, value string) error {
+	if name == "activeProfile" {
+		synced := t.SyncActiveProfile()
+		if err := synced.UseProfile(value); err != nil {
+			return err
+		}
+		*t = synced
+		return nil
+	}
+
+	field, err := findSetting(reflect.ValueOf(t).Elem(), name)
+	if err != nil {
+		return err
+	}
+	invalidValue := func(err error) error {
+		return errors.Wrap(err, fmt.Sprintf("Invalid value for %v: %q", name, value))
+	}
+
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalidValue(err)
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return invalidValue(err)
+		}
+		field.SetInt(int64(d))
+	case int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return invalidValue(err)
+		}
+		field.SetInt(i)
+	case []string:
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case SettingMap:
+		var env SettingMap
+		if err := env.Decode(value); err != nil {
+			return invalidValue(err)
+		}
+		field.Set(reflect.ValueOf(env))
+	default:
+		return errors.New("Unable to set " + name)
+	}
+	return nil
+}
+
+func findSetting(cfg reflect.Value, name string) (reflect.Value, error) {
+	var found reflect.Value
+	forEachSetting(cfg, func(settingName string, field reflect.Value) {
+		if settingName == name {
+			found = field
+		}
+	})
+	if !found.IsValid() {
+		return found, errors.Wrap(ErrUnknownSetting, name)
+	}
+	return found, nil
+}
+
+// forEachSetting calls fn for each settable field of the provided TermRecorderConfig value,
+// along with the name of that field in the config file
+func forEachSetting(cfg reflect.Value, fn func(name string, field reflect.Value)) {
+	cfgType := cfg.Type()
+	for i := 0; i < cfgType.NumField(); i++ {
+		name := strings.Split(cfgType.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" || unsettableSettings[name] {
+			continue
+		}
+		fn(name, cfg.Field(i))
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingNames(t *testing.T) {
+	names := SettingNames()
+	require.Contains(t, names, "apiURL")
+	require.Contains(t, names, "requestTimeout")
+	require.Contains(t, names, "insecureSkipVerify")
+	require.NotContains(t, names, "configVersion")
+	require.NotContains(t, names, "profiles")
+}
+
+func TestGetAndSetSettings(t *testing.T) {
+	cfg := TermRecorderConfigWithDefaults()
+
+	require.NoError(t, cfg.SetSetting("apiURL", "http://ashirt.example.com"))
+	require.NoError(t, cfg.SetSetting("maxRetries", "5"))
+	require.NoError(t, cfg.SetSetting("requestTimeout", "1m"))
+	require.NoError(t, cfg.SetSetting("insecureSkipVerify", "true"))
+
+	require.Equal(t, "http://ashirt.example.com", cfg.APIURL)
+	require.Equal(t, int64(5), cfg.MaxRetries)
+	require.Equal(t, time.Minute, cfg.RequestTimeout)
+	require.True(t, cfg.InsecureSkipVerify)
+
+	value, err := cfg.GetSetting("requestTimeout")
+	require.NoError(t, err)
+	require.Equal(t, "1m0s", value)
+
+	require.NoError(t, cfg.SetSetting("headerEnv", "LANG, USER"))
+	require.NoError(t, cfg.SetSetting("recordingEnv", "HISTFILE=/dev/null,FOO=a=b"))
+	require.Equal(t, []string{"LANG", "USER"}, cfg.HeaderEnv)
+	require.Equal(t, SettingMap{"HISTFILE": "/dev/null", "FOO": "a=b"}, cfg.RecordingEnv)
+	value, err = cfg.GetSetting("recordingEnv")
+	require.NoError(t, err)
+	require.Equal(t, "FOO=a=b,HISTFILE=/dev/null", value)
+	require.Error(t, cfg.SetSetting("recordingEnv", "novalue"))
+
+	// commas within patterns are escaped; other backslashes are kept
+	require.NoError(t, cfg.SetSetting("tagRules", `ports=\d{1\,5}/tcp, recon=nmap|masscan`))
+	require.Equal(t, SettingMap{"ports": `\d{1,5}/tcp`, "recon": "nmap|masscan"}, cfg.TagRules)
+	value, err = cfg.GetSetting("tagRules")
+	require.NoError(t, err)
+	require.Equal(t, `ports=\d{1\,5}/tcp,recon=nmap|masscan`, value)
+	require.NoError(t, cfg.SetSetting("tagRules", value))
+	require.Equal(t, SettingMap{"ports": `\d{1,5}/tcp`, "recon": "nmap|masscan"}, cfg.TagRules)
+
+	require.Error(t, cfg.SetSetting("maxRetries", "lots"))
+	require.ErrorIs(t, cfg.SetSetting("nope", "1"), ErrUnknownSetting)
+	_, err = cfg.GetSetting("nope")
+	require.ErrorIs(t, err, ErrUnknownSetting)
+}
+
+func TestMapSettingsFromEnv(t *testing.T) {
+	// maps use the same key=value syntax in env vars as with SetSetting
+	t.Setenv("ASHIRT_TERM_RECORDER_RECORDING_ENV", "HISTFILE=/dev/null,LANG=C")
+	t.Setenv("ASHIRT_TERM_RECORDER_TAG_RULES", `ports=\d{1\,5}/tcp`)
+	cfg := TermRecorderConfigWithDefaults()
+	require.NoError(t, cfg.parseEnv())
+	require.Equal(t, SettingMap{"HISTFILE": "/dev/null", "LANG": "C"}, cfg.RecordingEnv)
+	require.Equal(t, SettingMap{"ports": `\d{1,5}/tcp`}, cfg.TagRules)
+
+	t.Setenv("ASHIRT_TERM_RECORDER_RECORDING_ENV", "HISTFILE:/dev/null")
+	require.Error(t, cfg.parseEnv())
+}
+
+func TestSetActiveProfile(t *testing.T) {
+	cfg := TermRecorderConfigWithDefaults()
+	cfg.APIURL = "http://default.example.com"
+	cfg.Profiles = map[string]ServerProfile{"training": {APIURL: "http://training.example.com"}}
+
+	require.NoError(t, cfg.SetSetting("activeProfile", "training"))
+	require.Equal(t, "http://training.example.com", cfg.APIURL)
+	require.Equal(t, "http://default.example.com", cfg.Profiles[DefaultProfileName].APIURL)
+
+	require.ErrorIs(t, cfg.SetSetting("activeProfile", "missing"), ErrProfileNotFound)
+	require.Equal(t, "training", cfg.ActiveProfile)
+}
@@ -0,0 +1,316 @@
+package headless
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+// The kinds of evidence that can be added with AddEvidence
+const (
+	// EvidenceFile uploads an existing file. Images are uploaded as screenshots, recordings as
+	// terminal recordings, and any other text as a code block.
+	EvidenceFile = "file"
+	// EvidenceCode uploads the provided input as a code block
+	EvidenceCode = "code"
+	// EvidenceNote uploads a description, without any content
+	EvidenceNote = "note"
+	// EvidenceOutput uploads part of a recording's output, rendered as text, as a code block
+	EvidenceOutput = "output"
+)
+
+// EvidenceOptions controls AddEvidence
+type EvidenceOptions struct {
+	Kind string
+	// FilePath is the file to upload (for EvidenceFile), or the recording to take output from (for
+	// EvidenceOutput)
+	FilePath string
+	// Start and End select the output to upload (for EvidenceOutput), as offsets into the recording
+	// (see recording.ParseOffset). A blank End selects the rest of the recording.
+	Start, End string
+	// Marker selects the output between a marker and the next marker (for EvidenceOutput), in place
+	// of Start and End. See recording.MarkerSpan.
+	Marker string
+	// Language is the code block language (e.g. python). For files, this is normally guessed from
+	// the file extension; providing a language uploads the file as a code block.
+	Language string
+	// Source notes where a code block came from (e.g. a URL or command)
+	Source        string
+	Description   string
+	Tags          []string
+	CreateTags    bool
+	OperationSlug string
+	Profile       string
+}
+
+// EvidenceDetails are the details of new evidence that may be reviewed (via EvidencePrompter)
+// before the evidence is uploaded
+type EvidenceDetails struct {
+	OperationSlug string
+	Description   string
+	Tags          []dtos.Tag
+}
+
+// EvidencePrompter asks the user to review (and complete) the details of new evidence. The
+// operation may be blank, in which case one should be chosen. Returning context.Canceled stops the
+// upload.
+type EvidencePrompter func(EvidenceDetails) (EvidenceDetails, error)
+
+// maxCodeblockSize limits the size of code blocks, which are held in memory to encode them
+const maxCodeblockSize = 10 << 20
+
+// imageExtensions lists the file extensions that are uploaded as screenshots
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true}
+
+// codeLanguages maps file extensions to the languages used for ASHIRT code blocks
+var codeLanguages = map[string]string{
+	".bash": "sh", ".c": "c_cpp", ".cpp": "c_cpp", ".cs": "csharp", ".css": "css", ".go": "golang",
+	".h": "c_cpp", ".html": "html", ".java": "java", ".js": "javascript", ".json": "json",
+	".md": "markdown", ".php": "php", ".pl": "perl", ".ps1": "powershell", ".py": "python",
+	".rb": "ruby", ".rs": "rust", ".sh": "sh", ".sql": "sql", ".ts": "typescript", ".xml": "xml",
+	".yaml": "yaml", ".yml": "yaml", ".zsh": "sh",
+}
+
+// evidenceContent is the content to upload for some evidence (see network.UploadInput), along with
+// any defaults found for the evidence's details
+type evidenceContent struct {
+	contentType   string
+	filename      string
+	content       io.ReadSeeker
+	operationSlug string
+	description   string
+}
+
+// AddEvidence uploads a file, code block (read from input), note or recording excerpt, per
+// opts.Kind, and writes the result. If a prompter is provided, the user may review the operation,
+// description and tags before the upload; otherwise, no prompts are shown, and the operation must
+// be provided (or configured). Uploads can be cancelled with ^C.
+func AddEvidence(opts EvidenceOptions, input io.Reader, prompt EvidencePrompter, out io.Writer) int {
+	result := Result{FilePath: opts.FilePath}
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile, OperationSlug: opts.OperationSlug}); err != nil {
+		return fail(out, result, ExitConfig, err)
+	}
+	if err := configureNetwork(); err != nil {
+		return fail(out, result, ExitConfig, err)
+	}
+
+	evidence, err := readEvidenceContent(opts, input)
+	if err != nil {
+		return fail(out, result, ExitUsage, err)
+	}
+	if closer, ok := evidence.content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	result.ContentType = evidence.contentType
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	details := EvidenceDetails{
+		OperationSlug: firstNonBlank(opts.OperationSlug, evidence.operationSlug, config.OperationSlug()),
+		Description:   firstNonBlank(opts.Description, evidence.description),
+		Tags:          []dtos.Tag{},
+	}
+	tagsResolvedFor := ""
+	if len(opts.Tags) > 0 && details.OperationSlug != "" {
+		if details.Tags, err = resolveTags(ctx, details.OperationSlug, opts.Tags, nil, opts.CreateTags); err != nil {
+			return fail(out, result, exitCodeFor(err), err)
+		}
+		tagsResolvedFor = details.OperationSlug
+	}
+	if prompt != nil {
+		if details, err = prompt(details); err != nil {
+			return fail(out, result, exitCodeFor(err), err)
+		}
+	}
+
+	result.OperationSlug = details.OperationSlug
+	if details.OperationSlug == "" {
+		return fail(out, result, ExitUsage, errors.New("No operation specified (use --operation)"))
+	}
+	if len(opts.Tags) > 0 && tagsResolvedFor != details.OperationSlug {
+		// the operation was only chosen while prompting, so the requested tags are added now
+		requested, err := resolveTags(ctx, details.OperationSlug, opts.Tags, nil, opts.CreateTags)
+		if err != nil {
+			return fail(out, result, exitCodeFor(err), err)
+		}
+		for _, tag := range requested {
+			if _, ok := findTag(details.Tags, tag.Name); !ok {
+				details.Tags = append(details.Tags, tag)
+			}
+		}
+	}
+	result.Description = details.Description
+	result.Tags = tagNames(details.Tags)
+	if details.Description == "" && opts.Kind == EvidenceNote {
+		return fail(out, result, ExitUsage, errors.New("Notes require a description (use --description)"))
+	}
+
+	uploaded, err := network.UploadToAshirtWithContext(ctx, network.UploadInput{
+		OperationSlug: details.OperationSlug,
+		Description:   details.Description,
+		ContentType:   evidence.contentType,
+		Filename:      evidence.filename,
+		TagIDs:        tagIDs(details.Tags),
+		Content:       evidence.content,
+	})
+	if err != nil {
+		return fail(out, result, exitCodeFor(err), errors.Wrap(err, "Unable to upload evidence"))
+	}
+	if uploaded != nil {
+		result.EvidenceUUID = uploaded.UUID
+	}
+
+	result.Status = StatusUploaded
+	result.ExitCode = ExitSuccess
+	return finish(out, result)
+}
+
+// readEvidenceContent prepares the content to upload for the requested kind of evidence
+func readEvidenceContent(opts EvidenceOptions, input io.Reader) (evidenceContent, error) {
+	switch opts.Kind {
+	case EvidenceNote:
+		return evidenceContent{contentType: network.ContentTypeNone}, nil
+
+	case EvidenceCode:
+		code, err := readLimited(input)
+		if err != nil {
+			return evidenceContent{}, errors.Wrap(err, "Unable to read code block")
+		}
+		return newCodeblock(code, opts.Language, opts.Source, "codeblock.json")
+
+	case EvidenceFile:
+		return readEvidenceFile(opts)
+
+	case EvidenceOutput:
+		return readOutputExcerpt(opts)
+	}
+	return evidenceContent{}, fmt.Errorf("Unknown kind of evidence: %q", opts.Kind)
+}
+
+// readEvidenceFile determines how to upload the requested file. Images and recordings are streamed
+// from disk as-is, while text is read into a code block.
+func readEvidenceFile(opts EvidenceOptions) (evidenceContent, error) {
+	ext := strings.ToLower(filepath.Ext(opts.FilePath))
+	contentType := ""
+	if opts.Language == "" {
+		if imageExtensions[ext] {
+			contentType = network.ContentTypeScreenshot
+		} else if ext == ".cast" {
+			contentType = network.ContentTypeTerminalRecording
+		}
+	}
+
+	f, err := os.Open(opts.FilePath)
+	if err != nil {
+		return evidenceContent{}, errors.Wrap(err, "Unable to read evidence file")
+	}
+	if contentType != "" {
+		return evidenceContent{contentType: contentType, filename: filepath.Base(opts.FilePath), content: f}, nil
+	}
+
+	defer f.Close()
+	text, err := readLimited(f)
+	if err != nil {
+		return evidenceContent{}, errors.Wrap(err, "Unable to read evidence file")
+	}
+	if !utf8.Valid(text) || bytes.IndexByte(text, 0) != -1 {
+		return evidenceContent{}, fmt.Errorf("Unable to upload %v: only images, recordings and text files are supported", opts.FilePath)
+	}
+	language := opts.Language
+	if language == "" {
+		language = codeLanguages[ext]
+	}
+	source := firstNonBlank(opts.Source, filepath.Base(opts.FilePath))
+	return newCodeblock(text, language, source, filepath.Base(opts.FilePath))
+}
+
+// readOutputExcerpt renders the requested part of a recording's output as a code block. The
+// recording's operation is used by default, as is the marker's label (e.g. the command) when a
+// marker is requested.
+func readOutputExcerpt(opts EvidenceOptions) (evidenceContent, error) {
+	var span recording.OutputSpan
+	description := ""
+	if opts.Marker != "" {
+		if opts.Start != "" || opts.End != "" {
+			return evidenceContent{}, errors.New("Provide either a marker, or a start and end, but not both")
+		}
+		markers, err := recording.ReadMarkers(opts.FilePath)
+		if err != nil {
+			return evidenceContent{}, errors.Wrap(err, "Unable to read recording")
+		}
+		var marker recording.RecordingMarker
+		if span, marker, err = recording.MarkerSpan(markers, opts.Marker); err != nil {
+			return evidenceContent{}, err
+		}
+		description = marker.Label
+	} else {
+		var err error
+		if span.Start, err = recording.ParseOffset(firstNonBlank(opts.Start, "0")); err != nil {
+			return evidenceContent{}, err
+		}
+		if opts.End != "" {
+			if span.End, err = recording.ParseOffset(opts.End); err != nil {
+				return evidenceContent{}, err
+			}
+			if span.End <= span.Start {
+				return evidenceContent{}, errors.New("The end of the excerpt must be after its start")
+			}
+		}
+	}
+
+	text, err := recording.RenderOutput(opts.FilePath, span)
+	if err != nil {
+		return evidenceContent{}, errors.Wrap(err, "Unable to read recording")
+	}
+	if text == "" {
+		return evidenceContent{}, fmt.Errorf("No output found between %v", span)
+	}
+	source := fmt.Sprintf("%v (%v)", filepath.Base(opts.FilePath), span)
+	content, err := newCodeblock([]byte(text), opts.Language, source, filepath.Base(opts.FilePath)+".txt")
+	if err != nil {
+		return content, err
+	}
+	if metadata, err := recording.LoadMetadata(opts.FilePath); err == nil {
+		content.operationSlug = metadata.OperationSlug
+	}
+	content.description = description
+	return content, nil
+}
+
+// newCodeblock prepares a code block for upload
+func newCodeblock(code []byte, language, source, filename string) (evidenceContent, error) {
+	if len(bytes.TrimSpace(code)) == 0 {
+		return evidenceContent{}, errors.New("The code block is empty")
+	}
+	encoded, err := network.Codeblock{Language: language, Content: string(code), Source: source}.Encode()
+	if err != nil {
+		return evidenceContent{}, err
+	}
+	return evidenceContent{contentType: network.ContentTypeCodeblock, filename: filename, content: encoded}, nil
+}
+
+// readLimited reads all of the input, failing if the input is larger than maxCodeblockSize
+func readLimited(input io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(input, maxCodeblockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCodeblockSize {
+		return nil, fmt.Errorf("Code blocks are limited to %v MB", maxCodeblockSize>>20)
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/network"
+)
+
+func runAddEvidence(t *testing.T, opts EvidenceOptions, input io.Reader, prompt EvidencePrompter) (int, Result) {
+	var out bytes.Buffer
+	code := AddEvidence(opts, input, prompt, &out)
+	var result Result
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Equal(t, code, result.ExitCode)
+	return code, result
+}
+
+func TestAddEvidenceCode(t *testing.T) {
+	fake := useFakeServer(t)
+
+	code, result := runAddEvidence(t, EvidenceOptions{
+		Kind:          EvidenceCode,
+		Language:      "python",
+		Source:        "exploit.py",
+		Description:   "exploit",
+		Tags:          []string{"recon"},
+		OperationSlug: "op",
+	}, strings.NewReader("print('hi')\n"), nil)
+
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, network.ContentTypeCodeblock, result.ContentType)
+	require.Equal(t, []string{network.ContentTypeCodeblock}, fake.uploadForm["contentType"])
+	require.Equal(t, []string{"[1]"}, fake.uploadForm["tagIds"])
+	require.JSONEq(t, `{"contentSubtype": "python", "content": "print('hi')\n", "metadata": {"source": "exploit.py"}}`, string(fake.uploadFile))
+}
+
+func TestAddEvidenceFile(t *testing.T) {
+	fake := useFakeServer(t)
+	dir := t.TempDir()
+
+	scriptPath := filepath.Join(dir, "scan.sh")
+	require.NoError(t, ioutil.WriteFile(scriptPath, []byte("nmap -sV host\n"), 0600))
+	code, result := runAddEvidence(t, EvidenceOptions{Kind: EvidenceFile, FilePath: scriptPath, OperationSlug: "op"}, nil, nil)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, network.ContentTypeCodeblock, result.ContentType)
+	require.JSONEq(t, `{"contentSubtype": "sh", "content": "nmap -sV host\n", "metadata": {"source": "scan.sh"}}`, string(fake.uploadFile))
+
+	imagePath := filepath.Join(dir, "shot.PNG")
+	require.NoError(t, ioutil.WriteFile(imagePath, []byte("\x89PNG"), 0600))
+	code, result = runAddEvidence(t, EvidenceOptions{Kind: EvidenceFile, FilePath: imagePath, OperationSlug: "op"}, nil, nil)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, network.ContentTypeScreenshot, result.ContentType)
+	require.Equal(t, []byte("\x89PNG"), fake.uploadFile)
+
+	binaryPath := filepath.Join(dir, "a.out")
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte{0x7f, 'E', 'L', 'F', 0}, 0600))
+	code, _ = runAddEvidence(t, EvidenceOptions{Kind: EvidenceFile, FilePath: binaryPath, OperationSlug: "op"}, nil, nil)
+	require.Equal(t, ExitUsage, code)
+}
+
+func TestAddEvidenceNote(t *testing.T) {
+	fake := useFakeServer(t)
+
+	code, _ := runAddEvidence(t, EvidenceOptions{Kind: EvidenceNote, OperationSlug: "op"}, nil, nil)
+	require.Equal(t, ExitUsage, code)
+
+	code, result := runAddEvidence(t, EvidenceOptions{Kind: EvidenceNote, OperationSlug: "op", Description: "found creds"}, nil, nil)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "evidence-uuid", result.EvidenceUUID)
+	require.Equal(t, []string{network.ContentTypeNone}, fake.uploadForm["contentType"])
+	require.Equal(t, []string{"found creds"}, fake.uploadForm["notes"])
+	require.Nil(t, fake.uploadFile)
+}
+
+func TestAddEvidencePrompt(t *testing.T) {
+	fake := useFakeServer(t)
+
+	var prompted EvidenceDetails
+	code, result := runAddEvidence(t, EvidenceOptions{Kind: EvidenceNote, Tags: []string{"Recon"}}, nil,
+		func(details EvidenceDetails) (EvidenceDetails, error) {
+			prompted = details
+			details.OperationSlug = "op"
+			details.Description = "from prompt"
+			return details, nil
+		})
+
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "", prompted.OperationSlug)
+	require.Equal(t, "op", result.OperationSlug)
+	require.Equal(t, []string{"Recon"}, result.Tags) // resolved once the operation was chosen
+	require.Equal(t, []string{"from prompt"}, fake.uploadForm["notes"])
+}
+
+func TestAddEvidenceOutput(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(
+		"{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":1000}\n"+
+			"[1,\"o\",\"$ whoami\\r\\n\"]\n"+
+			"[1,\"m\",\"whoami\"]\n"+
+			"[2,\"o\",\"\\u001b[1mroot\\u001b[0m\\r\\n\"]\n"+
+			"[3,\"m\",\"exit 0\"]\n"+
+			"[3,\"o\",\"$ \"]\n"), 0600))
+	require.NoError(t, recording.SaveMetadata(recording.RecordingMetadata{FilePath: castPath, OperationSlug: "op"}))
+
+	code, result := runAddEvidence(t, EvidenceOptions{Kind: EvidenceOutput, FilePath: castPath, Marker: "whoami"}, nil, nil)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "op", result.OperationSlug)
+	require.Equal(t, "whoami", result.Description)
+	require.JSONEq(t, `{"contentSubtype": "", "content": "$ whoami\nroot\n$", "metadata": {"source": "rec.cast (0:01-0:03)"}}`, string(fake.uploadFile))
+
+	code, _ = runAddEvidence(t, EvidenceOptions{Kind: EvidenceOutput, FilePath: castPath, Start: "0:02", End: "1"}, nil, nil)
+	require.Equal(t, ExitUsage, code)
+	code, _ = runAddEvidence(t, EvidenceOptions{Kind: EvidenceOutput, FilePath: castPath, Start: "10"}, nil, nil)
+	require.Equal(t, ExitUsage, code)
+}
@@ -0,0 +1,105 @@
+// Package headless provides the non-interactive (scriptable) versions of aterm's actions. Each
+// action writes a single JSON object describing the outcome to its output, and returns an exit
+// code describing the outcome (see the Exit* constants). No menus or prompts are shown, unless the
+// caller supplies them (see AddEvidence).
+package headless
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+// Exit codes returned by the headless actions
+const (
+	// ExitSuccess indicates that the action completed
+	ExitSuccess = 0
+	// ExitFailure indicates an unexpected failure (e.g. a recording could not be written)
+	ExitFailure = 1
+	// ExitUsage indicates that the action was given bad input (e.g. a missing file, unknown tag)
+	ExitUsage = 2
+	// ExitConfig indicates that the configuration is missing, unreadable, or invalid
+	ExitConfig = 3
+	// ExitConnection indicates that the ASHIRT server could not be reached, or rejected the request
+	ExitConnection = 4
+	// ExitCancelled indicates that the user interrupted the action (i.e. ^C). This matches the
+	// exit code used by shells for SIGINT
+	ExitCancelled = 130
+)
+
+// Result is the JSON document written at the end of every headless action
+type Result struct {
+	Status          string   `json:"status"`
+	ExitCode        int      `json:"exitCode"`
+	Error           string   `json:"error,omitempty"`
+	FilePath        string   `json:"filePath,omitempty"`
+	ContentType     string   `json:"contentType,omitempty"`
+	OperationSlug   string   `json:"operationSlug,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Title           string   `json:"title,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	SuggestedTags   []string `json:"suggestedTags,omitempty"`
+	EvidenceUUID    string   `json:"evidenceUuid,omitempty"`
+	DuplicateOf     []string `json:"duplicateOf,omitempty"`
+	CommandExitCode *int     `json:"commandExitCode,omitempty"`
+}
+
+// Result statuses
+const (
+	StatusRecorded = "recorded"
+	StatusUploaded = "uploaded"
+	StatusUpdated  = "updated"
+	StatusDeleted  = "deleted"
+	StatusError    = "error"
+)
+
+// finish writes the result to the provided writer, and returns the exit code for the result
+func finish(out io.Writer, result Result) int {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+	return result.ExitCode
+}
+
+// fail writes an error result to the provided writer, and returns the provided exit code
+func fail(out io.Writer, result Result, exitCode int, err error) int {
+	result.Status = StatusError
+	result.ExitCode = exitCode
+	result.Error = err.Error()
+	return finish(out, result)
+}
+
+// loadConfig parses the configuration, without falling back to the first run prompts. A missing
+// config file is acceptable, provided the environment supplies the necessary details.
+func loadConfig(opts config.CLIOptions) error {
+	err := config.ParseConfig(opts)
+	if err != nil && !errors.Is(err, config.ErrConfigFileDoesNotExist) {
+		return err
+	}
+	return nil
+}
+
+// configureNetwork readies the network package to talk to the configured ASHIRT server
+func configureNetwork() error {
+	if err := config.ValidateLoadedConfig(); err != nil {
+		return err
+	}
+	if err := network.ConfigureTransport(config.TransportOptions()); err != nil {
+		return err
+	}
+	network.SetBaseURL(config.APIURL())
+	network.SetAccessKey(config.AccessKey())
+	network.SetRequestTimeout(config.RequestTimeout())
+	network.SetUploadTimeout(config.UploadTimeout())
+	network.SetMaxRetries(int(config.MaxRetries()))
+	network.SetRetryDelay(config.RetryDelay())
+	return nil
+}
+
+// UsageError reports an error in the provided command line arguments, returning ExitUsage
+func UsageError(out io.Writer, err error) int {
+	return fail(out, Result{}, ExitUsage, err)
+}
@@ -0,0 +1,137 @@
+package headless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/dialog"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+// ListOptions controls the output of the List* actions
+type ListOptions struct {
+	// JSON writes the list as a JSON array, rather than a table. Errors are also written as JSON
+	// (see Result)
+	JSON bool
+	// Profile is the server profile to use (or blank for the configured profile)
+	Profile string
+	// OperationSlug is the operation to list tags for, or to filter recordings by
+	OperationSlug string
+}
+
+// ListOperations writes the operations available on the server
+func ListOperations(opts ListOptions, out io.Writer) int {
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile}); err != nil {
+		return listFailed(out, opts, ExitConfig, err)
+	}
+	if err := configureNetwork(); err != nil {
+		return listFailed(out, opts, ExitConfig, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ops, err := network.GetOperationsWithContext(ctx)
+	if err != nil {
+		return listFailed(out, opts, exitCodeFor(err), errors.Wrap(err, "Unable to get operations"))
+	}
+
+	if opts.JSON {
+		return writeJSONList(out, ops)
+	}
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SLUG\tNAME")
+	for _, op := range ops {
+		fmt.Fprintf(table, "%v\t%v\n", op.Slug, op.Name)
+	}
+	table.Flush()
+	return ExitSuccess
+}
+
+// ListTags writes the tags for the requested (or configured) operation
+func ListTags(opts ListOptions, out io.Writer) int {
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile, OperationSlug: opts.OperationSlug}); err != nil {
+		return listFailed(out, opts, ExitConfig, err)
+	}
+	if err := configureNetwork(); err != nil {
+		return listFailed(out, opts, ExitConfig, err)
+	}
+	operationSlug := config.OperationSlug()
+	if operationSlug == "" {
+		return listFailed(out, opts, ExitUsage, errors.New("No operation specified (use --operation)"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	tags, err := network.GetTagsWithContext(ctx, operationSlug)
+	if err != nil {
+		return listFailed(out, opts, exitCodeFor(err), errors.Wrap(err, "Unable to get tags"))
+	}
+
+	if opts.JSON {
+		return writeJSONList(out, tags)
+	}
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tCOLOR")
+	for _, tag := range tags {
+		fmt.Fprintf(table, "%v\t%v\t%v\n", tag.ID, tag.Name, tag.ColorName)
+	}
+	table.Flush()
+	return ExitSuccess
+}
+
+// ListRecordings writes the recordings found in the configured output directory, optionally
+// filtered to a single operation
+func ListRecordings(opts ListOptions, out io.Writer) int {
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile}); err != nil {
+		return listFailed(out, opts, ExitConfig, err)
+	}
+	all, err := recording.FindRecordings(config.OutputDir())
+	if err != nil {
+		return listFailed(out, opts, ExitFailure, errors.Wrap(err, "Unable to list recordings"))
+	}
+
+	recordings := make([]recording.RecordingSummary, 0, len(all))
+	for _, r := range all {
+		if opts.OperationSlug == "" || opts.OperationSlug == r.OperationSlug {
+			recordings = append(recordings, r)
+		}
+	}
+
+	if opts.JSON {
+		return writeJSONList(out, recordings)
+	}
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "MODIFIED\tOPERATION\tUPLOADED\tSIZE\tFILE")
+	for _, r := range recordings {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n",
+			r.ModifiedAt.Format("2006-01-02 15:04"), r.OperationSlug, r.Uploaded, dialog.FormatBytes(r.Size), r.FilePath)
+	}
+	table.Flush()
+	return ExitSuccess
+}
+
+func writeJSONList(out io.Writer, list interface{}) int {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(list); err != nil {
+		return ExitFailure
+	}
+	return ExitSuccess
+}
+
+// listFailed reports the error as a JSON result, or as plain text on stderr, as appropriate
+func listFailed(out io.Writer, opts ListOptions, exitCode int, err error) int {
+	if opts.JSON {
+		return fail(out, Result{}, exitCode, err)
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	return exitCode
+}
@@ -0,0 +1,49 @@
+package headless
+
+import (
+	"io"
+	"os"
+
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+)
+
+// Record starts a recording for the configured operation, and returns once the recording shell (or
+// command, if provided) exits. When recording a command, the command's exit code is returned once
+// the recording is saved, rather than ExitSuccess. Status messages are written to stderr, so that
+// the result is the only content written to out after the recording finishes. The recording
+// metadata is saved alongside the recording, so that it can be uploaded later (see Upload).
+func Record(opts config.CLIOptions, out io.Writer) int {
+	var result Result
+	if err := loadConfig(opts); err != nil {
+		return fail(out, result, ExitConfig, err)
+	}
+
+	result.OperationSlug = config.OperationSlug()
+	if result.OperationSlug == "" {
+		return fail(out, result, ExitUsage, errors.New("No operation specified (use --operation)"))
+	}
+
+	recording.InitializeRecordings()
+	output, err := recording.StartCommandRecording(result.OperationSlug, opts.Command, opts.Title, os.Stderr)
+	result.FilePath = output.FilePath
+	if err != nil {
+		return fail(out, result, ExitFailure, err)
+	}
+
+	metadata := recording.RecordingMetadata{OperationSlug: result.OperationSlug}
+	metadata.ApplyOutput(output)
+	if err := recording.SaveMetadata(metadata); err != nil {
+		return fail(out, result, ExitFailure, errors.Wrap(err, "Unable to save recording metadata"))
+	}
+
+	result.Title = metadata.Title
+	result.Status = StatusRecorded
+	result.ExitCode = ExitSuccess
+	if len(opts.Command) > 0 {
+		result.CommandExitCode = &output.ExitCode
+		result.ExitCode = output.ExitCode
+	}
+	return finish(out, result)
+}
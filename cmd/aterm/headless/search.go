@@ -0,0 +1,59 @@
+package headless
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+)
+
+// SearchOptions controls how local recordings are searched
+type SearchOptions struct {
+	ListOptions
+	// Rebuild re-reads every recording, rather than only those changed since the last search
+	Rebuild bool
+}
+
+// Search writes the local recordings that contain the query, along with when each match occurred.
+// The recording index is brought up to date first (see recording.UpdateIndex). Recordings that
+// cannot be indexed are reported on stderr, but do not prevent the search.
+func Search(query string, opts SearchOptions, out io.Writer) int {
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile}); err != nil {
+		return listFailed(out, opts.ListOptions, ExitConfig, err)
+	}
+	index, err := recording.UpdateIndex(config.OutputDir(), opts.Rebuild)
+	if err != nil {
+		if index.Recordings == nil {
+			return listFailed(out, opts.ListOptions, ExitFailure, errors.Wrap(err, "Unable to index recordings"))
+		}
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+
+	results := index.Search(query, opts.OperationSlug)
+	if opts.JSON {
+		return writeJSONList(out, results)
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(out, "No recordings contain %q\n", query)
+		return ExitSuccess
+	}
+
+	for i, r := range results {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%v  %v  %v\n", r.StartedAt.Local().Format("2006-01-02 15:04"), r.OperationSlug, r.FilePath)
+		table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, m := range r.Matches {
+			offset := time.Duration(m.Seconds * float64(time.Second)).Round(time.Second)
+			fmt.Fprintf(table, "  %v (+%v)\t%v\t%v\n", m.At.Local().Format("2006-01-02 15:04:05"), offset, m.Field, m.Text)
+		}
+		table.Flush()
+	}
+	return ExitSuccess
+}
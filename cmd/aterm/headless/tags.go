@@ -0,0 +1,148 @@
+package headless
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+// TagOptions controls the tag management actions (CreateTag, RenameTag and DeleteTag)
+type TagOptions struct {
+	ListOptions
+	// Color is the color of a new (or renamed) tag. Blank picks a random color for new tags, and
+	// keeps the existing color for renamed tags.
+	Color string
+}
+
+// errUnknownTag is returned when the tag to change does not exist
+var errUnknownTag = errors.New("No such tag")
+
+// CreateTag creates a tag in the requested (or configured) operation, and writes the new tag
+func CreateTag(name string, opts TagOptions, out io.Writer) int {
+	operationSlug, code, err := prepareTagAction(opts)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, code, err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tags, err := network.GetTagsWithContext(ctx, operationSlug)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, exitCodeFor(err), errors.Wrap(err, "Unable to get tags"))
+	}
+	if _, ok := findTag(tags, name); ok {
+		return listFailed(out, opts.ListOptions, ExitUsage, fmt.Errorf("A tag named %q already exists", name))
+	}
+
+	color := opts.Color
+	if color == "" {
+		color = network.RandomTagColor()
+	}
+	tag, err := network.CreateTagWithContext(ctx, operationSlug, name, color)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, exitCodeFor(err), errors.Wrap(err, "Unable to create tag"))
+	}
+	return writeTag(out, opts, "Created", *tag)
+}
+
+// RenameTag renames the tag identified by name (or ID), and writes the updated tag
+func RenameTag(nameOrID, newName string, opts TagOptions, out io.Writer) int {
+	operationSlug, code, err := prepareTagAction(opts)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, code, err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tags, err := network.GetTagsWithContext(ctx, operationSlug)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, exitCodeFor(err), errors.Wrap(err, "Unable to get tags"))
+	}
+	tag, ok := findTagByNameOrID(tags, nameOrID)
+	if !ok {
+		return listFailed(out, opts.ListOptions, ExitUsage, errors.Wrap(errUnknownTag, nameOrID))
+	}
+	if existing, ok := findTag(tags, newName); ok && existing.ID != tag.ID {
+		return listFailed(out, opts.ListOptions, ExitUsage, fmt.Errorf("A tag named %q already exists", newName))
+	}
+
+	if opts.Color != "" {
+		tag.ColorName = opts.Color
+	}
+	tag.Name = newName
+	if err := network.UpdateTagWithContext(ctx, operationSlug, tag.ID, tag.Name, tag.ColorName); err != nil {
+		return listFailed(out, opts.ListOptions, exitCodeFor(err), errors.Wrap(err, "Unable to rename tag"))
+	}
+	return writeTag(out, opts, "Renamed", tag)
+}
+
+// DeleteTag deletes the tag identified by name (or ID), and writes the deleted tag. The tag is
+// also removed from all evidence.
+func DeleteTag(nameOrID string, opts TagOptions, out io.Writer) int {
+	operationSlug, code, err := prepareTagAction(opts)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, code, err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tags, err := network.GetTagsWithContext(ctx, operationSlug)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, exitCodeFor(err), errors.Wrap(err, "Unable to get tags"))
+	}
+	tag, ok := findTagByNameOrID(tags, nameOrID)
+	if !ok {
+		return listFailed(out, opts.ListOptions, ExitUsage, errors.Wrap(errUnknownTag, nameOrID))
+	}
+	if err := network.DeleteTagWithContext(ctx, operationSlug, tag.ID); err != nil {
+		return listFailed(out, opts.ListOptions, exitCodeFor(err), errors.Wrap(err, "Unable to delete tag"))
+	}
+	return writeTag(out, opts, "Deleted", tag)
+}
+
+// prepareTagAction loads the configuration and readies the network, returning the operation to
+// work with. On failure, the exit code to use is also returned.
+func prepareTagAction(opts TagOptions) (string, int, error) {
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile, OperationSlug: opts.OperationSlug}); err != nil {
+		return "", ExitConfig, err
+	}
+	if err := configureNetwork(); err != nil {
+		return "", ExitConfig, err
+	}
+	operationSlug := config.OperationSlug()
+	if operationSlug == "" {
+		return "", ExitUsage, errors.New("No operation specified (use --operation)")
+	}
+	return operationSlug, ExitSuccess, nil
+}
+
+// findTagByNameOrID finds a tag by name (ignoring case), or failing that, by ID
+func findTagByNameOrID(tags []dtos.Tag, nameOrID string) (dtos.Tag, bool) {
+	if tag, ok := findTag(tags, nameOrID); ok {
+		return tag, true
+	}
+	if id, err := strconv.ParseInt(nameOrID, 10, 64); err == nil {
+		for _, tag := range tags {
+			if tag.ID == id {
+				return tag, true
+			}
+		}
+	}
+	return dtos.Tag{}, false
+}
+
+func writeTag(out io.Writer, opts TagOptions, action string, tag dtos.Tag) int {
+	if opts.JSON {
+		return writeJSONList(out, tag)
+	}
+	fmt.Fprintf(out, "%v tag %q (ID %v, %v)\n", action, tag.Name, tag.ID, tag.ColorName)
+	return ExitSuccess
+}
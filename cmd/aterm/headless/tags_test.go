@@ -0,0 +1,46 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+)
+
+func TestTagManagement(t *testing.T) {
+	fake := useFakeServer(t)
+	opts := TagOptions{ListOptions: ListOptions{OperationSlug: "op", JSON: true}}
+	run := func(action func(out *bytes.Buffer) int) (int, dtos.Tag) {
+		var out bytes.Buffer
+		code := action(&out)
+		var tag dtos.Tag
+		json.Unmarshal(out.Bytes(), &tag)
+		return code, tag
+	}
+
+	code, tag := run(func(out *bytes.Buffer) int { return CreateTag("Exfil", opts, out) })
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "Exfil", tag.Name)
+	require.Equal(t, []string{"Exfil"}, fake.createdTags)
+
+	code, _ = run(func(out *bytes.Buffer) int { return CreateTag("recon", opts, out) })
+	require.Equal(t, ExitUsage, code, "tag names are unique, ignoring case")
+
+	opts.Color = "red"
+	code, tag = run(func(out *bytes.Buffer) int { return RenameTag("recon", "Discovery", opts, out) })
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, dtos.Tag{ID: 1, Name: "Discovery", ColorName: "red"}, tag)
+
+	code, tag = run(func(out *bytes.Buffer) int { return DeleteTag("1", opts, out) })
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "Recon", tag.Name)
+	require.Equal(t, []string{
+		`PUT /api/operations/op/tags/1 {"name":"Discovery","colorName":"red"}`,
+		`DELETE /api/operations/op/tags/1 `,
+	}, fake.tagChanges)
+
+	code, _ = run(func(out *bytes.Buffer) int { return DeleteTag("missing", opts, out) })
+	require.Equal(t, ExitUsage, code)
+}
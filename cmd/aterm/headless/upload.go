@@ -0,0 +1,222 @@
+package headless
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+// Upload uploads the requested recording, without any prompts. Details not provided on the command
+// line (operation, description, tags) are taken from the recording's saved metadata, if present.
+// The operation may also come from the configuration. Uploads can be cancelled with ^C.
+func Upload(opts config.UploadCLIOptions, out io.Writer) int {
+	result := Result{FilePath: opts.FilePath}
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile, OperationSlug: opts.OperationSlug}); err != nil {
+		return fail(out, result, ExitConfig, err)
+	}
+	if err := configureNetwork(); err != nil {
+		return fail(out, result, ExitConfig, err)
+	}
+
+	metadata, err := recording.LoadMetadata(opts.FilePath)
+	if err != nil {
+		metadata = recording.RecordingMetadata{}
+	}
+	metadata.FilePath = opts.FilePath
+	metadata.OperationSlug = firstNonBlank(opts.OperationSlug, metadata.OperationSlug, config.OperationSlug())
+	metadata.Description = firstNonBlank(opts.Description, metadata.Description)
+	result.OperationSlug = metadata.OperationSlug
+	if metadata.OperationSlug == "" {
+		return fail(out, result, ExitUsage, errors.New("No operation specified (use --operation)"))
+	}
+	if metadata.Description == "" && config.DescriptionTemplate() != "" {
+		metadata.Description, err = recording.RenderDescription(config.DescriptionTemplate(), recording.NewDescriptionFields(metadata))
+		if err != nil {
+			return fail(out, result, ExitConfig, err)
+		}
+	}
+	result.Description = metadata.Description
+
+	metadata.ContentHash, err = recording.HashRecording(opts.FilePath)
+	if err != nil {
+		return fail(out, result, ExitUsage, errors.Wrap(err, "Unable to read recording"))
+	}
+	if !opts.AllowDuplicate && config.DuplicateUploads() != config.DuplicateUploadsAllow {
+		duplicates, _ := recording.FindUploadedDuplicates(config.OutputDir(), metadata)
+		for _, duplicate := range duplicates {
+			result.DuplicateOf = append(result.DuplicateOf, duplicate.FilePath)
+		}
+		if len(duplicates) > 0 && config.DuplicateUploads() == config.DuplicateUploadsRefuse {
+			return fail(out, result, ExitUsage, errDuplicateUpload)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// suggested tags (and, unless tags were requested, the saved tags) are skipped if they no
+	// longer exist, rather than failing the upload
+	optionalTags := []string{}
+	if opts.SuggestTags {
+		rules, err := recording.CompileTagRules(config.TagRules())
+		if err != nil {
+			return fail(out, result, ExitConfig, err)
+		}
+		result.SuggestedTags, err = rules.SuggestTags(metadata)
+		if err != nil {
+			return fail(out, result, ExitFailure, err)
+		}
+		if len(opts.Tags) == 0 {
+			optionalTags = tagNames(metadata.SelectedTags)
+		}
+		optionalTags = append(optionalTags, result.SuggestedTags...)
+	}
+	if len(opts.Tags) > 0 || len(optionalTags) > 0 {
+		metadata.SelectedTags, err = resolveTags(ctx, metadata.OperationSlug, opts.Tags, optionalTags, opts.CreateTags)
+		if err != nil {
+			return fail(out, result, exitCodeFor(err), err)
+		}
+	}
+	result.Tags = tagNames(metadata.SelectedTags)
+
+	// the title is set just before uploading, so that the recording is only rewritten once the
+	// upload is otherwise ready. The upload does not depend on the title, so failures are ignored.
+	if titled, err := recording.ApplyDescriptionTitle(metadata); err == nil {
+		metadata = titled
+	}
+	result.Title = metadata.Title
+	content, err := os.Open(opts.FilePath)
+	if err != nil {
+		return fail(out, result, ExitUsage, errors.Wrap(err, "Unable to read recording"))
+	}
+	defer content.Close()
+
+	evidence, err := network.UploadToAshirtWithContext(ctx, network.UploadInput{
+		OperationSlug: metadata.OperationSlug,
+		Description:   recording.EvidenceDescription(metadata, config.OmitContext()),
+		ContentType:   network.ContentTypeTerminalRecording,
+		Filename:      filepath.Base(opts.FilePath),
+		TagIDs:        tagIDs(metadata.SelectedTags),
+		Content:       content,
+	})
+	if err != nil {
+		return fail(out, result, exitCodeFor(err), errors.Wrap(err, "Unable to upload recording"))
+	}
+	if evidence != nil {
+		result.EvidenceUUID = evidence.UUID
+	}
+
+	metadata.Uploaded = true
+	metadata.EvidenceUUID = result.EvidenceUUID
+	recording.SaveMetadata(metadata) // the upload succeeded, so this is not worth failing over
+
+	result.Status = StatusUploaded
+	result.ExitCode = ExitSuccess
+	return finish(out, result)
+}
+
+// errDuplicateUpload is returned when the recording's content was already uploaded to the
+// operation, and duplicate uploads are refused
+var errDuplicateUpload = errors.New("This recording was already uploaded to this operation (use --allow-duplicate to upload it again)")
+
+// errUnknownTags is returned when requested tags do not exist, and may not be created
+var errUnknownTags = errors.New("Unknown tags (use --create-tags to create them)")
+
+// resolveTags matches the requested tag names (case-insensitively) to the operation's tags,
+// optionally creating any tags that do not yet exist. Optional names are skipped, rather than
+// reported, when they do not exist (and may not be created). Each tag is included once.
+func resolveTags(ctx context.Context, operationSlug string, names, optionalNames []string, createMissing bool) ([]dtos.Tag, error) {
+	serverTags, err := network.GetTagsWithContext(ctx, operationSlug)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to get tags")
+	}
+
+	resolved := make([]dtos.Tag, 0, len(names)+len(optionalNames))
+	missing, missingOptional := []string{}, []string{}
+	for i, name := range append(append([]string{}, names...), optionalNames...) {
+		if _, ok := findTag(resolved, name); ok {
+			continue
+		}
+		if tag, ok := findTag(serverTags, name); ok {
+			resolved = append(resolved, tag)
+		} else if i < len(names) {
+			missing = append(missing, name)
+		} else {
+			missingOptional = append(missingOptional, name)
+		}
+	}
+
+	if !createMissing {
+		if len(missing) > 0 {
+			return nil, errors.Wrap(errUnknownTags, strings.Join(missing, ", "))
+		}
+		return resolved, nil
+	}
+	for _, name := range append(missing, missingOptional...) {
+		if _, ok := findTag(resolved, name); ok {
+			continue // requested more than once
+		}
+		tag, err := network.CreateTagWithContext(ctx, operationSlug, name, network.RandomTagColor())
+		if err != nil {
+			return nil, errors.Wrap(err, `Unable to create tag "`+name+`"`)
+		}
+		resolved = append(resolved, *tag)
+	}
+	return resolved, nil
+}
+
+func findTag(tags []dtos.Tag, name string) (dtos.Tag, bool) {
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag, true
+		}
+	}
+	return dtos.Tag{}, false
+}
+
+func tagIDs(tags []dtos.Tag) []int64 {
+	ids := make([]int64, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	return ids
+}
+
+func tagNames(tags []dtos.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// exitCodeFor determines the exit code for an error encountered while talking to the server
+func exitCodeFor(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ExitCancelled
+	case errors.Is(err, errUnknownTags):
+		return ExitUsage
+	case errors.Is(err, network.ErrTagActionNotSupported), errors.Is(err, network.ErrEvidenceActionNotSupported):
+		return ExitFailure
+	}
+	return ExitConnection
+}
+
+func firstNonBlank(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,295 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/eventers"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+type fakeServer struct {
+	mu          sync.Mutex
+	createdTags []string
+	tagChanges  []string
+	uploadForm  map[string][]string
+	uploadFile  []byte
+	// evidenceChanges lists the method of each change to existing evidence
+	evidenceChanges []string
+	changeForm      map[string][]string
+	changeFile      []byte
+}
+
+// useFakeServer starts a minimal ASHIRT server, with a single operation ("op") that has a single
+// tag ("Recon"), and points the configuration at it via the environment
+func useFakeServer(t *testing.T) *fakeServer {
+	fake := &fakeServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/operations/op/tags", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if r.Method == "POST" {
+			var input struct{ Name string }
+			json.NewDecoder(r.Body).Decode(&input)
+			fake.createdTags = append(fake.createdTags, input.Name)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 100 + len(fake.createdTags), "name": input.Name})
+			return
+		}
+		w.Write([]byte(`[{"id": 1, "name": "Recon"}]`))
+	})
+	mux.HandleFunc("/api/operations/op/tags/", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		fake.tagChanges = append(fake.tagChanges, r.Method+" "+r.URL.Path+" "+string(body))
+	})
+	mux.HandleFunc("/api/operations/op/evidence", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		r.ParseMultipartForm(1 << 20)
+		fake.uploadForm = r.MultipartForm.Value
+		fake.uploadFile = nil
+		if files := r.MultipartForm.File["file"]; len(files) > 0 {
+			f, _ := files[0].Open()
+			fake.uploadFile, _ = ioutil.ReadAll(f)
+			f.Close()
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uuid": "evidence-uuid", "description": "desc"}`))
+	})
+	mux.HandleFunc("/api/operations/op/evidence/", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.evidenceChanges = append(fake.evidenceChanges, r.Method+" "+r.URL.Path)
+		if r.Method == "PUT" {
+			r.ParseMultipartForm(1 << 20)
+			fake.changeForm = r.MultipartForm.Value
+			fake.changeFile = nil
+			if files := r.MultipartForm.File["content"]; len(files) > 0 {
+				f, _ := files[0].Open()
+				fake.changeFile, _ = ioutil.ReadAll(f)
+				f.Close()
+			}
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("ASHIRT_TERM_RECORDER_API_URL", srv.URL)
+	t.Setenv("ASHIRT_TERM_RECORDER_ACCESS_KEY", "access")
+	t.Setenv("ASHIRT_TERM_RECORDER_SECRET_KEY", "c2VjcmV0")
+	t.Setenv("ASHIRT_TERM_RECORDER_MAX_RETRIES", "0")
+	return fake
+}
+
+func writeRecording(t *testing.T) string {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(`{"version": 2}`+"\n"), 0600))
+	return castPath
+}
+
+func runUpload(t *testing.T, opts config.UploadCLIOptions) (int, Result) {
+	var out bytes.Buffer
+	code := Upload(opts, &out)
+	var result Result
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Equal(t, code, result.ExitCode)
+	return code, result
+}
+
+func TestUpload(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeRecording(t)
+
+	code, result := runUpload(t, config.UploadCLIOptions{
+		FilePath:      castPath,
+		OperationSlug: "op",
+		Description:   "ran nmap",
+		Tags:          []string{"recon"},
+	})
+
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, StatusUploaded, result.Status)
+	require.Equal(t, "evidence-uuid", result.EvidenceUUID)
+	require.Equal(t, []string{"Recon"}, result.Tags)
+	require.Equal(t, []string{"ran nmap"}, fake.uploadForm["notes"])
+	require.Equal(t, []string{"[1]"}, fake.uploadForm["tagIds"])
+
+	metadata, err := recording.LoadMetadata(castPath)
+	require.NoError(t, err)
+	require.True(t, metadata.Uploaded)
+	require.Equal(t, "evidence-uuid", metadata.EvidenceUUID)
+}
+
+func TestUploadUsesSavedMetadata(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeRecording(t)
+	require.NoError(t, recording.SaveMetadata(recording.RecordingMetadata{
+		FilePath:      castPath,
+		OperationSlug: "op",
+		Description:   "from metadata",
+	}))
+
+	code, result := runUpload(t, config.UploadCLIOptions{FilePath: castPath})
+
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "op", result.OperationSlug)
+	require.Equal(t, []string{"from metadata"}, fake.uploadForm["notes"])
+}
+
+func TestUploadSetsTitle(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeRecording(t)
+	require.NoError(t, recording.SaveMetadata(recording.RecordingMetadata{FilePath: castPath, StartedAt: time.Unix(100, 0)}))
+
+	code, result := runUpload(t, config.UploadCLIOptions{FilePath: castPath, OperationSlug: "op", Description: "ran nmap\nagainst the DMZ"})
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "ran nmap", result.Title)
+	require.Contains(t, string(fake.uploadFile), `"title":"ran nmap"`)
+
+	metadata, err := recording.LoadMetadata(castPath)
+	require.NoError(t, err)
+	require.Equal(t, "ran nmap", metadata.Title)
+	hash, err := recording.HashRecording(castPath)
+	require.NoError(t, err)
+	require.Equal(t, hash, metadata.ContentHash)
+
+	// recordings that already have a title are left as-is, even without saved metadata
+	otherPath := filepath.Join(t.TempDir(), "other.cast")
+	require.NoError(t, ioutil.WriteFile(otherPath, []byte(`{"version":2,"title":"nmap scan"}`+"\n"), 0600))
+	code, result = runUpload(t, config.UploadCLIOptions{FilePath: otherPath, OperationSlug: "op", Description: "ran nmap"})
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "", result.Title)
+	require.Contains(t, string(fake.uploadFile), `"title":"nmap scan"`)
+}
+
+func TestUploadIncludesContext(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeRecording(t)
+	require.NoError(t, recording.SaveMetadata(recording.RecordingMetadata{
+		FilePath:      castPath,
+		OperationSlug: "op",
+		Description:   "ran nmap",
+		Context:       &formatters.RecordingContext{Hostname: "kali", User: "operator"},
+	}))
+
+	code, _ := runUpload(t, config.UploadCLIOptions{FilePath: castPath})
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, []string{"ran nmap\n\nHost: kali\nUser: operator"}, fake.uploadForm["notes"])
+
+	metadata, err := recording.LoadMetadata(castPath)
+	require.NoError(t, err)
+	require.Equal(t, "ran nmap", metadata.Description)
+}
+
+func TestUploadUnknownTags(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeRecording(t)
+	opts := config.UploadCLIOptions{FilePath: castPath, OperationSlug: "op", Tags: []string{"Recon", "Exfil"}}
+
+	code, result := runUpload(t, opts)
+	require.Equal(t, ExitUsage, code)
+	require.Equal(t, StatusError, result.Status)
+	require.Contains(t, result.Error, "Exfil")
+	require.Nil(t, fake.uploadForm)
+
+	opts.CreateTags = true
+	code, result = runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, []string{"Exfil"}, fake.createdTags)
+	require.Equal(t, []string{"Recon", "Exfil"}, result.Tags)
+}
+
+func TestUploadErrors(t *testing.T) {
+	useFakeServer(t)
+
+	code, _ := runUpload(t, config.UploadCLIOptions{FilePath: filepath.Join(t.TempDir(), "missing.cast"), OperationSlug: "op"})
+	require.Equal(t, ExitUsage, code)
+
+	code, _ = runUpload(t, config.UploadCLIOptions{FilePath: writeRecording(t), OperationSlug: "unknown-op"})
+	require.Equal(t, ExitConnection, code)
+
+	t.Setenv("ASHIRT_TERM_RECORDER_ACCESS_KEY", "")
+	code, _ = runUpload(t, config.UploadCLIOptions{FilePath: writeRecording(t), OperationSlug: "op"})
+	require.Equal(t, ExitConfig, code)
+}
+
+func TestUploadSuggestedTags(t *testing.T) {
+	fake := useFakeServer(t)
+	t.Setenv("ASHIRT_TERM_RECORDER_TAG_RULES", "recon=nmap|masscan,exfil=scp ")
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(`{"version": 2}`+"\n"+`[1, "o", "$ NMAP -sV host\r\n"]`+"\n"), 0600))
+	opts := config.UploadCLIOptions{FilePath: castPath, OperationSlug: "op", SuggestTags: true}
+
+	code, result := runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, []string{"recon"}, result.SuggestedTags)
+	require.Equal(t, []string{"Recon"}, result.Tags)
+	require.Equal(t, []string{"[1]"}, fake.uploadForm["tagIds"])
+
+	require.NoError(t, recording.SaveMetadata(recording.RecordingMetadata{
+		FilePath: castPath,
+		Commands: []eventers.ShellCommand{{Command: "scp loot host:"}},
+	}))
+	code, result = runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code, "missing suggested tags are skipped")
+	require.Equal(t, []string{"exfil", "recon"}, result.SuggestedTags)
+	require.Equal(t, []string{"Recon"}, result.Tags)
+
+	opts.CreateTags = true
+	code, result = runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, []string{"exfil"}, fake.createdTags)
+	require.Equal(t, []string{"Recon", "exfil"}, result.Tags)
+
+	t.Setenv("ASHIRT_TERM_RECORDER_TAG_RULES", "recon=[")
+	code, _ = runUpload(t, opts)
+	require.Equal(t, ExitConfig, code)
+}
+
+func TestUploadDuplicates(t *testing.T) {
+	fake := useFakeServer(t)
+	outputDir := t.TempDir()
+	t.Setenv("ASHIRT_TERM_RECORDER_OUTPUT_DIR", outputDir)
+	castPath := filepath.Join(outputDir, "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(`{"version": 2}`+"\n"), 0600))
+	opts := config.UploadCLIOptions{FilePath: castPath, OperationSlug: "op"}
+
+	code, result := runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code)
+	require.Empty(t, result.DuplicateOf)
+	metadata, err := recording.LoadMetadata(castPath)
+	require.NoError(t, err)
+	require.NotEmpty(t, metadata.ContentHash)
+
+	// duplicates are noted, but uploaded, by default
+	renamedPath := filepath.Join(outputDir, "renamed.cast")
+	require.NoError(t, os.Rename(castPath, renamedPath))
+	opts.FilePath = renamedPath
+	code, result = runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, []string{castPath}, result.DuplicateOf)
+
+	t.Setenv("ASHIRT_TERM_RECORDER_DUPLICATE_UPLOADS", "refuse")
+	fake.uploadForm = nil
+	code, result = runUpload(t, opts)
+	require.Equal(t, ExitUsage, code)
+	require.Len(t, result.DuplicateOf, 2)
+	require.Nil(t, fake.uploadForm)
+
+	opts.AllowDuplicate = true
+	code, _ = runUpload(t, opts)
+	require.Equal(t, ExitSuccess, code)
+}
@@ -0,0 +1,171 @@
+package headless
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+// EvidenceUpdateOptions controls UpdateEvidence
+type EvidenceUpdateOptions struct {
+	FilePath string
+	// Description replaces the evidence's description, unless blank
+	Description string
+	AddTags     []string
+	RemoveTags  []string
+	CreateTags  bool
+	// ReplaceContent uploads the recording again, replacing the evidence's content
+	ReplaceContent bool
+	Profile        string
+}
+
+// errNotUploaded is returned when changing the evidence for a recording that was not uploaded, or
+// was uploaded before the evidence was noted in the recording's metadata
+var errNotUploaded = errors.New("No uploaded evidence is known for this recording")
+
+// UpdateEvidence changes the evidence created when the recording was uploaded, and updates the
+// recording's metadata to match
+func UpdateEvidence(opts EvidenceUpdateOptions, out io.Writer) int {
+	metadata, result, code, err := loadUploadedRecording(opts.FilePath, opts.Profile)
+	if err != nil {
+		return fail(out, result, code, err)
+	}
+	if opts.Description == "" && len(opts.AddTags) == 0 && len(opts.RemoveTags) == 0 && !opts.ReplaceContent {
+		return fail(out, result, ExitUsage, errors.New("Nothing to update (use --description, --tag, --remove-tag or --replace-content)"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	update := network.EvidenceUpdate{OperationSlug: metadata.OperationSlug, EvidenceUUID: metadata.EvidenceUUID}
+	selectedTags := metadata.SelectedTags
+	if len(opts.AddTags) > 0 {
+		added, err := resolveTags(ctx, metadata.OperationSlug, opts.AddTags, nil, opts.CreateTags)
+		if err != nil {
+			return fail(out, result, exitCodeFor(err), err)
+		}
+		update.TagsToAdd = tagIDs(added)
+		selectedTags = mergeTags(selectedTags, added)
+	}
+	if len(opts.RemoveTags) > 0 {
+		removed, err := resolveTags(ctx, metadata.OperationSlug, opts.RemoveTags, nil, false)
+		if err != nil {
+			return fail(out, result, exitCodeFor(err), err)
+		}
+		update.TagsToRemove = tagIDs(removed)
+		selectedTags = withoutTags(selectedTags, removed)
+	}
+	if opts.Description != "" {
+		updated := metadata
+		updated.Description = opts.Description
+		description := recording.EvidenceDescription(updated, config.OmitContext())
+		update.Description = &description
+	}
+	if opts.ReplaceContent {
+		content, err := os.Open(opts.FilePath)
+		if err != nil {
+			return fail(out, result, ExitUsage, errors.Wrap(err, "Unable to read recording"))
+		}
+		defer content.Close()
+		update.Content = content
+		update.Filename = filepath.Base(opts.FilePath)
+		metadata.ContentHash, _ = recording.HashRecording(opts.FilePath)
+	}
+
+	if err := network.UpdateEvidenceWithContext(ctx, update); err != nil {
+		return fail(out, result, exitCodeFor(err), errors.Wrap(err, "Unable to update evidence"))
+	}
+
+	metadata.Description = firstNonBlank(opts.Description, metadata.Description)
+	metadata.SelectedTags = selectedTags
+	recording.SaveMetadata(metadata) // the update succeeded, so this is not worth failing over
+
+	result.Description = metadata.Description
+	result.Tags = tagNames(metadata.SelectedTags)
+	result.Status = StatusUpdated
+	result.ExitCode = ExitSuccess
+	return finish(out, result)
+}
+
+// DeleteEvidence deletes the evidence created when the recording was uploaded. The recording
+// itself is kept, and is marked as not uploaded.
+func DeleteEvidence(filePath, profile string, out io.Writer) int {
+	metadata, result, code, err := loadUploadedRecording(filePath, profile)
+	if err != nil {
+		return fail(out, result, code, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := network.DeleteEvidenceWithContext(ctx, metadata.OperationSlug, metadata.EvidenceUUID); err != nil {
+		return fail(out, result, exitCodeFor(err), errors.Wrap(err, "Unable to delete evidence"))
+	}
+
+	metadata.Uploaded = false
+	metadata.EvidenceUUID = ""
+	recording.SaveMetadata(metadata)
+
+	result.Status = StatusDeleted
+	result.ExitCode = ExitSuccess
+	return finish(out, result)
+}
+
+// loadUploadedRecording readies the network, and loads the metadata of an uploaded recording. On
+// failure, the exit code to use is also returned.
+func loadUploadedRecording(filePath, profile string) (recording.RecordingMetadata, Result, int, error) {
+	result := Result{FilePath: filePath}
+	if err := loadConfig(config.CLIOptions{Profile: profile}); err != nil {
+		return recording.RecordingMetadata{}, result, ExitConfig, err
+	}
+	if err := configureNetwork(); err != nil {
+		return recording.RecordingMetadata{}, result, ExitConfig, err
+	}
+
+	metadata, err := recording.LoadMetadata(filePath)
+	if err != nil || !metadata.Uploaded || metadata.EvidenceUUID == "" {
+		return metadata, result, ExitUsage, errNotUploaded
+	}
+	metadata.FilePath = filePath
+	result.OperationSlug = metadata.OperationSlug
+	result.EvidenceUUID = metadata.EvidenceUUID
+	return metadata, result, ExitSuccess, nil
+}
+
+// mergeTags adds the tags that are not already present
+func mergeTags(tags, added []dtos.Tag) []dtos.Tag {
+	merged := append([]dtos.Tag{}, tags...)
+	for _, tag := range added {
+		if !containsTagID(merged, tag.ID) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// withoutTags removes the given tags
+func withoutTags(tags, removed []dtos.Tag) []dtos.Tag {
+	kept := []dtos.Tag{}
+	for _, tag := range tags {
+		if !containsTagID(removed, tag.ID) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+func containsTagID(tags []dtos.Tag, id int64) bool {
+	for _, tag := range tags {
+		if tag.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+)
+
+func writeUploadedRecording(t *testing.T) string {
+	castPath := writeRecording(t)
+	require.NoError(t, recording.SaveMetadata(recording.RecordingMetadata{
+		FilePath:      castPath,
+		OperationSlug: "op",
+		Description:   "ran nmap",
+		SelectedTags:  []dtos.Tag{{ID: 1, Name: "Recon"}},
+		Uploaded:      true,
+		EvidenceUUID:  "evidence-uuid",
+	}))
+	return castPath
+}
+
+func parseResult(t *testing.T, code int, out bytes.Buffer) Result {
+	var result Result
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Equal(t, code, result.ExitCode)
+	return result
+}
+
+func TestUpdateEvidence(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeUploadedRecording(t)
+
+	var out bytes.Buffer
+	code := UpdateEvidence(EvidenceUpdateOptions{FilePath: castPath}, &out)
+	require.Equal(t, ExitUsage, code)
+	require.Empty(t, fake.evidenceChanges)
+
+	out.Reset()
+	code = UpdateEvidence(EvidenceUpdateOptions{
+		FilePath:       castPath,
+		Description:    "ran nmap again",
+		AddTags:        []string{"Exfil"},
+		RemoveTags:     []string{"recon"},
+		CreateTags:     true,
+		ReplaceContent: true,
+	}, &out)
+	result := parseResult(t, code, out)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, StatusUpdated, result.Status)
+	require.Equal(t, []string{"Exfil"}, result.Tags)
+	require.Equal(t, []string{"PUT /api/operations/op/evidence/evidence-uuid"}, fake.evidenceChanges)
+	require.Equal(t, []string{"ran nmap again"}, fake.changeForm["description"])
+	require.Equal(t, []string{"[101]"}, fake.changeForm["tagsToAdd"])
+	require.Equal(t, []string{"[1]"}, fake.changeForm["tagsToRemove"])
+	require.Equal(t, []byte(`{"version": 2}`+"\n"), fake.changeFile)
+
+	metadata, err := recording.LoadMetadata(castPath)
+	require.NoError(t, err)
+	require.Equal(t, "ran nmap again", metadata.Description)
+	require.Equal(t, []dtos.Tag{{ID: 101, Name: "Exfil"}}, metadata.SelectedTags)
+}
+
+func TestDeleteEvidence(t *testing.T) {
+	fake := useFakeServer(t)
+	castPath := writeUploadedRecording(t)
+
+	var out bytes.Buffer
+	code := DeleteEvidence(castPath, "", &out)
+	result := parseResult(t, code, out)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, StatusDeleted, result.Status)
+	require.Equal(t, []string{"DELETE /api/operations/op/evidence/evidence-uuid"}, fake.evidenceChanges)
+
+	metadata, err := recording.LoadMetadata(castPath)
+	require.NoError(t, err)
+	require.False(t, metadata.Uploaded)
+	require.Empty(t, metadata.EvidenceUUID)
+
+	// the evidence is no longer known, so cannot be deleted again
+	out.Reset()
+	require.Equal(t, ExitUsage, DeleteEvidence(castPath, "", &out))
+	require.Len(t, fake.evidenceChanges, 1)
+}
@@ -0,0 +1,85 @@
+package headless
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/errors"
+)
+
+// VerifyOptions controls Verify
+type VerifyOptions struct {
+	ListOptions
+	// PublicKeyFile is the (PEM encoded) public key the recording must be signed with. When blank,
+	// the configured signing key is expected, if there is one.
+	PublicKeyFile string
+}
+
+// Verify checks a recording against its integrity record (see recording.SealRecording), and
+// reports the first line that was changed. Returns ExitFailure if the recording was changed, or
+// was not signed by the expected key.
+func Verify(filePath string, opts VerifyOptions, out io.Writer) int {
+	if err := loadConfig(config.CLIOptions{Profile: opts.Profile}); err != nil {
+		return listFailed(out, opts.ListOptions, ExitConfig, err)
+	}
+
+	var trustedKey ed25519.PublicKey
+	if opts.PublicKeyFile != "" {
+		data, err := ioutil.ReadFile(opts.PublicKeyFile)
+		if err != nil {
+			return listFailed(out, opts.ListOptions, ExitUsage, errors.Wrap(err, "Unable to read public key"))
+		}
+		if trustedKey, err = recording.ParsePublicKey(data); err != nil {
+			return listFailed(out, opts.ListOptions, ExitUsage, err)
+		}
+	} else {
+		signingKey, err := config.SigningKey()
+		if err != nil {
+			return listFailed(out, opts.ListOptions, ExitConfig, err)
+		}
+		if signingKey != nil {
+			trustedKey = signingKey.Public().(ed25519.PublicKey)
+		}
+	}
+
+	verification, err := recording.VerifyRecording(filePath, trustedKey)
+	if err != nil {
+		return listFailed(out, opts.ListOptions, ExitUsage, err)
+	}
+	exitCode := ExitSuccess
+	if !verification.Intact {
+		exitCode = ExitFailure
+	}
+	if opts.JSON {
+		writeJSONList(out, verification)
+		return exitCode
+	}
+
+	if verification.Intact {
+		fmt.Fprintf(out, "%v: OK (%v lines)\n", filePath, verification.Lines)
+	} else {
+		location := ""
+		if verification.TamperedLine > 0 {
+			location = fmt.Sprintf(" at line %v", verification.TamperedLine)
+			if verification.TamperedOffset != nil {
+				location += fmt.Sprintf(" (%v into the recording)", recording.FormatOffset(*verification.TamperedOffset))
+			}
+		}
+		fmt.Fprintf(out, "%v: TAMPERED%v: %v\n", filePath, location, verification.Problem)
+	}
+	switch {
+	case !verification.Signed:
+		fmt.Fprintln(out, "Not signed")
+	case !verification.SignatureValid:
+		fmt.Fprintf(out, "Signature: invalid (key %v)\n", verification.KeyFingerprint)
+	case verification.KeyTrusted:
+		fmt.Fprintf(out, "Signature: valid, by the expected key (%v)\n", verification.KeyFingerprint)
+	default:
+		fmt.Fprintf(out, "Signature: valid, by key %v (not checked against a trusted key; use --public-key)\n", verification.KeyFingerprint)
+	}
+	return exitCode
+}
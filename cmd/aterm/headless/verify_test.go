@@ -0,0 +1,51 @@
+package headless
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/cmd/aterm/recording"
+	"github.com/theparanoids/aterm/write"
+)
+
+func TestVerify(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	castPath := filepath.Join(dir, "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte("{\"version\":2}\n[1,\"o\",\"id\\r\\n\"]\n"), 0600))
+
+	var out bytes.Buffer
+	require.Equal(t, ExitUsage, Verify(castPath, VerifyOptions{}, &out))
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	publicKeyPath := filepath.Join(dir, "public.pem")
+	require.NoError(t, ioutil.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	var chain write.HashChain
+	chain.Add([]byte("[1,\"o\",\"id\\r\\n\"]\n"))
+	chain.Add([]byte("{\"version\":2}\n"))
+	require.NoError(t, recording.SealRecording(castPath, &chain, privateKey))
+
+	out.Reset()
+	opts := VerifyOptions{PublicKeyFile: publicKeyPath}
+	opts.JSON = true
+	require.Equal(t, ExitSuccess, Verify(castPath, opts, &out))
+	var verification recording.Verification
+	require.NoError(t, json.Unmarshal(out.Bytes(), &verification))
+	require.True(t, verification.KeyTrusted)
+
+	require.NoError(t, ioutil.WriteFile(castPath, []byte("{\"version\":2}\n[1,\"o\",\"whoami\\r\\n\"]\n"), 0600))
+	out.Reset()
+	opts.JSON = false
+	require.Equal(t, ExitFailure, Verify(castPath, opts, &out))
+	require.Contains(t, out.String(), "TAMPERED at line 2 (0:01 into the recording)")
+}
@@ -0,0 +1,154 @@
+package recording
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+
+	"github.com/theparanoids/aterm/cmd/aterm/config"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+// Names of the context details, as used by the omitContext setting
+const (
+	ContextHostname    = "hostname"
+	ContextUser        = "user"
+	ContextIPAddresses = "ipAddresses"
+	ContextWorkingDir  = "workingDir"
+	ContextOS          = "os"
+	ContextVersion     = "version"
+	ContextOperation   = "operation"
+	// ContextDescription leaves the summary of the context out of uploaded descriptions
+	ContextDescription = "description"
+	// ContextAll omits every detail
+	ContextAll = "all"
+)
+
+// GatherContext collects the details of where, and by whom, a recording is being made, less any
+// omitted details (see the Context* names). A blank working directory means the current directory.
+func GatherContext(operationSlug, workingDir string, omit []string) formatters.RecordingContext {
+	var context formatters.RecordingContext
+	if !contextOmitted(omit, ContextHostname) {
+		context.Hostname, _ = os.Hostname()
+	}
+	if !contextOmitted(omit, ContextUser) {
+		context.User = currentUser()
+	}
+	if !contextOmitted(omit, ContextIPAddresses) {
+		context.IPAddresses = ipAddresses()
+	}
+	if !contextOmitted(omit, ContextWorkingDir) {
+		context.WorkingDir = workingDir
+		if workingDir == "" {
+			context.WorkingDir, _ = os.Getwd()
+		}
+	}
+	if !contextOmitted(omit, ContextOS) {
+		context.OS = runtime.GOOS + "/" + runtime.GOARCH
+		if release := kernelRelease(); release != "" {
+			context.OS += " " + release
+		}
+	}
+	if !contextOmitted(omit, ContextVersion) {
+		context.ATermVersion = config.Version()
+	}
+	if !contextOmitted(omit, ContextOperation) {
+		context.OperationSlug = operationSlug
+	}
+	return context
+}
+
+// ContextSummary describes the context, one detail per line, for inclusion in evidence
+// descriptions. Returns an empty string if no details are known.
+func ContextSummary(context formatters.RecordingContext) string {
+	lines := []string{}
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+	add("Host", context.Hostname)
+	add("IP Addresses", strings.Join(context.IPAddresses, ", "))
+	add("User", context.User)
+	add("Working Dir", context.WorkingDir)
+	add("OS", context.OS)
+	add("ATerm", context.ATermVersion)
+	add("Operation", context.OperationSlug)
+	return strings.Join(lines, "\n")
+}
+
+// EvidenceDescription returns the description to upload for the recording: the recording's
+// description, followed by a summary of its context (see ContextSummary), unless omitted
+func EvidenceDescription(metadata RecordingMetadata, omit []string) string {
+	if metadata.Context == nil || contextOmitted(omit, ContextDescription) {
+		return metadata.Description
+	}
+	summary := ContextSummary(*metadata.Context)
+	if summary == "" {
+		return metadata.Description
+	} else if metadata.Description == "" {
+		return summary
+	}
+	return metadata.Description + "\n\n" + summary
+}
+
+func contextOmitted(omit []string, name string) bool {
+	for _, o := range omit {
+		if strings.EqualFold(o, name) || strings.EqualFold(o, ContextAll) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentUser returns the name of the user running aterm
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// ipAddresses lists the addresses of the network interfaces that are up, other than loopback and
+// link-local addresses
+func ipAddresses() []string {
+	addresses := []string{}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return addresses
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() && !ipNet.IP.IsLoopback() {
+				addresses = append(addresses, ipNet.IP.String())
+			}
+		}
+	}
+	return addresses
+}
+
+// kernelRelease returns the operating system's kernel release (e.g. 6.1.0-13-amd64), if known
+func kernelRelease() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	if release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		return strings.TrimSpace(string(release))
+	}
+	release, err := exec.Command("uname", "-r").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(release))
+}
@@ -0,0 +1,44 @@
+package recording
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+func TestGatherContext(t *testing.T) {
+	context := GatherContext("op", "/tmp/work", nil)
+	require.Equal(t, "op", context.OperationSlug)
+	require.Equal(t, "/tmp/work", context.WorkingDir)
+	require.NotEmpty(t, context.OS)
+
+	context = GatherContext("op", "/tmp/work", []string{ContextHostname, "IPADDRESSES", ContextWorkingDir})
+	require.Empty(t, context.Hostname)
+	require.Empty(t, context.IPAddresses)
+	require.Empty(t, context.WorkingDir)
+	require.Equal(t, "op", context.OperationSlug)
+
+	require.True(t, GatherContext("op", "/tmp/work", []string{ContextAll}).IsEmpty())
+}
+
+func TestEvidenceDescription(t *testing.T) {
+	context := formatters.RecordingContext{
+		Hostname:      "kali",
+		IPAddresses:   []string{"10.0.0.5", "10.0.0.6"},
+		User:          "operator",
+		OperationSlug: "op",
+	}
+	summary := "Host: kali\nIP Addresses: 10.0.0.5, 10.0.0.6\nUser: operator\nOperation: op"
+	require.Equal(t, summary, ContextSummary(context))
+
+	metadata := RecordingMetadata{Description: "ran nmap"}
+	require.Equal(t, "ran nmap", EvidenceDescription(metadata, nil))
+
+	metadata.Context = &context
+	require.Equal(t, "ran nmap\n\n"+summary, EvidenceDescription(metadata, nil))
+	require.Equal(t, "ran nmap", EvidenceDescription(metadata, []string{ContextDescription}))
+
+	metadata.Description = ""
+	require.Equal(t, summary, EvidenceDescription(metadata, nil))
+}
@@ -0,0 +1,94 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/theparanoids/aterm/errors"
+)
+
+// DescriptionFields are the values available to description templates (see RenderDescription)
+type DescriptionFields struct {
+	Operation string
+	Hostname  string
+	User      string
+	FileName  string
+	StartTime time.Time
+	Duration  time.Duration
+	// Command is the command that was recorded, if a command (rather than a shell) was recorded
+	Command string
+	// ExitCode is the exit code of the recorded shell or command (-1 if not known)
+	ExitCode int
+	// FirstCommand is the first command run within the recording, or Command if no commands were
+	// detected
+	FirstCommand string
+	// Commands lists the commands run within the recording (see ShellIntegrationScript)
+	Commands []string
+	// Markers lists the labels of each marker in the recording
+	Markers []string
+}
+
+// descriptionFuncs are the extra functions available to description templates
+var descriptionFuncs = template.FuncMap{
+	"join": func(items []string, sep string) string {
+		return strings.Join(items, sep)
+	},
+}
+
+// NewDescriptionFields gathers the template values for the recording described by the metadata.
+// The recording itself is read for its markers; if this fails, Markers is left empty. Hostname and
+// User are taken from the recording's context, if noted (see GatherContext).
+func NewDescriptionFields(metadata RecordingMetadata) DescriptionFields {
+	fields := DescriptionFields{
+		Operation: metadata.OperationSlug,
+		FileName:  filepath.Base(metadata.FilePath),
+		StartTime: metadata.StartedAt,
+		Duration:  time.Duration(metadata.DurationSeconds * float64(time.Second)).Round(time.Second),
+		Command:   metadata.Command,
+		ExitCode:  -1,
+		Commands:  []string{},
+		Markers:   []string{},
+	}
+	if metadata.Context != nil {
+		// as recorded, less any omitted details
+		fields.Hostname, fields.User = metadata.Context.Hostname, metadata.Context.User
+	} else {
+		// older recordings did not note their context, so the best guess is this machine
+		fields.Hostname, _ = os.Hostname()
+		fields.User = currentUser()
+	}
+	if metadata.ExitCode != nil {
+		fields.ExitCode = *metadata.ExitCode
+	}
+	for _, c := range metadata.Commands {
+		fields.Commands = append(fields.Commands, c.Command)
+	}
+	fields.FirstCommand = fields.Command
+	if len(fields.Commands) > 0 {
+		fields.FirstCommand = fields.Commands[0]
+	}
+	if markers, err := ReadMarkers(metadata.FilePath); err == nil {
+		for _, m := range markers {
+			fields.Markers = append(fields.Markers, m.Label)
+		}
+	}
+	return fields
+}
+
+// RenderDescription renders the description template (a text/template) with the provided fields.
+// Besides the standard template functions, join (e.g. {{join .Commands "; "}}) is available.
+// Surrounding whitespace is removed from the result.
+func RenderDescription(tmpl string, fields DescriptionFields) (string, error) {
+	parsed, err := template.New("description").Funcs(descriptionFuncs).Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "Invalid description template")
+	}
+	var rendered strings.Builder
+	if err := parsed.Execute(&rendered, fields); err != nil {
+		return "", errors.Wrap(err, "Unable to render description template")
+	}
+	return strings.TrimSpace(rendered.String()), nil
+}
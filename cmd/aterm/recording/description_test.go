@@ -0,0 +1,60 @@
+package recording
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/eventers"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+func TestRenderDescription(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(
+		"{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":1000}\n"+
+			"[1,\"m\",\"nmap -sV host\"]\n"+
+			"[1.5,\"o\",\"Starting Nmap\\r\\n\"]\n"+
+			"[65,\"m\",\"exit 0\"]\n"), 0600))
+	exitCode := 0
+	fields := NewDescriptionFields(RecordingMetadata{
+		FilePath:        castPath,
+		OperationSlug:   "op",
+		StartedAt:       time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		DurationSeconds: 65.4,
+		ExitCode:        &exitCode,
+		Commands:        []eventers.ShellCommand{{Command: "nmap -sV host"}, {Command: "exit"}},
+	})
+	require.Equal(t, []string{"nmap -sV host", "exit 0"}, fields.Markers)
+	require.Equal(t, "nmap -sV host", fields.FirstCommand)
+	require.NotEmpty(t, fields.User)
+
+	rendered, err := RenderDescription(
+		`  {{.Operation}} {{.StartTime.Format "2006-01-02"}} ({{.Duration}}, exit {{.ExitCode}}): {{join .Commands "; "}}`+"\n", fields)
+	require.NoError(t, err)
+	require.Equal(t, "op 2021-03-04 (1m5s, exit 0): nmap -sV host; exit", rendered)
+
+	_, err = RenderDescription("{{.Unknown}}", fields)
+	require.Error(t, err)
+	_, err = RenderDescription("{{", fields)
+	require.Error(t, err)
+}
+
+func TestDescriptionFieldsUseRecordedContext(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte("{\"version\":2}\n"), 0600))
+
+	fields := NewDescriptionFields(RecordingMetadata{
+		FilePath: castPath,
+		Context:  &formatters.RecordingContext{Hostname: "jumpbox", User: "operator"},
+	})
+	require.Equal(t, "jumpbox", fields.Hostname)
+	require.Equal(t, "operator", fields.User)
+
+	// omitted details are left blank, rather than filled in from this machine
+	fields = NewDescriptionFields(RecordingMetadata{FilePath: castPath, Context: &formatters.RecordingContext{}})
+	require.Empty(t, fields.Hostname)
+	require.Empty(t, fields.User)
+}
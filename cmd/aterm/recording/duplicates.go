@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HashRecording returns the SHA-256 hash of the recording's events, hex encoded. The header (the
+// first line) is left out, so that changes to it, such as setting the title, do not change the
+// hash.
+func HashRecording(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if _, err := reader.ReadBytes('\n'); err != nil && err != io.EOF {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// FindUploadedDuplicates looks for earlier uploads of the same content to the same operation, as
+// noted in the metadata saved under the given directory (and alongside the given recording).
+// Metadata is read directly, so uploads are found even if the recording has since been renamed
+// or removed; the returned metadata notes where the recording was when it was last saved. The
+// given metadata must include the ContentHash of the recording being uploaded.
+func FindUploadedDuplicates(dir string, metadata RecordingMetadata) ([]RecordingMetadata, error) {
+	duplicates := []RecordingMetadata{}
+	if metadata.ContentHash == "" {
+		return duplicates, nil
+	}
+
+	metadataPaths := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // skip unreadable entries
+		}
+		if !info.IsDir() && strings.HasSuffix(path, metadataSuffix) {
+			metadataPaths = append(metadataPaths, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return duplicates, err
+	}
+	// the recording may be kept outside of the directory
+	ownPath := MetadataPath(metadata.FilePath)
+	if !containsPath(metadataPaths, ownPath) {
+		metadataPaths = append(metadataPaths, ownPath)
+	}
+
+	for _, path := range metadataPaths {
+		saved, err := LoadMetadata(strings.TrimSuffix(path, metadataSuffix))
+		if err != nil {
+			continue
+		}
+		if saved.Uploaded && saved.ContentHash == metadata.ContentHash && saved.OperationSlug == metadata.OperationSlug {
+			duplicates = append(duplicates, saved)
+		}
+	}
+	return duplicates, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
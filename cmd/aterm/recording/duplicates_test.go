@@ -0,0 +1,54 @@
+package recording
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUploadedDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	opDir := filepath.Join(dir, "op")
+	require.NoError(t, os.Mkdir(opDir, 0700))
+	original := filepath.Join(opDir, "original.cast")
+	require.NoError(t, ioutil.WriteFile(original, []byte("{\"version\":2}\n[0.5,\"o\",\"hi\\r\\n\"]\n"), 0600))
+
+	hash, err := HashRecording(original)
+	require.NoError(t, err)
+	require.Equal(t, "f78d9d330139ebeab37e9da93db0dd6a09654bb4c02e73c350c7094777c557d5", hash)
+
+	// the header is not part of the hash, so titling the recording leaves it unchanged
+	require.NoError(t, SetRecordingTitle(original, "ran whoami"))
+	titledHash, err := HashRecording(original)
+	require.NoError(t, err)
+	require.Equal(t, hash, titledHash)
+
+	uploaded := RecordingMetadata{FilePath: original, OperationSlug: "op", ContentHash: hash, Uploaded: true}
+	require.NoError(t, SaveMetadata(uploaded))
+
+	// renamed without its metadata
+	renamed := filepath.Join(opDir, "renamed.cast")
+	require.NoError(t, os.Rename(original, renamed))
+	duplicates, err := FindUploadedDuplicates(dir, RecordingMetadata{FilePath: renamed, OperationSlug: "op", ContentHash: hash})
+	require.NoError(t, err)
+	require.Len(t, duplicates, 1)
+	require.Equal(t, original, duplicates[0].FilePath)
+
+	// other operations, and other content, are not duplicates
+	duplicates, err = FindUploadedDuplicates(dir, RecordingMetadata{FilePath: renamed, OperationSlug: "other", ContentHash: hash})
+	require.NoError(t, err)
+	require.Empty(t, duplicates)
+	duplicates, err = FindUploadedDuplicates(dir, RecordingMetadata{FilePath: renamed, OperationSlug: "op", ContentHash: "abc"})
+	require.NoError(t, err)
+	require.Empty(t, duplicates)
+
+	// recordings kept outside of the directory are checked too
+	outside := filepath.Join(t.TempDir(), "outside.cast")
+	require.NoError(t, SaveMetadata(RecordingMetadata{FilePath: outside, OperationSlug: "op", ContentHash: "abc", Uploaded: true}))
+	duplicates, err = FindUploadedDuplicates(dir, RecordingMetadata{FilePath: outside, OperationSlug: "op", ContentHash: "abc"})
+	require.NoError(t, err)
+	require.Len(t, duplicates, 1)
+}
@@ -0,0 +1,242 @@
+package recording
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/eventers"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+// IndexFileName is the name of the index file, kept at the top of the output directory
+const IndexFileName = ".aterm-index.json"
+
+// indexVersion is increased whenever the indexed details change, so that older indexes are rebuilt
+const indexVersion = 3
+
+// RecordingIndex catalogs the recordings in a directory, so that they can be searched without
+// re-reading every recording. See UpdateIndex.
+type RecordingIndex struct {
+	Version    int                `json:"version"`
+	Recordings []IndexedRecording `json:"recordings"`
+}
+
+// IndexedRecording holds the searchable details of a single recording
+type IndexedRecording struct {
+	RecordingSummary
+	Tags               []string                `json:"tags"`
+	StartedAt          time.Time               `json:"startedAt"`
+	DurationSeconds    float64                 `json:"durationSeconds,omitempty"`
+	Commands           []eventers.ShellCommand `json:"commands"`
+	Output             []OutputLine            `json:"output"`
+	MetadataModifiedAt time.Time               `json:"metadataModifiedAt"`
+}
+
+// SearchMatch is a single match within a recording. Field is one of "command", "output",
+// "description" or "tag". Seconds is relative to the start of the recording (and is zero for
+// descriptions and tags).
+type SearchMatch struct {
+	Field   string    `json:"field"`
+	Seconds float64   `json:"offsetSeconds"`
+	At      time.Time `json:"at"`
+	Text    string    `json:"text"`
+}
+
+// SearchResult lists the matches found within a single recording
+type SearchResult struct {
+	RecordingSummary
+	StartedAt time.Time     `json:"startedAt"`
+	Matches   []SearchMatch `json:"matches"`
+}
+
+// IndexPath returns where the index for the given directory is kept
+func IndexPath(dir string) string {
+	return filepath.Join(dir, IndexFileName)
+}
+
+// UpdateIndex brings the index for the given directory up to date, and saves it. Only recordings
+// that are new, or have changed (or whose metadata has changed) since last indexed are read;
+// recordings that no longer exist are dropped. If rebuild is true, every recording is re-read.
+// Recordings that cannot be read are skipped, and noted in the returned error.
+func UpdateIndex(dir string, rebuild bool) (RecordingIndex, error) {
+	existing := map[string]IndexedRecording{}
+	if !rebuild {
+		if index, err := LoadIndex(dir); err == nil && index.Version == indexVersion {
+			for _, r := range index.Recordings {
+				existing[r.FilePath] = r
+			}
+		}
+	}
+
+	summaries, err := FindRecordings(dir)
+	if err != nil {
+		return RecordingIndex{}, err
+	}
+
+	var readErr error
+	index := RecordingIndex{Version: indexVersion, Recordings: make([]IndexedRecording, 0, len(summaries))}
+	for _, summary := range summaries {
+		metadataModifiedAt := time.Time{}
+		if info, err := os.Stat(MetadataPath(summary.FilePath)); err == nil {
+			metadataModifiedAt = info.ModTime()
+		}
+		if prior, ok := existing[summary.FilePath]; ok &&
+			prior.Size == summary.Size && prior.ModifiedAt.Equal(summary.ModifiedAt) &&
+			prior.MetadataModifiedAt.Equal(metadataModifiedAt) {
+			index.Recordings = append(index.Recordings, prior)
+			continue
+		}
+		entry, err := indexRecording(summary)
+		if err != nil {
+			readErr = errors.Append(readErr, errors.Wrap(err, "Unable to index "+summary.FilePath))
+			continue
+		}
+		entry.MetadataModifiedAt = metadataModifiedAt
+		index.Recordings = append(index.Recordings, entry)
+	}
+
+	if err := saveIndex(dir, index); err != nil {
+		return index, errors.Append(readErr, errors.Wrap(err, "Unable to save the recording index"))
+	}
+	return index, readErr
+}
+
+// LoadIndex reads the saved index for the given directory, as-is
+func LoadIndex(dir string) (RecordingIndex, error) {
+	var index RecordingIndex
+	data, err := ioutil.ReadFile(IndexPath(dir))
+	if err != nil {
+		return index, err
+	}
+	err = json.Unmarshal(data, &index)
+	return index, err
+}
+
+// saveIndex writes the index to a temporary file, which then replaces the existing index. The index
+// contains recorded output, so it is only readable by its owner.
+func saveIndex(dir string, index RecordingIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, IndexFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), IndexPath(dir))
+}
+
+// indexRecording reads the recording (and its metadata, if any) to build its index entry
+func indexRecording(summary RecordingSummary) (IndexedRecording, error) {
+	entry := IndexedRecording{RecordingSummary: summary, Tags: []string{}, Commands: []eventers.ShellCommand{}}
+
+	header, output, err := readOutputText(summary.FilePath)
+	if err != nil {
+		return entry, err
+	}
+	entry.StartedAt = time.Unix(header.Timestamp, 0)
+	entry.DurationSeconds = header.Duration
+	entry.Output = output
+
+	if metadata, err := LoadMetadata(summary.FilePath); err == nil {
+		for _, tag := range metadata.SelectedTags {
+			entry.Tags = append(entry.Tags, tag.Name)
+		}
+		if !metadata.StartedAt.IsZero() {
+			entry.StartedAt = metadata.StartedAt
+		}
+		if metadata.DurationSeconds > 0 {
+			entry.DurationSeconds = metadata.DurationSeconds
+		}
+		if metadata.Commands != nil {
+			entry.Commands = metadata.Commands
+		}
+	}
+	return entry, nil
+}
+
+// readOutputText reads the header of the recording at the given path, along with its output as
+// plain lines of text (see textExtractor). Unreadable events are treated as the end of the
+// recording, as recordings may be cut short if aterm was killed.
+func readOutputText(path string) (formatters.ASCIICastHeader, []OutputLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return formatters.ASCIICastHeader{}, nil, err
+	}
+	defer f.Close()
+	reader, err := formatters.NewASCIICastReader(f)
+	if err != nil {
+		return formatters.ASCIICastHeader{}, nil, err
+	}
+
+	var text textExtractor
+	for {
+		evt, err := reader.Next()
+		if err != nil {
+			break
+		}
+		if evt.Type == "o" {
+			text.write(evt.When, evt.Data)
+		}
+	}
+	return reader.Header, text.finish(), nil
+}
+
+// Search finds the recordings containing the query (ignoring case) within their commands, output,
+// description or tags. Results are ordered as in the index (newest first). If operationSlug is
+// provided, only recordings for that operation are searched.
+func (index RecordingIndex) Search(query, operationSlug string) []SearchResult {
+	results := []SearchResult{}
+	needle := strings.ToLower(query)
+	contains := func(s string) bool {
+		return strings.Contains(strings.ToLower(s), needle)
+	}
+
+	for _, r := range index.Recordings {
+		if operationSlug != "" && r.OperationSlug != operationSlug {
+			continue
+		}
+		result := SearchResult{RecordingSummary: r.RecordingSummary, StartedAt: r.StartedAt, Matches: []SearchMatch{}}
+		addMatch := func(field string, seconds float64, text string) {
+			at := r.StartedAt.Add(time.Duration(seconds * float64(time.Second)))
+			result.Matches = append(result.Matches, SearchMatch{Field: field, Seconds: seconds, At: at, Text: text})
+		}
+
+		if contains(r.Description) {
+			addMatch("description", 0, r.Description)
+		}
+		for _, tag := range r.Tags {
+			if contains(tag) {
+				addMatch("tag", 0, tag)
+			}
+		}
+		for _, command := range r.Commands {
+			if contains(command.Command) {
+				addMatch("command", command.StartSeconds, command.Command)
+			}
+		}
+		for _, line := range r.Output {
+			if contains(line.Text) {
+				addMatch("output", line.Seconds, line.Text)
+			}
+		}
+		if len(result.Matches) > 0 {
+			results = append(results, result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,85 @@
+package recording
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/eventers"
+)
+
+func TestTextExtractor(t *testing.T) {
+	var x textExtractor
+	x.write(1, "\x1b]0;title\a\x1b[1;32mgreen\x1b[0m text\r\n")
+	x.write(2, "typo\bo\x1b") // sequence split across writes
+	x.write(3, "[Kline\x1b(B end\n\n   \n")
+	x.write(4, "Progress: 10%\r")
+	x.write(5, "\x1b[KProgress: 100%\r\n") // only the text after the last carriage return is kept
+	x.write(6, "no newline")
+
+	require.Equal(t, []OutputLine{
+		{Seconds: 1, Text: "green text"},
+		{Seconds: 2, Text: "typoline end"},
+		{Seconds: 5, Text: "Progress: 100%"},
+		{Seconds: 6, Text: "no newline"},
+	}, x.finish())
+}
+
+func TestUpdateIndexAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	opDir := filepath.Join(dir, "op")
+	require.NoError(t, os.Mkdir(opDir, 0700))
+	first := filepath.Join(opDir, "first.cast")
+	second := filepath.Join(opDir, "second.cast")
+	require.NoError(t, ioutil.WriteFile(first, []byte(
+		"{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":1000}\n"+
+			"[1.5,\"o\",\"$ smbclient -L //host\\r\\n\"]\n"+
+			"[2.5,\"o\",\"Sharename  Type\\r\\n\"]\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(second, []byte(
+		"{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":2000}\n"+
+			"[1,\"o\",\"nothing here\\r\\n\"]\n"), 0600))
+	require.NoError(t, SaveMetadata(RecordingMetadata{
+		FilePath:      first,
+		OperationSlug: "op",
+		Description:   "SMB enumeration",
+		SelectedTags:  []dtos.Tag{{Name: "smb"}},
+		Commands:      []eventers.ShellCommand{{Command: "smbclient -L //host", StartSeconds: 1.25}},
+	}))
+
+	index, err := UpdateIndex(dir, false)
+	require.NoError(t, err)
+	require.Len(t, index.Recordings, 2)
+
+	results := index.Search("SMB", "")
+	require.Len(t, results, 1)
+	require.Equal(t, first, results[0].FilePath)
+	require.Equal(t, []SearchMatch{
+		{Field: "description", At: time.Unix(1000, 0), Text: "SMB enumeration"},
+		{Field: "tag", At: time.Unix(1000, 0), Text: "smb"},
+		{Field: "command", Seconds: 1.25, At: time.Unix(1000, 0).Add(1250 * time.Millisecond), Text: "smbclient -L //host"},
+		{Field: "output", Seconds: 1.5, At: time.Unix(1000, 0).Add(1500 * time.Millisecond), Text: "$ smbclient -L //host"},
+	}, results[0].Matches)
+	require.Empty(t, index.Search("smb", "other-op"))
+
+	// unchanged recordings are kept from the saved index; removed recordings are dropped
+	saved, err := LoadIndex(dir)
+	require.NoError(t, err)
+	for i := range saved.Recordings {
+		saved.Recordings[i].Output = []OutputLine{{Text: "from the index"}}
+	}
+	require.NoError(t, saveIndex(dir, saved))
+	require.NoError(t, os.Remove(first))
+
+	index, err = UpdateIndex(dir, false)
+	require.NoError(t, err)
+	require.Len(t, index.Recordings, 1)
+	require.Len(t, index.Search("from the index", ""), 1)
+
+	index, err = UpdateIndex(dir, true)
+	require.NoError(t, err)
+	require.Empty(t, index.Search("from the index", ""))
+}
@@ -0,0 +1,252 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/write"
+)
+
+// integrityVersion identifies how the hash chain is built, and what is signed
+const integrityVersion = 1
+
+// ErrNotSealed is returned when verifying a recording that has no integrity record
+var ErrNotSealed = errors.New("The recording has no integrity record (see hashChain)")
+
+// IntegrityRecord makes changes to a recording evident. It is saved alongside the recording (see
+// IntegrityPath) once the recording is complete, rather than within it, so that recordings remain
+// playable.
+//
+// Each line of the recording is linked into a hash chain (see write.HashChain) as it is recorded:
+// each event, in order, followed by the header. The header is linked last, as it is only final
+// once recording ends. When a signing key is configured, the final link (and the number of links)
+// is signed with that key.
+type IntegrityRecord struct {
+	Version int `json:"version"`
+	// Links holds the hex encoded hash chain, with one link per line of the recording (i.e. one per
+	// event, then one for the header)
+	Links []string `json:"links"`
+	// PublicKey is the base64 encoded ed25519 public key that made the signature
+	PublicKey string `json:"publicKey,omitempty"`
+	// Signature is the base64 encoded ed25519 signature (see signedMessage)
+	Signature string `json:"signature,omitempty"`
+}
+
+// Verification describes the outcome of checking a recording against its integrity record
+type Verification struct {
+	FilePath string `json:"filePath"`
+	// Intact is true when every line matches the hash chain, and the signature (if any) is valid
+	Intact bool `json:"intact"`
+	Lines  int  `json:"lines"`
+	// TamperedLine is the first line (where the header is line 1) that no longer matches the
+	// hash chain, or 0 if every line matches
+	TamperedLine int `json:"tamperedLine,omitempty"`
+	// TamperedOffset is when the tampered event occurred (in seconds from the start of the
+	// recording), if known
+	TamperedOffset *float64 `json:"tamperedOffsetSeconds,omitempty"`
+	Problem        string   `json:"problem,omitempty"`
+
+	Signed         bool   `json:"signed"`
+	SignatureValid bool   `json:"signatureValid"`
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+	// KeyTrusted is true when the signature was made by the expected key. When no key is expected,
+	// the signature only shows that the integrity record was not changed without the signing key.
+	KeyTrusted bool `json:"keyTrusted"`
+}
+
+// IntegrityPath returns where the integrity record for the given recording is saved
+func IntegrityPath(recordingPath string) string {
+	return recordingPath + ".integrity.json"
+}
+
+// SealRecording saves the integrity record for the recording at the given path, from the hash chain
+// built as the recording was written (see write.StreamingFileWriter.ChainLines), signed with the
+// provided key (if not nil). The recording itself is not read, so changes made to the file while
+// recording are reported when it is verified, rather than sealed.
+func SealRecording(path string, chain *write.HashChain, key ed25519.PrivateKey) error {
+	record := IntegrityRecord{Version: integrityVersion, Links: chain.HexLinks()}
+	if key != nil {
+		record.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+		record.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, signedMessage(record.Links)))
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return errors.MaybeWrap(ioutil.WriteFile(IntegrityPath(path), data, 0600), "Unable to save integrity record")
+}
+
+// VerifyRecording checks the recording against its integrity record, reporting the first line
+// that has changed since the recording was sealed. If trustedKey is provided, the recording must
+// also be signed by that key.
+func VerifyRecording(path string, trustedKey ed25519.PublicKey) (Verification, error) {
+	result := Verification{FilePath: path}
+	record, err := loadIntegrityRecord(path)
+	if err != nil {
+		return result, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	// the header is the first line of the recording, but the last link in the chain
+	var chain write.HashChain
+	var header []byte
+	events := len(record.Links) - 1
+	err = forEachLine(f, func(line []byte) {
+		result.Lines++
+		if result.Lines == 1 {
+			header = line
+			return
+		}
+		link := hex.EncodeToString(chain.Add(line))
+		if result.TamperedLine != 0 {
+			return
+		}
+		if chain.Len() > events {
+			result.TamperedLine = result.Lines
+			result.Problem = "Events were added after the recording was sealed"
+		} else if link != record.Links[chain.Len()-1] {
+			result.TamperedLine = result.Lines
+			result.Problem = "The line differs from when the recording was sealed"
+		} else {
+			return
+		}
+		result.TamperedOffset = eventOffset(line)
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "Unable to read recording")
+	}
+	if result.TamperedLine == 0 && result.Lines < len(record.Links) {
+		result.TamperedLine = result.Lines + 1
+		result.Problem = fmt.Sprintf("The recording was truncated (%v lines are missing)", len(record.Links)-result.Lines)
+	} else if result.TamperedLine == 0 && hex.EncodeToString(chain.Add(header)) != record.Links[events] {
+		result.TamperedLine = 1
+		result.Problem = "The header differs from when the recording was sealed"
+	}
+
+	if record.Signature != "" {
+		result.Signed = true
+		publicKey, errKey := base64.StdEncoding.DecodeString(record.PublicKey)
+		signature, errSig := base64.StdEncoding.DecodeString(record.Signature)
+		if errKey == nil && errSig == nil && len(publicKey) == ed25519.PublicKeySize {
+			result.KeyFingerprint = KeyFingerprint(publicKey)
+			result.SignatureValid = ed25519.Verify(publicKey, signedMessage(record.Links), signature)
+			result.KeyTrusted = trustedKey != nil && bytes.Equal(publicKey, trustedKey)
+		}
+	}
+
+	switch {
+	case result.TamperedLine != 0:
+		// already described
+	case result.Signed && !result.SignatureValid:
+		result.Problem = "The integrity record's signature is invalid (the integrity record was changed)"
+	case trustedKey != nil && !result.Signed:
+		result.Problem = "The recording is not signed"
+	case trustedKey != nil && !result.KeyTrusted:
+		result.Problem = "The recording was signed by a different key (" + result.KeyFingerprint + ")"
+	default:
+		result.Intact = true
+	}
+	return result, nil
+}
+
+// loadIntegrityRecord reads the integrity record saved alongside the recording. Returns
+// ErrNotSealed if there is none.
+func loadIntegrityRecord(path string) (IntegrityRecord, error) {
+	var record IntegrityRecord
+	data, err := ioutil.ReadFile(IntegrityPath(path))
+	if os.IsNotExist(err) {
+		return record, ErrNotSealed
+	} else if err != nil {
+		return record, errors.Wrap(err, "Unable to read integrity record")
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, errors.Wrap(err, "Unable to read integrity record")
+	}
+	if record.Version != integrityVersion {
+		return record, fmt.Errorf("Unsupported integrity record version: %v", record.Version)
+	}
+	if len(record.Links) == 0 {
+		return record, errors.New("The integrity record is empty")
+	}
+	return record, nil
+}
+
+// KeyFingerprint summarizes a public key, in the style of ssh-keygen (i.e. SHA256:...)
+func KeyFingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// ParsePublicKey reads a PEM encoded (PKIX) ed25519 public key, e.g. from
+// "openssl pkey -in key.pem -pubout"
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("The public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse the public key")
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("The public key is not an ed25519 key")
+	}
+	return publicKey, nil
+}
+
+// forEachLine calls fn with each line of the recording (including its newline)
+func forEachLine(r io.Reader, fn func(line []byte)) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			fn(line)
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// signedMessage is what is signed: the final link, and the number of links, so that neither the
+// end of the chain nor its length can be changed
+func signedMessage(links []string) []byte {
+	final := ""
+	if len(links) > 0 {
+		final = links[len(links)-1]
+	}
+	return []byte(fmt.Sprintf("aterm hash chain v%v\n%v\n%v", integrityVersion, len(links), final))
+}
+
+// eventOffset returns the time of the event on the given line, if the line is a readable event
+func eventOffset(line []byte) *float64 {
+	var event []interface{}
+	if err := json.Unmarshal(line, &event); err != nil || len(event) == 0 {
+		return nil
+	}
+	if seconds, ok := event[0].(float64); ok {
+		return &seconds
+	}
+	return nil
+}
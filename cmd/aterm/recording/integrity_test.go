@@ -0,0 +1,162 @@
+package recording
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/common"
+	"github.com/theparanoids/aterm/formatters"
+	"github.com/theparanoids/aterm/write"
+)
+
+const sealedCast = "{\"version\":2,\"width\":80,\"height\":24}\n" +
+	"[0.5,\"o\",\"$ \"]\n" +
+	"[1.25,\"o\",\"whoami\\r\\n\"]\n" +
+	"[2,\"o\",\"root\\r\\n\"]\n"
+
+// sealCast seals the recording as if the given content had been written by a StreamingFileWriter
+func sealCast(t *testing.T, path, content string, key ed25519.PrivateKey) {
+	lines := strings.SplitAfter(content, "\n")
+	var chain write.HashChain
+	for _, line := range lines[1:] {
+		if line != "" {
+			chain.Add([]byte(line))
+		}
+	}
+	chain.Add([]byte(lines[0]))
+	require.NoError(t, SealRecording(path, &chain, key))
+}
+
+func TestVerifyRecording(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	writeCast := func(content string) {
+		require.NoError(t, ioutil.WriteFile(castPath, []byte(content), 0600))
+	}
+	writeCast(sealedCast)
+
+	_, err := VerifyRecording(castPath, nil)
+	require.Equal(t, ErrNotSealed, err)
+
+	sealCast(t, castPath, sealedCast, nil)
+	result, err := VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.True(t, result.Intact)
+	require.Equal(t, 4, result.Lines)
+	require.False(t, result.Signed)
+
+	// changed events are reported, along with when they occurred
+	writeCast(strings.Replace(sealedCast, "whoami", "id", 1))
+	result, err = VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.False(t, result.Intact)
+	require.Equal(t, 3, result.TamperedLine)
+	require.Equal(t, 1.25, *result.TamperedOffset)
+
+	writeCast(sealedCast + "[3,\"o\",\"extra\"]\n")
+	result, err = VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.Equal(t, 5, result.TamperedLine)
+
+	writeCast(strings.TrimSuffix(sealedCast, "[2,\"o\",\"root\\r\\n\"]\n"))
+	result, err = VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.Equal(t, 4, result.TamperedLine)
+	require.Contains(t, result.Problem, "truncated")
+
+	// unsigned recordings do not satisfy an expected key
+	writeCast(sealedCast)
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	result, err = VerifyRecording(castPath, publicKey)
+	require.NoError(t, err)
+	require.False(t, result.Intact)
+}
+
+func TestVerifySignedRecording(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(sealedCast), 0600))
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	sealCast(t, castPath, sealedCast, privateKey)
+	result, err := VerifyRecording(castPath, publicKey)
+	require.NoError(t, err)
+	require.True(t, result.Intact)
+	require.True(t, result.SignatureValid)
+	require.True(t, result.KeyTrusted)
+	require.Equal(t, KeyFingerprint(publicKey), result.KeyFingerprint)
+
+	result, err = VerifyRecording(castPath, otherKey)
+	require.NoError(t, err)
+	require.False(t, result.Intact)
+	require.True(t, result.SignatureValid)
+	require.False(t, result.KeyTrusted)
+
+	// rebuilding the chain to hide a change invalidates the signature
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(strings.Replace(sealedCast, "root", "user", 1)), 0600))
+	data, err := ioutil.ReadFile(IntegrityPath(castPath))
+	require.NoError(t, err)
+	var record IntegrityRecord
+	require.NoError(t, json.Unmarshal(data, &record))
+	sealCast(t, castPath, strings.Replace(sealedCast, "root", "user", 1), nil)
+	data, err = ioutil.ReadFile(IntegrityPath(castPath))
+	require.NoError(t, err)
+	var forged IntegrityRecord
+	require.NoError(t, json.Unmarshal(data, &forged))
+	forged.PublicKey, forged.Signature = record.PublicKey, record.Signature
+	data, err = json.Marshal(forged)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(IntegrityPath(castPath), data, 0600))
+
+	result, err = VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.False(t, result.Intact)
+	require.Equal(t, 0, result.TamperedLine)
+	require.False(t, result.SignatureValid)
+}
+
+func TestSealUsesWrittenContent(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := write.NewStreamingFileWriter(dir, "rec.cast", formatters.ASCIICast, false)
+	require.NoError(t, err)
+	chain := writer.ChainLines()
+	writer.WriteHeader(formatters.Metadata{StartTimeUnix: 100})
+	writer.WriteEvent(common.Event{Type: "o", Data: "root", When: time.Second})
+
+	// the recording is changed from within the recording, before it is sealed
+	content, err := ioutil.ReadFile(writer.Filepath())
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(writer.Filepath(), []byte(strings.Replace(string(content), "root", "user", 1)), 0600))
+
+	writer.WriteEvent(common.Event{Type: "o", Data: "$ ", When: 2 * time.Second})
+	writer.WriteFooter(formatters.Metadata{StartTimeUnix: 100, DurationSeconds: 2})
+	require.NoError(t, writer.Close())
+	require.NoError(t, SealRecording(writer.Filepath(), chain, nil))
+
+	result, err := VerifyRecording(writer.Filepath(), nil)
+	require.NoError(t, err)
+	require.False(t, result.Intact)
+	require.Equal(t, 2, result.TamperedLine)
+	require.Equal(t, 1.0, *result.TamperedOffset)
+}
+
+func TestVerifyRecordingHeader(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(sealedCast), 0600))
+	sealCast(t, castPath, sealedCast, nil)
+
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(strings.Replace(sealedCast, "80", "81", 1)), 0600))
+	result, err := VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.False(t, result.Intact)
+	require.Equal(t, 1, result.TamperedLine)
+	require.Nil(t, result.TamperedOffset)
+}
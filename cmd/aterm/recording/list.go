@@ -0,0 +1,63 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecordingSummary describes a recording found on disk
+type RecordingSummary struct {
+	FilePath      string    `json:"filePath"`
+	OperationSlug string    `json:"operationSlug"`
+	Description   string    `json:"description"`
+	Uploaded      bool      `json:"uploaded"`
+	EvidenceUUID  string    `json:"evidenceUuid,omitempty"`
+	Size          int64     `json:"size"`
+	ModifiedAt    time.Time `json:"modifiedAt"`
+}
+
+// FindRecordings locates every recording (.cast file) under the given directory, newest first.
+// Details are taken from each recording's saved metadata, where present. Recordings without
+// metadata use the name of their directory as the operation slug, as recordings are stored in
+// per-operation directories.
+func FindRecordings(dir string) ([]RecordingSummary, error) {
+	summaries := []RecordingSummary{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // skip unreadable entries
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".cast") {
+			return nil
+		}
+		summary := RecordingSummary{
+			FilePath:      path,
+			OperationSlug: filepath.Base(filepath.Dir(path)),
+			Size:          info.Size(),
+			ModifiedAt:    info.ModTime(),
+		}
+		if metadata, err := LoadMetadata(path); err == nil {
+			summary.Uploaded = metadata.Uploaded
+			summary.EvidenceUUID = metadata.EvidenceUUID
+			summary.Description = metadata.Description
+			if metadata.OperationSlug != "" {
+				summary.OperationSlug = metadata.OperationSlug
+			}
+		}
+		summaries = append(summaries, summary)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return summaries, nil
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].ModifiedAt.After(summaries[j].ModifiedAt)
+	})
+	return summaries, err
+}
@@ -0,0 +1,141 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/theparanoids/ashirt-server/backend/dtos"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/eventers"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+// RecordingMetadata captures the details of a recording needed to upload it to ASHIRT. This is
+// saved alongside the recording (see MetadataPath)
+type RecordingMetadata struct {
+	Uploaded      bool   `json:"uploaded"`
+	FilePath      string `json:"filePath"`
+	OperationSlug string `json:"operationSlug"`
+	Description   string `json:"description"`
+	// Title is the title noted in the recording header, if one was set (see SetRecordingTitle)
+	Title        string     `json:"title,omitempty"`
+	SelectedTags []dtos.Tag `json:"selectedTags"`
+	// EvidenceUUID identifies the evidence created when the recording was uploaded. This is unset
+	// for recordings uploaded by older versions.
+	EvidenceUUID string `json:"evidenceUuid,omitempty"`
+	// ContentHash is the SHA-256 hash of the recording's events (see HashRecording), taken when the
+	// recording was completed, and again when it is uploaded
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// The below describe how the recorded shell (or command) ended. These are unset for
+	// recordings made by older versions.
+	Command         string    `json:"command,omitempty"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds,omitempty"`
+	ExitCode        *int      `json:"exitCode,omitempty"`
+	ExitSignal      string    `json:"exitSignal,omitempty"`
+
+	// Commands lists the commands run within the recording, when shell integration is in use
+	Commands []eventers.ShellCommand `json:"commands,omitempty"`
+	// Context describes where, and by whom, the recording was made (see GatherContext)
+	Context *formatters.RecordingContext `json:"context,omitempty"`
+}
+
+// ApplyOutput copies the details of a completed recording into the metadata
+func (m *RecordingMetadata) ApplyOutput(output RecordingOutput) {
+	exitCode := output.ExitCode
+	m.FilePath = output.FilePath
+	m.Command = output.Command
+	m.Title = output.Title
+	m.StartedAt = output.StartTime
+	m.DurationSeconds = output.Duration.Seconds()
+	m.ExitCode = &exitCode
+	m.ExitSignal = output.ExitSignal
+	m.Commands = output.Commands
+	m.ContentHash = output.ContentHash
+	if !output.Context.IsEmpty() {
+		context := output.Context
+		m.Context = &context
+	}
+}
+
+// ExitSummary describes how the recorded shell or command ended, e.g. "exited with code 1 after
+// 2m3s". Returns an empty string if this is not known.
+func (m RecordingMetadata) ExitSummary() string {
+	if m.ExitCode == nil {
+		return ""
+	}
+	duration := time.Duration(m.DurationSeconds * float64(time.Second)).Round(time.Second)
+	if m.ExitSignal != "" {
+		return fmt.Sprintf("was terminated (%v) after %v", m.ExitSignal, duration)
+	}
+	return fmt.Sprintf("exited with code %v after %v", *m.ExitCode, duration)
+}
+
+// metadataSuffix is appended to a recording's path to find its metadata
+const metadataSuffix = ".recordingmeta.json"
+
+// MetadataPath returns where the metadata for the given recording is saved
+func MetadataPath(recordingPath string) string {
+	return recordingPath + metadataSuffix
+}
+
+// companionPaths lists the files kept alongside the given recording
+func companionPaths(recordingPath string) []string {
+	return []string{MetadataPath(recordingPath), IntegrityPath(recordingPath)}
+}
+
+// MoveRecording renames the recording, along with the files kept alongside it (e.g. its metadata
+// and integrity record), so that they continue to describe the recording. The saved metadata is
+// updated to note the new path.
+func MoveRecording(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	var result error
+	newPaths := companionPaths(to)
+	for i, path := range companionPaths(from) {
+		if err := os.Rename(path, newPaths[i]); err != nil && !os.IsNotExist(err) {
+			result = errors.Append(result, err)
+		}
+	}
+	if metadata, err := LoadMetadata(to); err == nil {
+		metadata.FilePath = to
+		result = errors.Append(result, SaveMetadata(metadata))
+	}
+	return result
+}
+
+// DeleteRecording removes the recording, along with the files kept alongside it
+func DeleteRecording(path string) error {
+	result := os.Remove(path)
+	for _, companion := range companionPaths(path) {
+		if err := os.Remove(companion); err != nil && !os.IsNotExist(err) {
+			result = errors.Append(result, err)
+		}
+	}
+	return result
+}
+
+// SaveMetadata writes the provided metadata alongside its recording
+func SaveMetadata(metadata RecordingMetadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(MetadataPath(metadata.FilePath), data, 0600)
+}
+
+// LoadMetadata reads the metadata saved alongside the given recording
+func LoadMetadata(recordingPath string) (RecordingMetadata, error) {
+	var metadata RecordingMetadata
+	data, err := ioutil.ReadFile(MetadataPath(recordingPath))
+	if err != nil {
+		return metadata, err
+	}
+	err = json.Unmarshal(data, &metadata)
+	return metadata, err
+}
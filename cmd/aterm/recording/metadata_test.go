@@ -0,0 +1,48 @@
+package recording
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveRecording(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "rec.cast"), filepath.Join(dir, "renamed.cast")
+	require.NoError(t, ioutil.WriteFile(from, []byte(sealedCast), 0600))
+	require.NoError(t, SaveMetadata(RecordingMetadata{FilePath: from, Description: "ran whoami"}))
+	sealCast(t, from, sealedCast, nil)
+
+	require.NoError(t, MoveRecording(from, to))
+	require.NoFileExists(t, from)
+	require.NoFileExists(t, MetadataPath(from))
+	require.FileExists(t, to)
+	metadata, err := LoadMetadata(to)
+	require.NoError(t, err)
+	require.Equal(t, to, metadata.FilePath)
+	require.Equal(t, "ran whoami", metadata.Description)
+	require.NoFileExists(t, IntegrityPath(from))
+	result, err := VerifyRecording(to, nil)
+	require.NoError(t, err)
+	require.True(t, result.Intact)
+
+	// recordings without metadata move alone
+	require.NoError(t, ioutil.WriteFile(from, []byte(sealedCast), 0600))
+	require.NoError(t, MoveRecording(from, filepath.Join(dir, "bare.cast")))
+	require.NoFileExists(t, MetadataPath(filepath.Join(dir, "bare.cast")))
+}
+
+func TestDeleteRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(path, []byte(sealedCast), 0600))
+	require.NoError(t, SaveMetadata(RecordingMetadata{FilePath: path}))
+	sealCast(t, path, sealedCast, nil)
+
+	require.NoError(t, DeleteRecording(path))
+	require.NoFileExists(t, path)
+	require.NoFileExists(t, MetadataPath(path))
+	require.NoFileExists(t, IntegrityPath(path))
+	require.Error(t, DeleteRecording(path))
+}
@@ -0,0 +1,171 @@
+package recording
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/aterm/common"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+// RecordingMarker is a point of interest within a recording, such as the start or end of a command
+// (see ShellIntegrationScript). Seconds is relative to the start of the recording.
+type RecordingMarker struct {
+	Seconds float64 `json:"offsetSeconds"`
+	Label   string  `json:"label"`
+}
+
+// OutputSpan selects part of a recording's output, in seconds relative to the start of the
+// recording. An End of zero selects all output after Start.
+type OutputSpan struct {
+	Start float64
+	End   float64
+}
+
+// String formats the span as a range of offsets (e.g. 0:05-1:10)
+func (span OutputSpan) String() string {
+	if span.End == 0 {
+		return FormatOffset(span.Start) + "-end"
+	}
+	return FormatOffset(span.Start) + "-" + FormatOffset(span.End)
+}
+
+// ReadMarkers reads the markers in the recording at the given path, in order
+func ReadMarkers(path string) ([]RecordingMarker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader, err := formatters.NewASCIICastReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	markers := []RecordingMarker{}
+	for {
+		evt, err := reader.Next()
+		if err != nil {
+			break
+		}
+		if evt.Type == string(common.Marker) {
+			markers = append(markers, RecordingMarker{Seconds: evt.When, Label: evt.Data})
+		}
+	}
+	return markers, nil
+}
+
+// MarkerSpan selects the output between a marker and the marker that follows it (or the end of the
+// recording). The marker is found by label (ignoring case), or failing that, by its position
+// (starting from 1). As shell integration marks both the start and end of each command, the span
+// starting at a command's marker holds that command's output.
+func MarkerSpan(markers []RecordingMarker, labelOrNumber string) (OutputSpan, RecordingMarker, error) {
+	index := -1
+	for i, m := range markers {
+		if strings.EqualFold(m.Label, labelOrNumber) {
+			index = i
+			break
+		}
+	}
+	if n, err := strconv.Atoi(labelOrNumber); index == -1 && err == nil && n >= 1 && n <= len(markers) {
+		index = n - 1
+	}
+	if index == -1 {
+		return OutputSpan{}, RecordingMarker{}, fmt.Errorf("No marker found matching %q", labelOrNumber)
+	}
+
+	return SpanFollowing(markers, index), markers[index], nil
+}
+
+// SpanFollowing selects the output between the marker at the given index and the next marker (or
+// the end of the recording)
+func SpanFollowing(markers []RecordingMarker, index int) OutputSpan {
+	span := OutputSpan{Start: markers[index].Seconds}
+	if index+1 < len(markers) {
+		span.End = markers[index+1].Seconds
+	}
+	return span
+}
+
+// RenderOutput renders the output within the span of the recording at the given path as plain
+// text, as it was displayed (see terminalScreen). Earlier output is also processed, so that cursor
+// movement within the span is rendered correctly, but only lines from where the cursor was at the
+// start of the span are included. Returns an empty string if there is no output within the span.
+func RenderOutput(path string, span OutputSpan) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	reader, err := formatters.NewASCIICastReader(f)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to read recording")
+	}
+
+	screen := newTerminalScreen(int(reader.Header.Width), int(reader.Header.Height))
+	fromLine := -1
+	for {
+		evt, err := reader.Next()
+		if err != nil {
+			break // as with readOutputText, unreadable events end the recording
+		}
+		if evt.Type != "o" {
+			continue
+		}
+		if span.End > 0 && evt.When > span.End {
+			break
+		}
+		if fromLine == -1 && evt.When >= span.Start {
+			fromLine = screen.mainRow()
+		}
+		screen.write(evt.Data)
+	}
+	if fromLine == -1 {
+		return "", nil
+	}
+	return screen.text(fromLine), nil
+}
+
+// ParseOffset parses an offset into a recording, as seconds (90), a duration (1m30s), or minutes
+// and seconds (1:30, or 1:01:30 with hours)
+func ParseOffset(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil && seconds >= 0 && !math.IsInf(seconds, 0) {
+		return seconds, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d.Seconds(), nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 || len(parts) == 3 {
+		seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+		valid := err == nil && seconds >= 0 && seconds < 60
+		multiplier := 60.0
+		for i := len(parts) - 2; i >= 0; i-- {
+			n, err := strconv.Atoi(parts[i])
+			valid = valid && err == nil && n >= 0
+			seconds += float64(n) * multiplier
+			multiplier *= 60
+		}
+		if valid {
+			return seconds, nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid offset %q (expected e.g. 90, 1m30s or 1:30)", s)
+}
+
+// FormatOffset formats an offset into a recording as minutes and seconds (or hours, minutes and
+// seconds), e.g. 1:30
+func FormatOffset(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
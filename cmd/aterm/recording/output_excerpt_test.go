@@ -0,0 +1,96 @@
+package recording
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func renderScreen(width, height int, writes ...string) string {
+	screen := newTerminalScreen(width, height)
+	for _, w := range writes {
+		screen.write(w)
+	}
+	return screen.text(0)
+}
+
+func TestTerminalScreen(t *testing.T) {
+	// line editing: backspace, carriage return and erase to end of line
+	require.Equal(t, "$ ls -la\nfile", renderScreen(80, 24, "$ lx\b\x1b[Ks -la\r\n", "fiXX\r", "file\x1b[K"))
+	// cursor movement, with parameters and sequences split across writes
+	require.Equal(t, "a  b\nccc", renderScreen(80, 24, "\r\nccc\x1b[1", "A\x1b[1Ga\x1b[4Gb"))
+	// wrapping at the screen width
+	require.Equal(t, "abcd\nef", renderScreen(4, 24, "abcdef"))
+	// characters split across writes
+	require.Equal(t, "héllo", renderScreen(80, 24, "h\xc3", "\xa9llo"))
+	// formatting and window titles are ignored
+	require.Equal(t, "red", renderScreen(80, 24, "\x1b]0;title\a\x1b[31mred\x1b[0m"))
+	// cleared output is kept
+	require.Equal(t, "one\ntwo", renderScreen(80, 24, "one\r\n\x1b[H\x1b[2Jtwo"))
+	// the alternate screen is discarded
+	require.Equal(t, "$ vim\n$", renderScreen(80, 24, "$ vim\r\n\x1b[?1049h\x1b[Hediting\x1b[?1049l$"))
+	// lines scrolled off the screen are kept
+	require.Equal(t, "1\n2\n3\n4", renderScreen(80, 2, "1\r\n2\r\n3\r\n\x1b[A\x1b[A\x1b[B4"))
+	// deleting and inserting characters
+	require.Equal(t, "ax cd", renderScreen(80, 24, "abcd\x1b[3D\x1b[P\x1b[2@x"))
+}
+
+func TestRenderOutput(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(
+		"{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":1000}\n"+
+			"[0.5,\"o\",\"$ \"]\n"+
+			"[1,\"o\",\"nmap -sV host\\r\\n\"]\n"+
+			"[1,\"m\",\"nmap -sV host\"]\n"+
+			"[2,\"o\",\"Starting Nmap\\r\\n\"]\n"+
+			"[3,\"o\",\"Progress: 10%\\rProgress: 100%\\r\\n\"]\n"+
+			"[4,\"m\",\"exit 0\"]\n"+
+			"[4,\"o\",\"$ \"]\n"+
+			"[5,\"o\",\"exit\\r\\n\"]\n"), 0600))
+
+	markers, err := ReadMarkers(castPath)
+	require.NoError(t, err)
+	require.Equal(t, []RecordingMarker{{Seconds: 1, Label: "nmap -sV host"}, {Seconds: 4, Label: "exit 0"}}, markers)
+
+	span, marker, err := MarkerSpan(markers, "NMAP -sV host")
+	require.NoError(t, err)
+	require.Equal(t, OutputSpan{Start: 1, End: 4}, span)
+	require.Equal(t, "nmap -sV host", marker.Label)
+	span, _, err = MarkerSpan(markers, "2")
+	require.NoError(t, err)
+	require.Equal(t, OutputSpan{Start: 4}, span)
+	_, _, err = MarkerSpan(markers, "3")
+	require.Error(t, err)
+
+	text, err := RenderOutput(castPath, OutputSpan{Start: 2, End: 3.5})
+	require.NoError(t, err)
+	require.Equal(t, "Starting Nmap\nProgress: 100%", text)
+
+	text, err = RenderOutput(castPath, OutputSpan{})
+	require.NoError(t, err)
+	require.Equal(t, "$ nmap -sV host\nStarting Nmap\nProgress: 100%\n$ exit", text)
+
+	text, err = RenderOutput(castPath, OutputSpan{Start: 10})
+	require.NoError(t, err)
+	require.Equal(t, "", text)
+}
+
+func TestParseOffset(t *testing.T) {
+	for input, expected := range map[string]float64{
+		"90": 90, "1.5": 1.5, "1m30s": 90, "1:30": 90, "1:01:30": 3690, "0:00": 0,
+	} {
+		actual, err := ParseOffset(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, actual, input)
+	}
+	for _, input := range []string{"", "-1", "1:60", "a:30", "1:2:3:4", "soon"} {
+		_, err := ParseOffset(input)
+		require.Error(t, err, input)
+	}
+
+	require.Equal(t, "1:30", FormatOffset(90.7))
+	require.Equal(t, "1:01:30", FormatOffset(3690))
+	require.Equal(t, "0:05-end", OutputSpan{Start: 5}.String())
+}
@@ -0,0 +1,125 @@
+package recording
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputLine is a single line of text output, as found in a recording. Seconds is relative to the
+// start of the recording, and marks when the line started.
+type OutputLine struct {
+	Seconds float64 `json:"t"`
+	Text    string  `json:"text"`
+}
+
+// textState tracks which part of an escape sequence (if any) the textExtractor is within
+type textState int
+
+const (
+	textNormal textState = iota
+	textEscape
+	textEscapeIntermediate
+	textCSI
+	textString
+	textStringEscape
+)
+
+// textExtractor converts raw terminal output into plain lines of text. Escape sequences and
+// control characters are removed. Text following a carriage return replaces the line so far (e.g.
+// for progress bars), but other cursor movement is ignored, so the text may differ from what was
+// displayed. Sequences may be split across writes.
+type textExtractor struct {
+	state textState
+	// returned notes a carriage return, after which the next text replaces the line
+	returned  bool
+	line      strings.Builder
+	lineStart float64
+	lines     []OutputLine
+}
+
+// write processes the output of a single event, which occurred at the given time
+func (x *textExtractor) write(seconds float64, data string) {
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch x.state {
+		case textNormal:
+			switch {
+			case b == '\x1b':
+				x.state = textEscape
+			case b == '\n':
+				x.endLine()
+			case b == '\r':
+				x.returned = true
+			case b == '\b' && x.returned:
+				// already at the start of the line
+			case b == '\b':
+				s := x.line.String()
+				_, size := utf8.DecodeLastRuneInString(s)
+				x.line.Reset()
+				x.line.WriteString(s[:len(s)-size])
+			case b == '\t' || b >= 0x20 && b != 0x7f:
+				if x.returned {
+					x.line.Reset()
+					x.returned = false
+				}
+				if x.line.Len() == 0 {
+					x.lineStart = seconds
+				}
+				x.line.WriteByte(b)
+			}
+
+		case textEscape:
+			switch b {
+			case '[':
+				x.state = textCSI
+			case ']', 'P', 'X', '^', '_':
+				x.state = textString
+			case '(', ')', '*', '+', '#', '%':
+				x.state = textEscapeIntermediate
+			default:
+				x.state = textNormal
+			}
+
+		case textEscapeIntermediate:
+			x.state = textNormal
+
+		case textCSI:
+			if b >= 0x40 && b <= 0x7e {
+				x.state = textNormal
+			}
+
+		case textString:
+			if b == '\a' {
+				x.state = textNormal
+			} else if b == '\x1b' {
+				x.state = textStringEscape
+			}
+
+		case textStringEscape:
+			if b == '\\' {
+				x.state = textNormal
+			} else {
+				x.state = textString
+			}
+		}
+	}
+}
+
+// endLine keeps the current line, if it has any content
+func (x *textExtractor) endLine() {
+	text := strings.TrimSpace(x.line.String())
+	x.line.Reset()
+	x.returned = false
+	if text != "" {
+		x.lines = append(x.lines, OutputLine{Seconds: x.lineStart, Text: text})
+	}
+}
+
+// finish returns all of the lines found, including any unterminated final line
+func (x *textExtractor) finish() []OutputLine {
+	x.endLine()
+	if x.lines == nil {
+		return []OutputLine{}
+	}
+	return x.lines
+}
@@ -0,0 +1,68 @@
+package recording
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+// PlaybackOptions controls how a recording is replayed
+type PlaybackOptions struct {
+	// Speed multiplies the playback rate (e.g. 2 plays back twice as fast). Values <= 0 are
+	// treated as 1.
+	Speed float64
+	// MaxIdle limits how long playback pauses between events. Zero applies the recording's own
+	// idle_time_limit, if any.
+	MaxIdle time.Duration
+}
+
+// Play replays the output events of the asciicast recording in r to w, honoring the original
+// timing. Playback stops early (returning the context's error) if the context is cancelled.
+func Play(ctx context.Context, r io.Reader, w io.Writer, opts PlaybackOptions) error {
+	reader, err := formatters.NewASCIICastReader(r)
+	if err != nil {
+		return errors.Wrap(err, "Unable to read recording")
+	}
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	maxIdle := opts.MaxIdle
+	if maxIdle == 0 && reader.Header.IdleTimeLimit > 0 {
+		maxIdle = time.Duration(reader.Header.IdleTimeLimit * float64(time.Second))
+	}
+
+	var lastEventAt float64
+	for {
+		evt, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "Unable to read recording")
+		}
+		if evt.Type != "o" {
+			continue
+		}
+
+		delay := time.Duration((evt.When - lastEventAt) * float64(time.Second))
+		lastEventAt = evt.When
+		if maxIdle > 0 && delay > maxIdle {
+			delay = maxIdle
+		}
+		if delay > 0 {
+			timer := time.NewTimer(time.Duration(float64(delay) / speed))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if _, err = io.WriteString(w, evt.Data); err != nil {
+			return err
+		}
+	}
+}
@@ -43,11 +43,17 @@ func NewPtyTracker(termOut, readOut io.Writer, termInput io.Reader, onReadyFunc
 	}
 }
 
-// Run starts the pty session
+// Run starts the pty session, running the provided shell
 func (t *PtyTracker) Run(shell string) error {
+	return t.RunCommand(exec.Command(shell))
+}
+
+// RunCommand starts the pty session, running the provided (unstarted) command. This returns once
+// the command exits. As with exec.Cmd.Wait, an *exec.ExitError is returned if the command does not
+// exit successfully.
+func (t *PtyTracker) RunCommand(c *exec.Cmd) error {
 	defer t.close()
 
-	c := exec.Command(shell)
 	var err error
 	t.Pty, err = pty.Start(c)
 	if err != nil {
@@ -62,7 +68,7 @@ func (t *PtyTracker) Run(shell string) error {
 	go func() { io.Copy(t.Pty, wrappedStdin) }()
 	io.Copy(t.termOut, t.Pty)
 
-	return nil
+	return c.Wait()
 }
 
 // Close performs all of the closes necessary to restore the system back to a good state.
@@ -0,0 +1,147 @@
+package recording
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables set for every recorded process, so that shell rc files and tools can
+// detect that they are being recorded
+const (
+	EnvRecording     = "ATERM_RECORDING"
+	EnvRecordingFile = "ATERM_RECORDING_FILE"
+	EnvPromptMarker  = "ATERM_PROMPT_MARKER"
+)
+
+// childEnvironment builds the environment for the recorded process: aterm's own environment,
+// followed by the configured extra variables, and finally the variables describing the recording.
+// Later entries replace earlier ones (see exec.Cmd.Env)
+func childEnvironment(base []string, extra map[string]string, filePath, promptMarker string) []string {
+	env := append([]string{}, base...)
+	for k, v := range extra {
+		env = append(env, k+"="+v)
+	}
+	env = append(env, EnvRecording+"=1", EnvRecordingFile+"="+filePath)
+	if promptMarker != "" {
+		env = append(env, EnvPromptMarker+"="+promptMarker)
+	}
+	return env
+}
+
+// lookupEnv finds the value of the named variable in an environment list (as used by exec.Cmd.Env).
+// The last matching entry wins.
+func lookupEnv(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if kv := strings.SplitN(env[i], "=", 2); len(kv) == 2 && kv[0] == name {
+			return kv[1], true
+		}
+	}
+	return "", false
+}
+
+// headerEnv selects the named variables from the environment, for noting in the recording header
+func headerEnv(env []string, names []string) map[string]string {
+	selected := map[string]string{}
+	for _, name := range names {
+		if v, ok := lookupEnv(env, name); ok {
+			selected[name] = v
+		}
+	}
+	return selected
+}
+
+// customizeShell alters an (unstarted) interactive shell so that its prompt begins with the prompt
+// marker (available to the shell as ATERM_PROMPT_MARKER), and/or so that the shell integration
+// script (see ShellIntegrationScript) is loaded. bash and zsh are started with a generated rc file,
+// which loads the user's usual rc file before making these changes. fish is given extra init
+// commands. Other shells only support the prompt marker, by having PS1 set directly (which may be
+// overridden by their rc files). The returned function removes any generated files, and should be
+// called once the shell exits.
+func customizeShell(c *exec.Cmd, shell string, promptMarker, integration bool) (func(), error) {
+	noop := func() {}
+	shellName := filepath.Base(shell)
+	var extraRC string
+	if integration {
+		script, err := ShellIntegrationScript(shellName)
+		if err != nil {
+			return noop, err
+		}
+		extraRC = script
+	}
+
+	switch shellName {
+	case "bash":
+		if promptMarker {
+			extraRC = bashPromptRC + extraRC
+		}
+		dir, err := ioutil.TempDir("", "aterm-bash")
+		if err != nil {
+			return noop, err
+		}
+		rcPath := filepath.Join(dir, "bashrc")
+		err = ioutil.WriteFile(rcPath, []byte(bashRC+extraRC), 0600)
+		c.Args = append(c.Args, "--rcfile", rcPath)
+		return func() { os.RemoveAll(dir) }, err
+
+	case "zsh":
+		if promptMarker {
+			extraRC = zshPromptRC + extraRC
+		}
+		dir, err := ioutil.TempDir("", "aterm-zsh")
+		if err != nil {
+			return noop, err
+		}
+		originalZDotDir, ok := lookupEnv(c.Env, "ZDOTDIR")
+		if !ok {
+			originalZDotDir, _ = lookupEnv(c.Env, "HOME")
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, ".zshenv"), []byte(zshEnv), 0600)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, ".zshrc"), []byte(zshRC+extraRC), 0600)
+		}
+		c.Env = append(c.Env, "ATERM_ZDOTDIR="+originalZDotDir, "ZDOTDIR="+dir)
+		return func() { os.RemoveAll(dir) }, err
+
+	case "fish":
+		if promptMarker {
+			c.Args = append(c.Args, "--init-command", fishPromptInit)
+		}
+		if extraRC != "" {
+			c.Args = append(c.Args, "--init-command", extraRC)
+		}
+		return noop, nil
+
+	default:
+		if promptMarker {
+			marker, _ := lookupEnv(c.Env, EnvPromptMarker)
+			c.Env = append(c.Env, "PS1="+marker+" $ ")
+		}
+		return noop, nil
+	}
+}
+
+const bashRC = `[ -f ~/.bashrc ] && . ~/.bashrc
+`
+
+const bashPromptRC = `PS1="${ATERM_PROMPT_MARKER} ${PS1}"
+`
+
+const zshEnv = `[ -f "${ATERM_ZDOTDIR}/.zshenv" ] && . "${ATERM_ZDOTDIR}/.zshenv"
+`
+
+const zshRC = `ZDOTDIR="${ATERM_ZDOTDIR}"
+unset ATERM_ZDOTDIR
+[ -f "${ZDOTDIR}/.zshrc" ] && . "${ZDOTDIR}/.zshrc"
+`
+
+const zshPromptRC = `PROMPT="${ATERM_PROMPT_MARKER} ${PROMPT}"
+`
+
+const fishPromptInit = `functions -c fish_prompt __aterm_fish_prompt
+function fish_prompt
+    printf '%s ' "$ATERM_PROMPT_MARKER"
+    __aterm_fish_prompt
+end`
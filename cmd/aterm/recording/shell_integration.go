@@ -0,0 +1,104 @@
+package recording
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// IntegratedShells lists the shells with integration scripts (see ShellIntegrationScript)
+var IntegratedShells = []string{"bash", "zsh", "fish"}
+
+// ShellIntegrationScript returns the integration script for the named shell (or path to a shell).
+// When loaded by a recorded shell, the script reports where each command starts and ends (and its
+// exit status) via OSC 133 escape sequences, so that commands can be found within recordings. The
+// script does nothing outside of a recording, so it is safe to add to the shell's rc file.
+func ShellIntegrationScript(shell string) (string, error) {
+	switch filepath.Base(shell) {
+	case "bash":
+		return bashIntegration, nil
+	case "zsh":
+		return zshIntegration, nil
+	case "fish":
+		return fishIntegration, nil
+	}
+	return "", fmt.Errorf("Shell integration is not available for %v (supported shells: bash, zsh, fish)", shell)
+}
+
+// bashIntegration reports commands via a DEBUG trap, which also fires for each PROMPT_COMMAND
+// entry. Commands are therefore only reported once __aterm_arm (the last PROMPT_COMMAND entry) has
+// run. Any existing DEBUG trap is run before ours. Note that bash hides the DEBUG trap from sourced
+// files (unless it was set within the same file), so a trap set before sourcing the script is
+// replaced.
+const bashIntegration = `# aterm shell integration (bash)
+if [ -n "$ATERM_RECORDING" ] && [ -z "$__aterm_integrated" ]; then
+    __aterm_integrated=1
+    __aterm_at_prompt=0
+    __aterm_preexec() {
+        [ "$__aterm_at_prompt" = 1 ] || return
+        [ -n "$COMP_LINE" ] && return
+        case "$BASH_COMMAND" in __aterm_precmd*) return ;; esac
+        __aterm_at_prompt=0
+        local cmd
+        cmd=$(HISTTIMEFORMAT= builtin history 1 | sed 's/^ *[0-9]*[* ] *//')
+        cmd=${cmd//[$'\a\e']/}
+        cmd=${cmd//$'\n'/ }
+        printf '\e]133;C;cmdline=%s\a' "$cmd"
+        __aterm_running=1
+    }
+    __aterm_precmd() {
+        local status=$?
+        __aterm_at_prompt=0
+        [ -n "$__aterm_running" ] && printf '\e]133;D;%s\a' "$status"
+        __aterm_running=
+        printf '\e]133;A\a'
+    }
+    __aterm_arm() {
+        __aterm_at_prompt=1
+    }
+    __aterm_capture_trap() { __aterm_prev_debug=$2; }
+    __aterm_prev_debug=$(builtin trap -p DEBUG)
+    eval "__aterm_capture_trap ${__aterm_prev_debug#trap }"
+    unset -f __aterm_capture_trap
+    trap "${__aterm_prev_debug:+$__aterm_prev_debug$'\n'}__aterm_preexec" DEBUG
+    if [[ "$(declare -p PROMPT_COMMAND 2>/dev/null)" == "declare -a"* ]]; then
+        PROMPT_COMMAND=(__aterm_precmd "${PROMPT_COMMAND[@]}" __aterm_arm)
+    else
+        PROMPT_COMMAND="__aterm_precmd${PROMPT_COMMAND:+$'\n'$PROMPT_COMMAND}"$'\n''__aterm_arm'
+    fi
+fi
+`
+
+const zshIntegration = `# aterm shell integration (zsh)
+if [[ -n "$ATERM_RECORDING" && -z "$__aterm_integrated" ]]; then
+    __aterm_integrated=1
+    __aterm_preexec() {
+        local cmd=${1//[$'\a\e']/}
+        cmd=${cmd//$'\n'/ }
+        printf '\e]133;C;cmdline=%s\a' "$cmd"
+        __aterm_running=1
+    }
+    __aterm_precmd() {
+        local exit_status=$?
+        [[ -n "$__aterm_running" ]] && printf '\e]133;D;%s\a' "$exit_status"
+        __aterm_running=
+        printf '\e]133;A\a'
+    }
+    preexec_functions=(__aterm_preexec $preexec_functions)
+    precmd_functions=(__aterm_precmd $precmd_functions)
+fi
+`
+
+const fishIntegration = `# aterm shell integration (fish)
+if set -q ATERM_RECORDING; and not set -q __aterm_integrated
+    set -g __aterm_integrated 1
+    function __aterm_preexec --on-event fish_preexec
+        printf '\e]133;C;cmdline=%s\a' (string replace -ra '[\a\e\n]' ' ' -- $argv[1])
+    end
+    function __aterm_postexec --on-event fish_postexec
+        printf '\e]133;D;%s\a' $status
+    end
+    function __aterm_prompt --on-event fish_prompt
+        printf '\e]133;A\a'
+    end
+end
+`
@@ -0,0 +1,53 @@
+package recording
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBashIntegrationWithPromptCommand(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	dir := t.TempDir()
+	trapped := filepath.Join(dir, "trapped")
+	rcPath := filepath.Join(dir, "bashrc")
+	rc := "PROMPT_COMMAND='__user_prompt=1'\n" +
+		"trap 'touch \"$ATERM_TEST_TRAPPED\"' DEBUG\n" +
+		bashIntegration
+	require.NoError(t, ioutil.WriteFile(rcPath, []byte(rc), 0600))
+
+	for _, promptCommand := range []string{"string", "array"} {
+		t.Run(promptCommand, func(t *testing.T) {
+			os.Remove(trapped)
+			script := rc
+			if promptCommand == "array" {
+				script = strings.Replace(rc, "PROMPT_COMMAND='__user_prompt=1'", "PROMPT_COMMAND=('__user_prompt=1' ':')", 1)
+			}
+			require.NoError(t, ioutil.WriteFile(rcPath, []byte(script), 0600))
+
+			c := exec.Command(bash, "--noprofile", "--rcfile", rcPath, "-i")
+			c.Env = append(os.Environ(), "ATERM_RECORDING=1", "HISTFILE=/dev/null", "ATERM_TEST_TRAPPED="+trapped)
+			c.Stdin = strings.NewReader("echo one\nfalse\n\necho two\n")
+			output, err := c.CombinedOutput()
+			require.NoError(t, err, string(output))
+
+			starts := regexp.MustCompile("\x1b]133;C;cmdline=([^\a]*)\a").FindAllStringSubmatch(string(output), -1)
+			commands := []string{}
+			for _, start := range starts {
+				commands = append(commands, start[1])
+			}
+			require.Equal(t, []string{"echo one", "false", "echo two"}, commands)
+			require.Contains(t, string(output), "\x1b]133;D;1\a")
+			require.FileExists(t, trapped, "the existing DEBUG trap should still run")
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package recording
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/creack/pty"
 	"github.com/jonboulle/clockwork"
@@ -30,6 +35,17 @@ var ErrNotInitialized = errors.New("Recordings have not been initialized")
 // FileName: The name of the file to be written
 // FileDir: Where the file should be stored
 // Shell: What shell to use for the PTY
+// Command: What command (and arguments) to run in the PTY, in place of the shell. Optional
+// WorkingDir: Where to start the shell/command. Optional (defaults to the current directory)
+// Title: The title noted in the recording header. Optional (defaults to when recording started)
+// Env: Extra environment variables for the shell/command
+// PromptMarker: Text to add to the start of the shell's prompt. Optional (not used for commands)
+// ShellIntegration: Load the shell integration script into the shell. Optional (not used for
+// commands)
+// HeaderEnv: Names of environment variables to note in the recording header, in addition to SHELL
+// and TERM
+// HashChain: Link each line into a hash chain as it is written, so that the recording can be
+// sealed (see SealRecording)
 // EventMiddleware: How to transform events that come through
 // OnRecordingStart: A hook into the recording process just before actual recording starts
 //
@@ -38,6 +54,15 @@ type RecordingInput struct {
 	FileName         string
 	FileDir          string
 	Shell            string
+	Command          []string
+	WorkingDir       string
+	Title            string
+	Env              map[string]string
+	PromptMarker     string
+	ShellIntegration bool
+	HeaderEnv        []string
+	HashChain        bool
+	Context          formatters.RecordingContext
 	TermInput        io.Reader
 	EventMiddleware  []eventers.EventMiddleware
 	OnRecordingStart func(RecordingOutput)
@@ -46,6 +71,28 @@ type RecordingInput struct {
 // RecordingOutput is a small structure for communicating in-progress or completed recording details
 type RecordingOutput struct {
 	FilePath string
+	// ExitCode is the exit code of the recorded shell or command, once it has completed. If the
+	// process was killed by a signal, this is 128 + the signal number (as shells report it)
+	ExitCode int
+	// ExitSignal names the signal that killed the recorded process, if any
+	ExitSignal string
+	// Command is the recorded command (see FormatCommand), or blank if the shell was recorded
+	Command string
+	// Title is the title noted in the recording header, or blank if none was provided
+	Title     string
+	StartTime time.Time
+	Duration  time.Duration
+	// Commands lists the commands run within the recorded shell, as reported by the shell's
+	// integration hooks (see ShellIntegrationScript)
+	Commands []eventers.ShellCommand
+	// Context describes where, and by whom, the recording was made
+	Context formatters.RecordingContext
+	// ContentHash is the SHA-256 hash of the completed recording (see HashRecording). This is blank
+	// if the recording could not be read back.
+	ContentHash string
+
+	// hashChain links the lines of the recording, as written, when requested
+	hashChain *write.HashChain
 }
 
 type recordingConfiguration struct {
@@ -70,19 +117,52 @@ func InitializeRecordings() {
 
 // StartRecording takes control of the terminal and starts a subshell to record input.
 func StartRecording(opSlug string) (RecordingOutput, error) {
+	return StartRecordingWithStatus(opSlug, os.Stdout)
+}
+
+// StartRecordingWithStatus is identical to StartRecording, but status messages (i.e. where the
+// recording is being written) are written to the provided writer, rather than stdout.
+func StartRecordingWithStatus(opSlug string, status io.Writer) (RecordingOutput, error) {
+	return StartCommandRecording(opSlug, nil, "", status)
+}
+
+// StartCommandRecording is identical to StartRecordingWithStatus, but records the provided command
+// (and arguments), rather than an interactive shell. If no command is provided, the shell is
+// recorded as usual. The command's exit code is returned in the output. The title, if provided,
+// is noted in the recording header; otherwise, a title can be set later (see SetRecordingTitle).
+func StartCommandRecording(opSlug string, command []string, title string, status io.Writer) (RecordingOutput, error) {
 	if recConfig.ptyReader == nil {
 		return RecordingOutput{}, ErrNotInitialized
 	}
 
+	// the signing key is read up front, so that a missing or unreadable key is reported before
+	// anything is recorded. The key is only needed to seal hash chained recordings.
+	var signingKey ed25519.PrivateKey
+	if config.HashChain() {
+		var err error
+		if signingKey, err = config.SigningKey(); err != nil {
+			return RecordingOutput{}, err
+		}
+	}
+
 	recOpts := RecordingInput{
-		FileDir:   filepath.Join(config.OutputDir(), opSlug),
-		FileName:  config.OutputFileName(),
-		Shell:     config.RecordingShell(),
-		TermInput: recConfig.ptyReader,
+		FileDir:          filepath.Join(config.OutputDir(), opSlug),
+		FileName:         config.OutputFileName(),
+		Shell:            config.RecordingShell(),
+		Command:          command,
+		WorkingDir:       config.RecordingWorkingDir(),
+		Title:            title,
+		Env:              config.RecordingEnv(),
+		PromptMarker:     config.PromptMarker(),
+		ShellIntegration: config.ShellIntegration(),
+		HeaderEnv:        config.HeaderEnv(),
+		HashChain:        config.HashChain(),
+		Context:          GatherContext(opSlug, config.RecordingWorkingDir(), config.OmitContext()),
+		TermInput:        recConfig.ptyReader,
 		OnRecordingStart: func(output RecordingOutput) {
 			// These Println occur while the terminal is in a raw state. CRs need to be manually added.
-			fmt.Println("Recording to " + fancy.WithBold(output.FilePath) + "\n\r")
-			fmt.Println(fancy.WithBold("Recording now live!\r", fancy.Reverse|fancy.LightGreen))
+			fmt.Fprintln(status, "Recording to "+fancy.WithBold(output.FilePath)+"\n\r")
+			fmt.Fprintln(status, fancy.WithBold("Recording now live!\r", fancy.Reverse|fancy.LightGreen))
 		},
 	}
 
@@ -112,7 +192,14 @@ func StartRecording(opSlug string) (RecordingOutput, error) {
 		recConfig.ptyWriter.Write([]byte("a")) // feed a dummy character to force the write target to switch over to the dialog writer
 	}()
 
-	return record(recOpts)
+	output, err := record(recOpts)
+	if err == nil && output.hashChain != nil {
+		if err := SealRecording(output.FilePath, output.hashChain, signingKey); err != nil {
+			// the recording itself is complete, so this is reported rather than returned
+			fmt.Fprintln(status, fancy.Caution("Unable to seal the recording", err)+"\r")
+		}
+	}
+	return output, err
 }
 
 // copyRouter is based off of io.Copy (and by extension, copyBuffer. This simplifies the implementation
@@ -163,16 +250,98 @@ func record(ri RecordingInput) (RecordingOutput, error) {
 		return result, errors.Wrap(err, "Unable to create file writer")
 	}
 	result.FilePath = tw.Filepath()
+	if ri.HashChain {
+		result.hashChain = tw.ChainLines()
+	}
+
+	c := exec.Command(ri.Shell)
+	if len(ri.Command) > 0 {
+		c = exec.Command(ri.Command[0], ri.Command[1:]...)
+	}
+	c.Dir = ri.WorkingDir
+	c.Env = childEnvironment(os.Environ(), ri.Env, result.FilePath, ri.PromptMarker)
+	if (ri.PromptMarker != "" || ri.ShellIntegration) && len(ri.Command) == 0 {
+		cleanup, err := customizeShell(c, ri.Shell, ri.PromptMarker != "", ri.ShellIntegration)
+		defer cleanup()
+		if err != nil {
+			tw.Close()
+			return result, errors.Wrap(err, "Unable to customize the recording shell")
+		}
+	}
 
-	recorder := recorders.NewStreamingRecorder(tw, clockwork.NewRealClock(), ri.Shell)
-	eventWriter := eventers.NewEventWriter(&recorder, common.Output, ri.EventMiddleware...)
+	result.StartTime = time.Now()
+	result.Command = FormatCommand(ri.Command)
+	result.Context = ri.Context
+	result.Title = ri.Title
+	commandTracker := eventers.NewCommandTracker(result.StartTime)
+	recorder := recorders.NewStreamingRecorderWithMetadata(tw, clockwork.NewRealClock(), formatters.Metadata{
+		Title:   ri.Title,
+		Shell:   ri.Shell,
+		Term:    os.Getenv("TERM"),
+		Command: result.Command,
+		Env:     headerEnv(c.Env, ri.HeaderEnv),
+		Context: ri.Context,
+	})
+	middleware := append(ri.EventMiddleware, commandTracker.Middleware())
+	eventWriter := eventers.NewEventWriter(&recorder, common.Output, middleware...)
 	wrappedStdOut := io.MultiWriter(os.Stdout, eventWriter)
 
 	tracker := NewPtyTracker(wrappedStdOut, ioutil.Discard, ri.TermInput, func() { ri.OnRecordingStart(result) })
 
-	err = tracker.Run(ri.Shell)
-	if err != nil {
-		return result, errors.Wrap(err, `Unable to start the recording. Shell path: "`+ri.Shell+`"`)
+	err = tracker.RunCommand(c)
+	result.Duration = time.Since(result.StartTime)
+	result.Commands = commandTracker.Commands()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		if len(ri.Command) == 0 {
+			return result, errors.Wrap(err, `Unable to start the recording. Shell path: "`+ri.Shell+`"`)
+		}
+		return result, errors.Wrap(err, `Unable to start the recording. Command: "`+result.Command+`"`)
+	}
+	result.ExitCode, result.ExitSignal = exitStatus(c.ProcessState)
+
+	// the header is rewritten with the final details (e.g. duration) once the writer is closed
+	recorder.UpdateMetadata(func(m *formatters.Metadata) {
+		m.ExitCode = &result.ExitCode
+		m.ExitSignal = result.ExitSignal
+	})
+	recorder.Output(tw)
+	if err = tw.Close(); err != nil {
+		return result, errors.Wrap(err, "Unable to finalize recording")
+	}
+	result.ContentHash, _ = HashRecording(result.FilePath) // re-hashed on upload, so this is not worth failing over
+	return result, nil
+}
+
+// exitStatus interprets the state of an exited process as an exit code, and the name of the
+// signal that killed it (if any)
+func exitStatus(state *os.ProcessState) (int, string) {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), status.Signal().String()
+	}
+	return state.ExitCode(), ""
+}
+
+// FormatCommand joins the command and its arguments into a single string, quoting any arguments
+// that a shell would otherwise split or interpret
+func FormatCommand(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	for _, r := range arg {
+		safe := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune("_@%+=:,./-", r)
+		if !safe {
+			return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
 	}
-	return result, errors.MaybeWrap(tw.Close(), "Issue closing file writer")
+	return arg
 }
@@ -0,0 +1,68 @@
+package recording
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/theparanoids/aterm/errors"
+)
+
+// TagRules suggests tags for recordings, based on their content. Each tag name is paired with a
+// pattern, which suggests that tag when found in any command or line of output. See
+// CompileTagRules.
+type TagRules map[string]*regexp.Regexp
+
+// CompileTagRules parses the provided rules, which map tag names to regular expressions. Patterns
+// ignore case.
+func CompileTagRules(rules map[string]string) (TagRules, error) {
+	compiled := make(TagRules, len(rules))
+	for tag, pattern := range rules {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Invalid tag rule for %q", tag))
+		}
+		compiled[tag] = re
+	}
+	return compiled, nil
+}
+
+// Suggest returns the names of the tags whose patterns match any of the provided lines, sorted by
+// name
+func (rules TagRules) Suggest(lines []string) []string {
+	suggested := []string{}
+	for tag, re := range rules {
+		for _, line := range lines {
+			if re.MatchString(line) {
+				suggested = append(suggested, tag)
+				break
+			}
+		}
+	}
+	sort.Strings(suggested)
+	return suggested
+}
+
+// SuggestTags applies the rules to the commands (see RecordingMetadata.Commands) and output of the
+// recording described by the metadata
+func (rules TagRules) SuggestTags(metadata RecordingMetadata) ([]string, error) {
+	if len(rules) == 0 {
+		return []string{}, nil
+	}
+	_, output, err := readOutputText(metadata.FilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read recording")
+	}
+
+	lines := make([]string, 0, len(metadata.Commands)+len(output))
+	if metadata.Command != "" {
+		lines = append(lines, metadata.Command)
+	}
+	for _, c := range metadata.Commands {
+		lines = append(lines, c.Command)
+	}
+	for _, line := range output {
+		lines = append(lines, line.Text)
+	}
+	return rules.Suggest(lines), nil
+}
@@ -0,0 +1,358 @@
+package recording
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// terminalScreen is a minimal terminal emulator, used to render recorded output as the text that
+// was displayed. Unlike a real terminal, lines scrolled off the screen (or cleared) are kept, so
+// that all output can be rendered. Cursor movement, erasing, and inserting/deleting characters are
+// supported; formatting is ignored. Output written to the alternate screen (e.g. by editors and
+// pagers) is discarded once the alternate screen is closed.
+type terminalScreen struct {
+	width, height int
+	lines         [][]rune
+	// top is the index of the first line on the screen
+	top      int
+	row, col int
+	// wrapPending is set once a character is written to the last column; the next character then
+	// starts a new line
+	wrapPending bool
+	savedRow    int
+	savedCol    int
+	main        *terminalScreen // the main screen, while the alternate screen is in use
+
+	state  textState
+	params []byte
+	// partial holds an incomplete UTF-8 sequence, split across writes
+	partial []byte
+}
+
+// newTerminalScreen creates a blank screen with the given size. Sizes of zero are treated as the
+// typical 80x24.
+func newTerminalScreen(width, height int) *terminalScreen {
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+	return &terminalScreen{width: width, height: height, lines: [][]rune{nil}}
+}
+
+// write processes some output. Sequences (and characters) may be split across writes.
+func (s *terminalScreen) write(data string) {
+	buf := append(s.partial, data...)
+	s.partial = nil
+	for len(buf) > 0 {
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(buf) {
+			s.partial = append([]byte{}, buf...)
+			return
+		}
+		buf = buf[size:]
+		s.writeRune(r)
+	}
+}
+
+func (s *terminalScreen) writeRune(r rune) {
+	switch s.state {
+	case textNormal:
+		s.writeNormal(r)
+
+	case textEscape:
+		s.state = textNormal
+		switch r {
+		case '[':
+			s.state = textCSI
+			s.params = s.params[:0]
+		case ']', 'P', 'X', '^', '_':
+			s.state = textString
+		case '(', ')', '*', '+', '#', '%':
+			s.state = textEscapeIntermediate
+		case '7':
+			s.savedRow, s.savedCol = s.row-s.top, s.col
+		case '8':
+			s.moveTo(s.top+s.savedRow, s.savedCol)
+		case 'D':
+			s.lineFeed()
+		case 'E':
+			s.col = 0
+			s.lineFeed()
+		case 'M':
+			if s.row > s.top {
+				s.row--
+			}
+			s.wrapPending = false
+		case 'c':
+			s.clearScreen()
+		}
+
+	case textEscapeIntermediate:
+		s.state = textNormal
+
+	case textCSI:
+		if r >= 0x40 && r <= 0x7e {
+			s.state = textNormal
+			s.control(r)
+		} else {
+			s.params = append(s.params, byte(r))
+		}
+
+	case textString:
+		if r == '\a' {
+			s.state = textNormal
+		} else if r == '\x1b' {
+			s.state = textStringEscape
+		}
+
+	case textStringEscape:
+		if r == '\\' {
+			s.state = textNormal
+		} else {
+			s.state = textString
+		}
+	}
+}
+
+func (s *terminalScreen) writeNormal(r rune) {
+	switch {
+	case r == '\x1b':
+		s.state = textEscape
+	case r == '\r':
+		s.col = 0
+		s.wrapPending = false
+	case r == '\n' || r == '\v' || r == '\f':
+		s.lineFeed()
+	case r == '\b':
+		if s.col > 0 {
+			s.col--
+		}
+		s.wrapPending = false
+	case r == '\t':
+		s.col = min(s.col/8*8+8, s.width-1)
+	case r >= 0x20 && r != 0x7f && (r < 0x80 || r >= 0xa0):
+		if s.wrapPending {
+			s.col = 0
+			s.lineFeed()
+		}
+		s.put(s.row, s.col, r)
+		if s.col == s.width-1 {
+			s.wrapPending = true
+		} else {
+			s.col++
+		}
+	}
+}
+
+// control handles a CSI sequence, ending with the given final character
+func (s *terminalScreen) control(final rune) {
+	private := strings.HasPrefix(string(s.params), "?")
+	params := s.numericParams()
+	n := func(i int) int { // most parameters default to 1
+		if i < len(params) && params[i] > 0 {
+			return params[i]
+		}
+		return 1
+	}
+	p := func(i int) int { // while erase parameters default to 0
+		if i < len(params) {
+			return params[i]
+		}
+		return 0
+	}
+
+	switch final {
+	case 'A':
+		s.moveTo(max(s.row-n(0), s.top), s.col)
+	case 'B', 'e':
+		s.moveTo(s.row+n(0), s.col)
+	case 'C', 'a':
+		s.moveTo(s.row, s.col+n(0))
+	case 'D':
+		s.moveTo(s.row, s.col-n(0))
+	case 'E':
+		s.moveTo(s.row+n(0), 0)
+	case 'F':
+		s.moveTo(max(s.row-n(0), s.top), 0)
+	case 'G', '`':
+		s.moveTo(s.row, n(0)-1)
+	case 'H', 'f':
+		s.moveTo(s.top+n(0)-1, n(1)-1)
+	case 'd':
+		s.moveTo(s.top+n(0)-1, s.col)
+	case 'J':
+		switch p(0) {
+		case 0:
+			s.lines[s.row] = truncateRunes(s.lines[s.row], s.col)
+			s.lines = s.lines[:s.row+1]
+		case 1:
+			for i := s.top; i < s.row; i++ {
+				s.lines[i] = nil
+			}
+			s.erase(s.row, 0, s.col+1)
+		default:
+			s.clearScreen()
+		}
+	case 'K':
+		switch p(0) {
+		case 0:
+			s.lines[s.row] = truncateRunes(s.lines[s.row], s.col)
+		case 1:
+			s.erase(s.row, 0, s.col+1)
+		default:
+			s.lines[s.row] = nil
+		}
+	case 'X':
+		s.erase(s.row, s.col, s.col+n(0))
+	case 'P':
+		line := s.lines[s.row]
+		if s.col < len(line) {
+			s.lines[s.row] = append(line[:s.col:s.col], line[min(s.col+n(0), len(line)):]...)
+		}
+	case '@':
+		line := s.lines[s.row]
+		if s.col < len(line) {
+			inserted := append([]rune(strings.Repeat(" ", n(0))), line[s.col:]...)
+			s.lines[s.row] = truncateRunes(append(line[:s.col:s.col], inserted...), s.width)
+		}
+	case 'L', 'M':
+		bottom := min(s.top+s.height, len(s.lines))
+		screen := append([][]rune{}, s.lines[s.row:bottom]...)
+		if final == 'L' {
+			screen = append(make([][]rune, min(n(0), len(screen))), screen...)[:len(screen)]
+		} else {
+			screen = append(screen[min(n(0), len(screen)):], make([][]rune, min(n(0), len(screen)))...)
+		}
+		copy(s.lines[s.row:bottom], screen)
+	case 's':
+		s.savedRow, s.savedCol = s.row-s.top, s.col
+	case 'u':
+		s.moveTo(s.top+s.savedRow, s.savedCol)
+	case 'h', 'l':
+		if private {
+			for _, mode := range params {
+				if mode == 47 || mode == 1047 || mode == 1049 {
+					s.useAlternateScreen(final == 'h')
+				}
+			}
+		}
+	}
+}
+
+// numericParams parses the parameters of the current CSI sequence. Missing parameters are zero.
+func (s *terminalScreen) numericParams() []int {
+	raw := strings.TrimLeft(string(s.params), "?<=>")
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ";")
+	params := make([]int, len(parts))
+	for i, part := range parts {
+		params[i], _ = strconv.Atoi(part)
+	}
+	return params
+}
+
+// moveTo moves the cursor, keeping it on the screen. Moving below the last line of output adds
+// blank lines.
+func (s *terminalScreen) moveTo(row, col int) {
+	s.row = min(max(row, s.top), s.top+s.height-1)
+	s.col = min(max(col, 0), s.width-1)
+	s.wrapPending = false
+	s.ensureRow(s.row)
+}
+
+// lineFeed moves the cursor down a line, scrolling the screen if the cursor is on the last line
+func (s *terminalScreen) lineFeed() {
+	s.row++
+	s.wrapPending = false
+	s.ensureRow(s.row)
+	if s.row >= s.top+s.height {
+		s.top = s.row - s.height + 1
+	}
+}
+
+func (s *terminalScreen) ensureRow(row int) {
+	for len(s.lines) <= row {
+		s.lines = append(s.lines, nil)
+	}
+}
+
+// clearScreen starts a new screen below the existing output, so that cleared output is kept. The
+// cursor keeps its position on the screen.
+func (s *terminalScreen) clearScreen() {
+	last := len(s.lines)
+	for last > 0 && strings.TrimSpace(string(s.lines[last-1])) == "" {
+		last--
+	}
+	s.lines = s.lines[:last]
+	screenRow := s.row - s.top
+	s.top = last
+	s.row = s.top + screenRow
+	s.ensureRow(s.row)
+}
+
+// useAlternateScreen switches to (or from) a blank alternate screen. Leaving the alternate screen
+// restores the main screen, as it was.
+func (s *terminalScreen) useAlternateScreen(enable bool) {
+	if enable && s.main == nil {
+		main := *s
+		*s = terminalScreen{width: s.width, height: s.height, lines: [][]rune{nil}, state: s.state, params: s.params, main: &main}
+	} else if !enable && s.main != nil {
+		main := s.main
+		main.state, main.params, main.partial = s.state, s.params, s.partial
+		*s = *main
+	}
+}
+
+// mainRow returns the line the cursor is on, on the main screen
+func (s *terminalScreen) mainRow() int {
+	if s.main != nil {
+		return s.main.row
+	}
+	return s.row
+}
+
+// put writes a character to the given position, padding the line with spaces as needed
+func (s *terminalScreen) put(row, col int, r rune) {
+	line := s.lines[row]
+	for len(line) <= col {
+		line = append(line, ' ')
+	}
+	line[col] = r
+	s.lines[row] = line
+}
+
+// erase replaces the characters between the given columns with spaces
+func (s *terminalScreen) erase(row, from, to int) {
+	line := s.lines[row]
+	for i := from; i < to && i < len(line); i++ {
+		line[i] = ' '
+	}
+}
+
+// text returns the lines from the given line onwards, without trailing whitespace. Blank lines at
+// the start and end are dropped.
+func (s *terminalScreen) text(fromLine int) string {
+	lines := []string{}
+	for i := fromLine; i < len(s.lines); i++ {
+		lines = append(lines, strings.TrimRight(string(s.lines[i]), " \t"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func truncateRunes(line []rune, length int) []rune {
+	if len(line) > length {
+		return line[:length]
+	}
+	return line
+}
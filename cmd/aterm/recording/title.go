@@ -0,0 +1,85 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/formatters"
+	"github.com/theparanoids/aterm/write"
+)
+
+// ErrSealedRecording is returned when changing a sealed recording (see SealRecording). Sealed
+// recordings are never rewritten, as any change would be reported as tampering.
+var ErrSealedRecording = errors.New("The recording is sealed, so cannot be changed")
+
+// maxTitleLength limits titles taken from descriptions, which may be much longer than players
+// are able to show
+const maxTitleLength = 100
+
+// SetRecordingTitle rewrites the title in the recording's header. Sealed recordings are left
+// unchanged (see ErrSealedRecording).
+func SetRecordingTitle(path, title string) error {
+	if _, err := os.Stat(IntegrityPath(path)); err == nil {
+		return ErrSealedRecording
+	}
+	err := write.UpdateASCIICastHeader(path, func(header *formatters.ASCIICastHeader) {
+		header.Title = title
+	})
+	return errors.MaybeWrap(err, "Unable to set the recording's title")
+}
+
+// TitleFromDescription derives a title from the first line of the description, shortened if
+// needed. Returns an empty string if the description is blank.
+func TitleFromDescription(description string) string {
+	title := strings.TrimSpace(strings.SplitN(strings.TrimSpace(description), "\n", 2)[0])
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+	}
+	return title
+}
+
+// ApplyDescriptionTitle titles a recording made by aterm (i.e. one with saved metadata noting when
+// it was recorded) after its description, if the recording has no title yet, so that players show
+// the description rather than when the recording started. This is done just prior to uploading,
+// and is best-effort: on failure, the metadata is returned unchanged, along with the error. Sealed
+// recordings are left as-is.
+func ApplyDescriptionTitle(metadata RecordingMetadata) (RecordingMetadata, error) {
+	title := TitleFromDescription(metadata.Description)
+	if metadata.Title != "" || metadata.StartedAt.IsZero() || title == "" {
+		return metadata, nil
+	}
+	titled, err := hasTitle(metadata.FilePath)
+	if err != nil || titled {
+		return metadata, err
+	}
+	if err := SetRecordingTitle(metadata.FilePath, title); err == ErrSealedRecording {
+		return metadata, nil
+	} else if err != nil {
+		return metadata, err
+	}
+	metadata.Title = title
+	return metadata, nil
+}
+
+// hasTitle checks whether the recording's header has a title, other than the start time that
+// aterm uses when no title was provided
+func hasTitle(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	headerLine, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && len(headerLine) == 0 {
+		return false, errors.Wrap(err, "Unable to read recording header")
+	}
+	var header formatters.ASCIICastHeader
+	if err := json.Unmarshal(headerLine, &header); err != nil {
+		return false, errors.Wrap(err, "Unable to parse recording header")
+	}
+	return header.Title != "" && header.Title != strconv.FormatInt(header.Timestamp, 10), nil
+}
@@ -0,0 +1,72 @@
+package recording
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRecordingTitle(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	require.NoError(t, ioutil.WriteFile(castPath, []byte(sealedCast), 0600))
+
+	require.NoError(t, SetRecordingTitle(castPath, "ran whoami"))
+	content, err := ioutil.ReadFile(castPath)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"title":"ran whoami"`)
+
+	// sealed recordings are never rewritten
+	sealCast(t, castPath, string(content), nil)
+	require.Equal(t, ErrSealedRecording, SetRecordingTitle(castPath, "changed"))
+	unchanged, err := ioutil.ReadFile(castPath)
+	require.NoError(t, err)
+	require.Equal(t, content, unchanged)
+	result, err := VerifyRecording(castPath, nil)
+	require.NoError(t, err)
+	require.True(t, result.Intact)
+}
+
+func TestApplyDescriptionTitle(t *testing.T) {
+	castPath := filepath.Join(t.TempDir(), "rec.cast")
+	writeHeader := func(header string) {
+		require.NoError(t, ioutil.WriteFile(castPath, []byte(header+"\n[0.5,\"o\",\"$ \"]\n"), 0600))
+	}
+	metadata := RecordingMetadata{FilePath: castPath, Description: "ran whoami\nas root"}
+
+	// recordings without metadata from aterm are not aterm's to change
+	writeHeader(`{"version":2,"timestamp":100,"title":"100"}`)
+	titled, err := ApplyDescriptionTitle(metadata)
+	require.NoError(t, err)
+	require.Equal(t, "", titled.Title)
+
+	// nor are recordings that already have a title
+	metadata.StartedAt = time.Unix(100, 0)
+	writeHeader(`{"version":2,"timestamp":100,"title":"whoami"}`)
+	titled, err = ApplyDescriptionTitle(metadata)
+	require.NoError(t, err)
+	require.Equal(t, "", titled.Title)
+	content, err := ioutil.ReadFile(castPath)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"title":"whoami"`)
+
+	writeHeader(`{"version":2,"timestamp":100,"title":"100"}`)
+	titled, err = ApplyDescriptionTitle(metadata)
+	require.NoError(t, err)
+	require.Equal(t, "ran whoami", titled.Title)
+	content, err = ioutil.ReadFile(castPath)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"title":"ran whoami"`)
+}
+
+func TestTitleFromDescription(t *testing.T) {
+	require.Equal(t, "", TitleFromDescription("  \n"))
+	require.Equal(t, "ran nmap", TitleFromDescription("\n ran nmap \nagainst the DMZ"))
+
+	title := TitleFromDescription(strings.Repeat("a", 150))
+	require.Equal(t, maxTitleLength, len([]rune(title)))
+	require.True(t, strings.HasSuffix(title, "…"))
+}
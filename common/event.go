@@ -5,7 +5,7 @@ import (
 	"time"
 )
 
-// EventType is an enum for the kinds of events that take can take place (really just input/output)
+// EventType is an enum for the kinds of events that take can take place (input, output, markers)
 type EventType string
 
 const (
@@ -13,6 +13,8 @@ const (
 	Input EventType = "i"
 	// Output signals the EventType for output-related events
 	Output EventType = "o"
+	// Marker signals the EventType for markers (i.e. points of interest) within a recording
+	Marker EventType = "m"
 )
 
 // Event is the structure of a generic terminal event. An event is comprised of 3 core components
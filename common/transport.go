@@ -0,0 +1,22 @@
+package common
+
+// TransportOptions configures how the client reaches the ASHIRT servers
+type TransportOptions struct {
+	// ProxyURL is the proxy to route all requests through. Supports http://, https:// and
+	// socks5:// schemes. If blank, the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment
+	// variables are used.
+	ProxyURL string
+
+	// CACertPath points to a PEM-encoded bundle of CA certificates to trust, in addition to the
+	// system's trusted CAs
+	CACertPath string
+
+	// ClientCertPath and ClientKeyPath point to a PEM-encoded certificate and key, presented to
+	// servers that require client (mutual TLS) authentication. Both or neither must be provided.
+	ClientCertPath string
+	ClientKeyPath  string
+
+	// InsecureSkipVerify disables all verification of the server's certificate. This should only
+	// be used for testing, as it makes connections vulnerable to interception.
+	InsecureSkipVerify bool
+}
@@ -0,0 +1,170 @@
+package dialog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/theparanoids/aterm/fancy"
+)
+
+// ProgressBar tracks and renders the progress of some long running task (e.g. an upload) as a
+// single, redrawn line of text, including the transfer rate and estimated time remaining.
+// Progress can be updated from any goroutine.
+type ProgressBar struct {
+	label   string
+	width   int
+	clock   clockwork.Clock
+	mutex   sync.Mutex
+	started time.Time
+	current int64
+	total   int64
+}
+
+// NewProgressBar constructs a ProgressBar with the given label
+func NewProgressBar(label string) *ProgressBar {
+	return &ProgressBar{label: label, width: 30, clock: clockwork.NewRealClock()}
+}
+
+// Update records the current progress. This has the signature expected by
+// network.UploadInput.OnProgress, so it can be passed along directly.
+// The rate and ETA are measured from the first call to Update.
+func (p *ProgressBar) Update(current, total int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.started.IsZero() {
+		p.started = p.clock.Now()
+	}
+	p.current, p.total = current, total
+}
+
+// Rate returns the average number of bytes processed per second, since the first update
+func (p *ProgressBar) Rate() float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.rate()
+}
+
+func (p *ProgressBar) rate() float64 {
+	if p.started.IsZero() {
+		return 0
+	}
+	elapsed := p.clock.Since(p.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.current) / elapsed
+}
+
+// ETA estimates the remaining time, based on the current rate. Returns false if no estimate
+// can be made yet.
+func (p *ProgressBar) ETA() (time.Duration, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	rate := p.rate()
+	if rate <= 0 || p.total <= 0 {
+		return 0, false
+	}
+	remaining := float64(p.total-p.current) / rate
+	return time.Duration(remaining * float64(time.Second)).Round(time.Second), true
+}
+
+// String renders the progress bar in its current state
+func (p *ProgressBar) String() string {
+	p.mutex.Lock()
+	current, total := p.current, p.total
+	p.mutex.Unlock()
+
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(current) / float64(total)
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * float64(p.width))
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", p.width-filled)
+
+	eta := "--"
+	if remaining, ok := p.ETA(); ok {
+		eta = remaining.String()
+	}
+
+	return fmt.Sprintf("%v [%v] %3.0f%% (%v / %v) %v/s ETA %v",
+		p.label, bar, ratio*100, FormatBytes(current), FormatBytes(total), FormatBytes(int64(p.Rate())), eta)
+}
+
+// ShowProgressBar renders the given progress bar until the stop channel is closed.
+// Like ShowLoadingAnimation, this should be called as a goroutine.
+func ShowProgressBar(bar *ProgressBar, stop <-chan struct{}) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		fmt.Print(fancy.ClearLine(bar.String(), 0))
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DoBackgroundProgress is the progress bar equivalent of DoBackgroundLoading: the provided
+// function is executed, while the given progress bar is rendered. The provided function is
+// expected to update the progress bar as it makes progress.
+func DoBackgroundProgress(bar *ProgressBar, fn func()) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go SyncedFunc(fn)(&wg)
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		ShowProgressBar(bar, stop)
+		close(stopped)
+	}()
+	wg.Wait()
+	close(stop)
+	<-stopped // so that the bar is not redrawn once cleared
+	fmt.Print(fancy.ClearLine(""))
+}
+
+// DoCancellableProgress is identical to DoBackgroundProgress, except that the user may cancel the
+// task by pressing ^C. The provided function receives a context that is cancelled when this
+// occurs, and is expected to stop work shortly thereafter. The function's error is returned; the
+// task was only cancelled if this matches context.Canceled (see IsCancelled), as ^C may be pressed
+// after the task has already completed.
+//
+// Note: ^C is only captured while the provided function is running.
+func DoCancellableProgress(bar *ProgressBar, fn func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Println(fancy.AsDim("Press ^C to cancel") + "\r")
+	var err error
+	DoBackgroundProgress(bar, func() { err = fn(ctx) })
+	return err
+}
+
+// IsCancelled returns true if the error notes that a task was cancelled (see DoCancellableProgress)
+func IsCancelled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// FormatBytes renders a byte count in a human-friendly unit (e.g. 1.5 MiB)
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -10,3 +10,38 @@ func SearcherContainsCI(optionLabels []string) func(input string, index int) boo
 		return strings.Contains(strings.ToLower(optionLabels[index]), strings.ToLower(input))
 	}
 }
+
+// FuzzyScore checks if, **ignoring case**, each character of the input appears in the label, in
+// order (though not necessarily together). Matches are scored by how spread out the matching
+// characters are, and how late in the label the match begins, where lower scores are better
+// matches. An empty input matches everything, with a score of 0.
+func FuzzyScore(input, label string) (int, bool) {
+	needle := []rune(strings.ToLower(input))
+	if len(needle) == 0 {
+		return 0, true
+	}
+	haystack := []rune(strings.ToLower(label))
+
+	// try each starting point, keeping the tightest match
+	best, found := 0, false
+	for start := range haystack {
+		if haystack[start] != needle[0] {
+			continue
+		}
+		matched, end := 1, start
+		for i := start + 1; i < len(haystack) && matched < len(needle); i++ {
+			if haystack[i] == needle[matched] {
+				matched++
+				end = i
+			}
+		}
+		if matched < len(needle) {
+			break // later starting points can only match less
+		}
+		score := (end-start+1-len(needle))*2 + start
+		if !found || score < best {
+			best, found = score, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,45 @@
+package dialog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		input   string
+		label   string
+		score   int
+		matched bool
+	}{
+		// tighter matches, and those starting earlier, score lower
+		{input: "ab", label: "ab", score: 0, matched: true},
+		{input: "ab", label: "xab", score: 1, matched: true},
+		{input: "ab", label: "a_b", score: 2, matched: true},
+		{input: "ab", label: "xa_b", score: 3, matched: true},
+		{input: "ab", label: "a__b", score: 4, matched: true},
+
+		// case is ignored
+		{input: "AB", label: "aB", score: 0, matched: true},
+		{input: "ab", label: "XAB", score: 1, matched: true},
+
+		// an empty input matches anything
+		{input: "", label: "anything", score: 0, matched: true},
+		{input: "", label: "", score: 0, matched: true},
+
+		// a later occurrence of the first rune can give a tighter match
+		{input: "ab", label: "a_xab", score: 3, matched: true},
+		{input: "cat", label: "concatenate", score: 3, matched: true},
+
+		// every rune must appear, in order
+		{input: "ba", label: "ab", matched: false},
+		{input: "abc", label: "ab", matched: false},
+		{input: "a", label: "", matched: false},
+	}
+	for _, tc := range tests {
+		score, matched := FuzzyScore(tc.input, tc.label)
+		require.Equal(t, tc.matched, matched, "input: %q, label: %q", tc.input, tc.label)
+		require.Equal(t, tc.score, score, "input: %q, label: %q", tc.input, tc.label)
+	}
+}
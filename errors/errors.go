@@ -38,6 +38,12 @@ func Is(err, target error) bool {
 	return errors.Is(err, target)
 }
 
+// As wraps golang's errors.As function to provide easier use when using this package and golang's
+// underlying errors package
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
 // MultiErrorPrintFormat provides the common error printing function for hashicorp/go-multierror
 // Format is err1 : err2 : ... : errN (roughly equivalement to strings.Join(errs, " : ") )
 func MultiErrorPrintFormat(errs []error) string {
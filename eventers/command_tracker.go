@@ -0,0 +1,172 @@
+package eventers
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/aterm/common"
+)
+
+// ShellCommand describes a single command run within a recorded shell, as reported by the shell's
+// integration hooks. Times are in seconds, relative to the start of the recording.
+type ShellCommand struct {
+	Command      string  `json:"command"`
+	StartSeconds float64 `json:"start"`
+	EndSeconds   float64 `json:"end,omitempty"`
+	ExitCode     *int    `json:"exitCode,omitempty"`
+}
+
+// oscCommandPrefix starts each OSC 133 ("semantic prompt") escape sequence. These are emitted by
+// the shell integration hooks (and by some shells natively) in the form:
+//
+//	ESC ] 133 ; A ST                  prompt start
+//	ESC ] 133 ; B ST                  prompt end (command input starts)
+//	ESC ] 133 ; C [; cmdline=...] ST  command start (output starts)
+//	ESC ] 133 ; D [; exit code] ST    command end
+//
+// where ST is either BEL, or ESC \
+const oscCommandPrefix = "\x1b]133;"
+
+// maxPendingSequence limits how much of an unterminated sequence is kept between writes. Anything
+// larger is assumed not to be a real sequence.
+const maxPendingSequence = 4096
+
+// CommandTracker watches terminal output for OSC 133 sequences, and collects the commands run in
+// the recorded shell. Each command's start and end are also added to the recording as markers. Use
+// Middleware to attach the tracker to an EventWriter.
+//
+// Note: this is not safe for concurrent use; Commands should only be called once writing ends.
+type CommandTracker struct {
+	startTime time.Time
+	pending   []byte
+	commands  []ShellCommand
+	running   bool
+}
+
+// NewCommandTracker creates a CommandTracker for a recording that started at the provided time
+func NewCommandTracker(startTime time.Time) *CommandTracker {
+	return &CommandTracker{startTime: startTime, commands: []ShellCommand{}}
+}
+
+// Commands returns the commands seen so far, in the order they were run
+func (t *CommandTracker) Commands() []ShellCommand {
+	return append([]ShellCommand{}, t.commands...)
+}
+
+// Middleware produces an EventMiddleware that inspects (but does not alter) each event
+func (t *CommandTracker) Middleware() EventMiddleware {
+	return func(evt RawEvent) RawEvent {
+		t.scan(evt)
+		return evt
+	}
+}
+
+// scan finds each complete sequence in the event (including any partial sequence left over from the
+// last event), keeping any trailing partial sequence for next time
+func (t *CommandTracker) scan(evt RawEvent) {
+	data := append(t.pending, evt.Data...)
+	t.pending = nil
+	prefix := []byte(oscCommandPrefix)
+
+	for {
+		start := bytes.Index(data, prefix)
+		if start < 0 {
+			// the data may end partway through the prefix
+			for n := len(prefix) - 1; n > 0; n-- {
+				if bytes.HasSuffix(data, prefix[:n]) {
+					t.pending = append([]byte{}, prefix[:n]...)
+					break
+				}
+			}
+			return
+		}
+
+		params := data[start+len(prefix):]
+		end, terminatorLength := findStringTerminator(params)
+		if end < 0 {
+			if len(data)-start <= maxPendingSequence {
+				t.pending = append([]byte{}, data[start:]...)
+			}
+			return
+		}
+		t.handleSequence(string(params[:end]), evt)
+		data = params[end+terminatorLength:]
+	}
+}
+
+// findStringTerminator returns the index, and length, of the first BEL or ESC \ in the data, or
+// -1 if no terminator is present
+func findStringTerminator(data []byte) (int, int) {
+	for i, b := range data {
+		if b == '\a' {
+			return i, 1
+		}
+		if b == '\x1b' && i+1 < len(data) && data[i+1] == '\\' {
+			return i, 2
+		}
+	}
+	return -1, 0
+}
+
+func (t *CommandTracker) handleSequence(params string, evt RawEvent) {
+	kind, options := params, ""
+	if i := strings.IndexByte(params, ';'); i >= 0 {
+		kind, options = params[:i], params[i+1:]
+	}
+	when := evt.EventTime.Sub(t.startTime).Seconds()
+
+	switch kind {
+	case "C":
+		t.finishCommand(when, nil, evt)
+		command := ShellCommand{StartSeconds: when}
+		// the command line is always the last option, and so may contain semicolons
+		if strings.HasPrefix(options, "cmdline=") {
+			command.Command = strings.TrimPrefix(options, "cmdline=")
+		} else if strings.HasPrefix(options, "cmdline_url=") {
+			command.Command, _ = url.PathUnescape(strings.TrimPrefix(options, "cmdline_url="))
+		}
+		t.commands = append(t.commands, command)
+		t.running = true
+
+		label := command.Command
+		if label == "" {
+			label = "command"
+		}
+		addMarker(evt, label)
+
+	case "D":
+		var exitCode *int
+		if code, err := strconv.Atoi(strings.SplitN(options, ";", 2)[0]); err == nil {
+			exitCode = &code
+		}
+		t.finishCommand(when, exitCode, evt)
+	}
+}
+
+// finishCommand notes the end of the running command, if any
+func (t *CommandTracker) finishCommand(when float64, exitCode *int, evt RawEvent) {
+	if !t.running {
+		return
+	}
+	t.running = false
+	last := &t.commands[len(t.commands)-1]
+	last.EndSeconds = when
+	last.ExitCode = exitCode
+	if exitCode != nil {
+		addMarker(evt, fmt.Sprintf("exit %v", *exitCode))
+	} else {
+		addMarker(evt, "end")
+	}
+}
+
+// addMarker records a marker event, at the same time as the provided event
+func addMarker(evt RawEvent, label string) {
+	if evt.rec == nil {
+		return
+	}
+	RawEvent{Data: []byte(label), EventTime: evt.EventTime, EventType: common.Marker, rec: evt.rec}.Dispatch()
+}
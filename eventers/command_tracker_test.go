@@ -0,0 +1,51 @@
+package eventers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/theparanoids/aterm/common"
+	"github.com/theparanoids/aterm/recorders"
+)
+
+func TestCommandTracker(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	rec := recorders.NewBufferedRecorder(clock, "whatever")
+	tracker := NewCommandTracker(clock.Now())
+	ew := EventWriter{rec: &rec, clock: clock, eventType: common.Output, middleware: []EventMiddleware{tracker.Middleware()}}
+
+	write := func(s string) {
+		clock.Advance(time.Second)
+		ew.Write([]byte(s))
+	}
+
+	write("\x1b]133;A\a$ \x1b]133;B\a")
+	write("\x1b]133;C;cmdline=echo a; echo b\aa\r\nb\r\n")
+	write("\x1b]13") // sequence split across writes
+	write("3;D;0\x1b\\\x1b]133;A\a$ ")
+	write("\x1b]133;C;cmdline_url=false%20%3B\a")
+	write("\x1b]133;D;1\a")
+	write("\x1b]133;C\aunnamed")
+	write("plain output")
+
+	zero, one := 0, 1
+	assert.Equal(t, []ShellCommand{
+		{Command: "echo a; echo b", StartSeconds: 2, EndSeconds: 4, ExitCode: &zero},
+		{Command: "false ;", StartSeconds: 5, EndSeconds: 6, ExitCode: &one},
+		{Command: "", StartSeconds: 7},
+	}, tracker.Commands())
+
+	markers := []string{}
+	outputs := 0
+	for _, evt := range rec.GetEventsForTesting() {
+		if evt.Type == common.Marker {
+			markers = append(markers, evt.Data)
+		} else {
+			outputs++
+		}
+	}
+	assert.Equal(t, []string{"echo a; echo b", "exit 0", "false ;", "exit 1", "command"}, markers)
+	assert.Equal(t, 8, outputs, "output is unaltered")
+}
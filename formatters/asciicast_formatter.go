@@ -21,26 +21,47 @@ var ASCIICast = asciiCast{
 }
 
 // WriteHeader constructs an Asciicinema/Asciicast header. A basic header is constructed, and if
-// information is present in the recorder, more details can be added.
+// information is present in the recorder, more details can be added. Recordings without a title
+// are titled with their start time (or the current time, if that is not known).
 func (f asciiCast) WriteHeader(m Metadata) ([]byte, error) {
 	title := m.Title
-	if title == "" {
+	if title == "" && m.StartTimeUnix != 0 {
+		title = strconv.FormatInt(m.StartTimeUnix, 10)
+	} else if title == "" {
 		title = strconv.FormatInt(f.clock.Now().Unix(), 10)
 	}
 
+	width, height := m.Width, m.Height
+	if width == 0 && height == 0 {
+		width, height = systemstate.TermWidth(), systemstate.TermHeight()
+	}
+
 	header := ASCIICastHeader{
-		Version:   2,
-		Width:     systemstate.TermWidth(),
-		Height:    systemstate.TermHeight(),
-		Timestamp: m.StartTimeUnix,
-		Env:       map[string]string{"SHELL": m.Shell, "TERM": m.Term},
-		Title:     title,
+		Version:    2,
+		Width:      width,
+		Height:     height,
+		Timestamp:  m.StartTimeUnix,
+		Env:        map[string]string{},
+		Title:      title,
+		Command:    m.Command,
+		ExitCode:   m.ExitCode,
+		ExitSignal: m.ExitSignal,
+	}
+
+	for k, v := range m.Env {
+		header.Env[k] = v
 	}
+	header.Env["SHELL"], header.Env["TERM"] = m.Shell, m.Term
 
 	if m.DurationSeconds != 0 {
 		header.Duration = m.DurationSeconds
 	}
 
+	if !m.Context.IsEmpty() {
+		context := m.Context
+		header.Context = &context
+	}
+
 	if theme() != nil {
 		header.Theme = theme()
 	}
@@ -78,6 +78,8 @@ func TestAsciiCastFormatterWriteHeaderPlain(t *testing.T) {
 	assert.Equal(t, header.Height, uint16('h'))
 	assert.Equal(t, header.Duration, float64(0))
 	assert.Equal(t, header.Env, map[string]string{"SHELL": "", "TERM": ""})
+	assert.Equal(t, header.Command, "")
+	assert.Nil(t, header.Context)
 }
 
 func TestAsciiCastFormatterWriteHeaderWithContent(t *testing.T) {
@@ -93,6 +95,8 @@ func TestAsciiCastFormatterWriteHeaderWithContent(t *testing.T) {
 		Title:           "SomeTitle",
 		Shell:           "/bin/bash",
 		Term:            "otherTerm",
+		Command:         "nmap -sV 10.0.0.1",
+		Env:             map[string]string{"LANG": "C", "SHELL": "ignored"},
 	})
 
 	assert.Nil(t, err)
@@ -109,5 +113,27 @@ func TestAsciiCastFormatterWriteHeaderWithContent(t *testing.T) {
 	assert.Equal(t, header.Width, uint16('w'))
 	assert.Equal(t, header.Height, uint16('h'))
 	assert.Equal(t, header.Duration, float64(10))
-	assert.Equal(t, header.Env, map[string]string{"SHELL": "/bin/bash", "TERM": "otherTerm"})
+	assert.Equal(t, header.Env, map[string]string{"SHELL": "/bin/bash", "TERM": "otherTerm", "LANG": "C"})
+	assert.Equal(t, header.Command, "nmap -sV 10.0.0.1")
+}
+
+func TestAsciiCastFormatterWriteHeaderWithContext(t *testing.T) {
+	formatter := asciiCast{clock: clockwork.NewFakeClock()}
+	context := RecordingContext{
+		Hostname:      "kali",
+		User:          "operator",
+		IPAddresses:   []string{"10.0.0.5"},
+		WorkingDir:    "/home/operator",
+		OS:            "linux/amd64",
+		ATermVersion:  "v1.0.0",
+		OperationSlug: "op",
+	}
+
+	bytes, err := formatter.WriteHeader(Metadata{Context: context})
+	assert.Nil(t, err)
+
+	var header ASCIICastHeader
+	err = json.Unmarshal(bytes, &header)
+	assert.Nil(t, err)
+	assert.Equal(t, &context, header.Context)
 }
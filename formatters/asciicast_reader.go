@@ -0,0 +1,76 @@
+package formatters
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ASCIICastReader reads an asciicast (v2) file, one event at a time. The header is read when the
+// reader is created.
+type ASCIICastReader struct {
+	Header ASCIICastHeader
+	source *bufio.Reader
+	line   int
+}
+
+// NewASCIICastReader creates a reader for the asciicast content in r, reading the header
+// immediately. An error is returned if the header cannot be read, or is not a version 2 header.
+func NewASCIICastReader(r io.Reader) (*ASCIICastReader, error) {
+	reader := &ASCIICastReader{source: bufio.NewReader(r)}
+	line, err := reader.nextLine()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("Recording is empty")
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(line, &reader.Header); err != nil {
+		return nil, fmt.Errorf("Unable to parse recording header: %w", err)
+	}
+	if reader.Header.Version != 2 {
+		return nil, fmt.Errorf("Unsupported asciicast version: %v", reader.Header.Version)
+	}
+	return reader, nil
+}
+
+// Next reads the next event. Returns io.EOF once all events have been read.
+func (r *ASCIICastReader) Next() (ASCIInemaEvent, error) {
+	var evt ASCIInemaEvent
+	line, err := r.nextLine()
+	if err != nil {
+		return evt, err
+	}
+
+	var fields []interface{}
+	if err = json.Unmarshal(line, &fields); err != nil {
+		return evt, fmt.Errorf("Unable to parse event on line %v: %w", r.line, err)
+	}
+	var ok [3]bool
+	if len(fields) == 3 {
+		evt.When, ok[0] = fields[0].(float64)
+		evt.Type, ok[1] = fields[1].(string)
+		evt.Data, ok[2] = fields[2].(string)
+	}
+	if !(ok[0] && ok[1] && ok[2]) {
+		return evt, fmt.Errorf("Malformed event on line %v", r.line)
+	}
+	return evt, nil
+}
+
+// nextLine reads the next non-blank line, without the trailing newline
+func (r *ASCIICastReader) nextLine() ([]byte, error) {
+	for {
+		line, err := r.source.ReadBytes('\n')
+		r.line++
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package formatters
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestASCIICastReader(t *testing.T) {
+	content := `{"version": 2, "width": 80, "height": 24, "timestamp": 1600000000, "title": "demo", "env": {"SHELL": "/bin/bash"}}
+[0.5, "o", "$ "]
+
+[1.25, "i", "ls\r"]
+[1.5, "o", "file.txt\r\n"]`
+
+	reader, err := NewASCIICastReader(strings.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(80), reader.Header.Width)
+	assert.Equal(t, "demo", reader.Header.Title)
+	assert.Equal(t, "/bin/bash", reader.Header.Env["SHELL"])
+
+	events := []ASCIInemaEvent{}
+	for {
+		evt, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, evt)
+	}
+	assert.Equal(t, []ASCIInemaEvent{
+		{When: 0.5, Type: "o", Data: "$ "},
+		{When: 1.25, Type: "i", Data: "ls\r"},
+		{When: 1.5, Type: "o", Data: "file.txt\r\n"},
+	}, events)
+}
+
+func TestASCIICastReaderErrors(t *testing.T) {
+	_, err := NewASCIICastReader(strings.NewReader(""))
+	assert.Error(t, err)
+
+	_, err = NewASCIICastReader(strings.NewReader(`{"version": 1}`))
+	assert.Error(t, err)
+
+	reader, err := NewASCIICastReader(strings.NewReader(`{"version": 2}` + "\n" + `[1, "o"]`))
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.EqualError(t, err, "Malformed event on line 2")
+}
@@ -12,9 +12,12 @@ type ASCIICastHeader struct {
 	Duration      float64           `json:"duration,omitempty"`
 	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
 	Command       string            `json:"command,omitempty"`
+	ExitCode      *int              `json:"exit_code,omitempty"`   // non-standard: set once recording ends
+	ExitSignal    string            `json:"exit_signal,omitempty"` // non-standard: set once recording ends
 	Title         string            `json:"title,omitempty"`
 	Env           map[string]string `json:"env"`
 	Theme         *ASCIICastTheme   `json:"theme,omitempty"`
+	Context       *RecordingContext `json:"aterm_context,omitempty"` // non-standard: where the recording was made
 }
 
 // ASCIICastTheme is the struct that represents the Asciinema file theme sub-structure
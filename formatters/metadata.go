@@ -9,8 +9,39 @@ type Metadata struct {
 	Title           string
 	Shell           string
 	Term            string
+	Command         string
+	// ExitCode and ExitSignal describe how the recorded process ended, once it has
+	ExitCode   *int
+	ExitSignal string
+	// Env holds any additional environment variables to note in the recording. Shell and Term
+	// take priority over SHELL and TERM entries.
+	Env map[string]string
+	// Context describes where, and by whom, the recording was made
+	Context RecordingContext
+	// Width and Height give the size of the terminal, in columns and rows. When not provided, the
+	// current size (see systemstate) is used.
+	Width  uint16
+	Height uint16
+}
+
+// RecordingContext describes where, and by whom, a recording was made. Every field is optional, as
+// details may be withheld for privacy.
+type RecordingContext struct {
+	Hostname      string   `json:"hostname,omitempty"`
+	User          string   `json:"user,omitempty"`
+	IPAddresses   []string `json:"ip_addresses,omitempty"`
+	WorkingDir    string   `json:"working_dir,omitempty"`
+	OS            string   `json:"os,omitempty"`
+	ATermVersion  string   `json:"aterm_version,omitempty"`
+	OperationSlug string   `json:"operation,omitempty"`
+}
+
+// IsEmpty returns true if no details are known
+func (c RecordingContext) IsEmpty() bool {
+	return c.Hostname == "" && c.User == "" && len(c.IPAddresses) == 0 && c.WorkingDir == "" &&
+		c.OS == "" && c.ATermVersion == "" && c.OperationSlug == ""
 }
 
 func (m Metadata) String() string {
-	return fmt.Sprintf("%v %v %v %v %v", m.StartTimeUnix, m.DurationSeconds, m.Title, m.Shell, m.Term)
+	return fmt.Sprintf("%v %v %v %v %v %v", m.StartTimeUnix, m.DurationSeconds, m.Title, m.Shell, m.Term, m.Command)
 }
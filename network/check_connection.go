@@ -1,8 +1,12 @@
 package network
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/theparanoids/ashirt-server/backend/dtos"
@@ -12,18 +16,32 @@ var ErrConnectionUnauthorized = errors.New("Could not connect: Unauthorized")
 var ErrConnectionNotFound = errors.New("Could not connect: Not Found")
 var ErrConnectionUnknownStatus = errors.New("Could not connect: Unknown status")
 var ErrOutOfDateServer = errors.New("Could not connect: Invalid or out of date server")
+var ErrTLSUnknownAuthority = errors.New("Could not connect: Server certificate is signed by an unknown authority")
+var ErrTLSHostnameMismatch = errors.New("Could not connect: Server certificate does not match the server's name")
+var ErrTLSCertificateInvalid = errors.New("Could not connect: Server certificate is invalid")
+var ErrTLSHandshakeFailed = errors.New("Could not connect: TLS handshake failed")
+var ErrTLSNotTLS = errors.New("Could not connect: Server did not respond with TLS")
 
 // TestConnection performs a basic query to the backend and interprets the results.
 // There are a few scenarios. A successful connection returns ("", nil)
 // Otherwise, the return structure is ("suggestion to fix (if any)", underlyingError)
 // the underlying error is likely (but not necessarily) one of:
-// ErrConnectionUnknownStatus, ErrConnectionNotFound, ErrConnectionUnauthorized
+// ErrConnectionUnknownStatus, ErrConnectionNotFound, ErrConnectionUnauthorized, or, for TLS-related
+// issues: ErrTLSUnknownAuthority, ErrTLSHostnameMismatch, ErrTLSCertificateInvalid,
+// ErrTLSHandshakeFailed, ErrTLSNotTLS
 // use errors.Is(err, target) to check these errors
 func TestConnection() (string, error) {
-	resp, err := makeJSONRequest("GET", apiURL+"/checkconnection", http.NoBody)
+	return TestConnectionWithContext(context.Background())
+}
+
+// TestConnectionWithContext is identical to TestConnection, but can be cancelled via the provided
+// context
+func TestConnectionWithContext(ctx context.Context) (string, error) {
+	resp, err := makeJSONRequest(ctx, "GET", apiURL+"/checkconnection", http.NoBody)
 	if err != nil {
-		return "", err
+		return classifyTLSError(err)
 	}
+	defer resp.Body.Close()
 	statusCode := resp.StatusCode
 	if statusCode == http.StatusOK {
 		var cc dtos.CheckConnection
@@ -40,3 +58,33 @@ func TestConnection() (string, error) {
 		return "", fmt.Errorf("%w : Status Code: %v", ErrConnectionUnknownStatus, statusCode)
 	}
 }
+
+// classifyTLSError inspects a connection error for common TLS problems, returning a suggestion and
+// a matching ErrTLS* error (wrapping the original error) if one is recognized. Otherwise, the
+// error is returned as-is
+func classifyTLSError(err error) (string, error) {
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var alertErr tls.AlertError
+	var recordHeaderErr tls.RecordHeaderError
+	var opErr *net.OpError
+
+	wrap := func(tlsErr error) error { return fmt.Errorf("%w : %v", tlsErr, err) }
+
+	switch {
+	case errors.As(err, &unknownAuthErr):
+		return "Check the CA bundle (caCertPath), or whether a proxy is intercepting traffic", wrap(ErrTLSUnknownAuthority)
+	case errors.As(err, &hostnameErr):
+		return "Check API URL: it must match a name on the server's certificate", wrap(ErrTLSHostnameMismatch)
+	case errors.As(err, &invalidErr):
+		return "Check that the server's certificate has not expired, and that your system clock is correct", wrap(ErrTLSCertificateInvalid)
+	// the http client reports record headers that look like HTTP as a scheme mismatch
+	case errors.As(err, &recordHeaderErr), errors.Is(err, http.ErrSchemeMismatch):
+		return "Check API URL: the server may only support http, rather than https", wrap(ErrTLSNotTLS)
+	// alerts sent by the server are only exposed as a "remote error"
+	case errors.As(err, &alertErr), errors.As(err, &opErr) && opErr.Op == "remote error":
+		return "The server rejected the connection. If it requires a client certificate, check clientCertPath and clientKeyPath", wrap(ErrTLSHandshakeFailed)
+	}
+	return "", err
+}
@@ -0,0 +1,37 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Codeblock is the content of code block evidence (see ContentTypeCodeblock)
+type Codeblock struct {
+	// Language is the language of the code (e.g. python), used for syntax highlighting. Blank
+	// for plain text.
+	Language string
+	Content  string
+	// Source notes where the code came from (e.g. a URL, file or command)
+	Source string
+}
+
+// codeblockContent is the format ASHIRT expects for code block content
+type codeblockContent struct {
+	ContentSubtype string `json:"contentSubtype"`
+	Content        string `json:"content"`
+	Metadata       struct {
+		Source string `json:"source,omitempty"`
+	} `json:"metadata"`
+}
+
+// Encode converts the code block into content suitable for UploadInput.Content
+func (c Codeblock) Encode() (io.ReadSeeker, error) {
+	content := codeblockContent{ContentSubtype: c.Language, Content: c.Content}
+	content.Metadata.Source = c.Source
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(encoded), nil
+}
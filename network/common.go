@@ -1,11 +1,14 @@
 package network
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -14,12 +17,20 @@ import (
 	"github.com/theparanoids/aterm/errors"
 )
 
+// client is shared by all requests. No client-wide timeout is set here, as uploads can take an
+// arbitrarily long time. Instead, timeouts are applied per-request via contexts
+// (see SetRequestTimeout and SetUploadTimeout)
 var client = &http.Client{}
 
 var apiURL string
 var accessKey string
 var secretKey []byte
 
+var requestTimeout = 30 * time.Second
+var uploadTimeout time.Duration
+var maxRetries = 3
+var retryDelay = 500 * time.Millisecond
+
 // SetBaseURL Sets the url to use as a base for all service contact
 // Note: this function only requires the url to reach the frontend service.
 // routes will be deduced from that.
@@ -49,6 +60,31 @@ func SetSecretKey(key string) error {
 	return err
 }
 
+// SetRequestTimeout sets the maximum time any single (non-upload) request attempt may take,
+// including reading the response. A zero or negative value disables the timeout.
+func SetRequestTimeout(d time.Duration) {
+	requestTimeout = d
+}
+
+// SetUploadTimeout sets the maximum time an evidence upload may take. A zero or negative value
+// disables the timeout, which is the default, as large recordings can take quite some time to
+// upload.
+func SetUploadTimeout(d time.Duration) {
+	uploadTimeout = d
+}
+
+// SetMaxRetries sets how many additional attempts are made for idempotent requests (e.g. GETs)
+// that fail due to a network error or a temporary server error. Zero disables retries.
+func SetMaxRetries(n int) {
+	maxRetries = n
+}
+
+// SetRetryDelay sets the base delay between retries. Each retry doubles the delay of the previous
+// retry, with some random jitter applied.
+func SetRetryDelay(d time.Duration) {
+	retryDelay = d
+}
+
 // addAuthentication adds Date and Authentication headers to the provided request
 // returns an error if building an appropriate authentication value fails, nil otherwise
 // Note: This should be called immediately before sending a request.
@@ -88,17 +124,114 @@ func readResponseBody(container interface{}, body io.Reader) error {
 	return nil
 }
 
-func makeJSONRequest(method, url string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, body)
+// makeJSONRequest sends a request with a json body, applying the configured request timeout.
+// Idempotent requests (GET, HEAD, PUT and DELETE) are retried when they fail due to network errors
+// or temporary server errors, provided their body can be re-sent (http.NoBody, or an io.Seeker,
+// which is rewound before each attempt). POST requests are never retried, as a request that
+// reached the server before failing may have already created something. The returned response
+// body should be closed when the caller is done with it.
+func makeJSONRequest(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	seeker, seekable := body.(io.Seeker)
+	if !isIdempotent(method) || (!seekable && body != http.NoBody) {
+		return makeJSONRequestAttempt(ctx, method, url, body)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if seekable {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := makeJSONRequestAttempt(ctx, method, url, body)
+		if attempt >= maxRetries || !isRetryable(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoffDelay(attempt)):
+		}
+	}
+}
+
+func makeJSONRequestAttempt(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if requestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/json")
 
 	if err = addAuthentication(req); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
 		return nil, err
 	}
+	// the timeout must remain in effect while the body is read, so release it only once closed
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// isIdempotent determines if sending a request with the given method more than once has the same
+// effect as sending it once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isRetryable determines if a request failed in a way that may succeed if tried again
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// certificate and handshake problems won't resolve themselves
+		if suggestion, _ := classifyTLSError(err); suggestion != "" {
+			return false
+		}
+		// only failing to reach the server, or to hear back from it in time, is worth retrying.
+		// Other errors (e.g. an invalid URL) would fail in the same way again.
+		var netErr net.Error
+		var opErr *net.OpError
+		return errors.As(err, &opErr) || errors.As(err, &netErr) && netErr.Timeout() ||
+			errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) // the connection was dropped
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// backoffDelay returns the delay to wait before the next retry: exponential backoff, with
+// "equal jitter" applied (i.e. somewhere between half and all of the exponential delay)
+func backoffDelay(attempt int) time.Duration {
+	delay := retryDelay << uint(attempt)
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// cancelOnClose releases a request's context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
-	return client.Do(req)
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
 }
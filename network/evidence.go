@@ -0,0 +1,105 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/theparanoids/aterm/errors"
+)
+
+// ErrEvidenceActionNotSupported is returned when the server does not support changing or deleting
+// evidence via the API (i.e. older servers). As the server responds in the same way, this is also
+// returned if the operation or evidence does not exist.
+var ErrEvidenceActionNotSupported = errors.New("The server does not support changing evidence via the API")
+
+// EvidenceUpdate describes changes to existing evidence. Unset fields leave the evidence unchanged.
+type EvidenceUpdate struct {
+	OperationSlug string
+	EvidenceUUID  string
+	// Description replaces the evidence's description, if not nil
+	Description  *string
+	TagsToAdd    []int64
+	TagsToRemove []int64
+	// Content replaces the evidence's content, if not nil. As with uploads, the content must be
+	// seekable (see UploadInput).
+	Content    io.ReadSeeker
+	Filename   string
+	OnProgress func(sent, total int64)
+}
+
+// UpdateEvidence changes the description, tags and/or content of existing evidence
+func UpdateEvidence(update EvidenceUpdate) error {
+	return UpdateEvidenceWithContext(context.Background(), update)
+}
+
+// UpdateEvidenceWithContext is identical to UpdateEvidence, but can be cancelled via the provided
+// context. As with uploads, the upload timeout applies, and updates are never retried.
+func UpdateEvidenceWithContext(ctx context.Context, update EvidenceUpdate) error {
+	if uploadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, uploadTimeout)
+		defer cancel()
+	}
+
+	tagsToAdd, _ := json.Marshal(nonNilIDs(update.TagsToAdd))
+	tagsToRemove, _ := json.Marshal(nonNilIDs(update.TagsToRemove))
+	form := multipartForm{
+		fields: [][2]string{
+			{"tagsToAdd", string(tagsToAdd)},
+			{"tagsToRemove", string(tagsToRemove)},
+		},
+		fileField:  "content",
+		filename:   update.Filename,
+		content:    update.Content,
+		onProgress: update.OnProgress,
+	}
+	if update.Description != nil {
+		form.fields = append(form.fields, [2]string{"description", *update.Description})
+	}
+
+	resp, err := sendMultipartForm(ctx, "PUT", evidenceURL(update.OperationSlug, update.EvidenceUUID), form)
+	if err != nil {
+		return errors.Append(err, ErrCannotConnect)
+	}
+	defer resp.Body.Close()
+	return evaluateEvidenceResponse(resp.StatusCode)
+}
+
+// DeleteEvidence removes existing evidence. Findings that include the evidence are kept.
+func DeleteEvidence(operationSlug, evidenceUUID string) error {
+	return DeleteEvidenceWithContext(context.Background(), operationSlug, evidenceUUID)
+}
+
+// DeleteEvidenceWithContext is identical to DeleteEvidence, but can be cancelled via the provided
+// context
+func DeleteEvidenceWithContext(ctx context.Context, operationSlug, evidenceUUID string) error {
+	body := []byte(`{"deleteAssociatedFindings": false}`)
+	resp, err := makeJSONRequest(ctx, "DELETE", evidenceURL(operationSlug, evidenceUUID), bytes.NewReader(body))
+	if err != nil {
+		return errors.Append(err, ErrCannotConnect)
+	}
+	defer resp.Body.Close()
+	return evaluateEvidenceResponse(resp.StatusCode)
+}
+
+func evidenceURL(operationSlug, evidenceUUID string) string {
+	return apiURL + "/operations/" + operationSlug + "/evidence/" + evidenceUUID
+}
+
+func evaluateEvidenceResponse(statusCode int) error {
+	if statusCode == http.StatusNotFound || statusCode == http.StatusMethodNotAllowed {
+		return ErrEvidenceActionNotSupported
+	}
+	return evaluateResponseStatusCode(statusCode)
+}
+
+// nonNilIDs ensures that an empty list of IDs is encoded as [], rather than null
+func nonNilIDs(ids []int64) []int64 {
+	if ids == nil {
+		return []int64{}
+	}
+	return ids
+}
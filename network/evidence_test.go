@@ -0,0 +1,78 @@
+package network_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/network"
+)
+
+func TestUpdateEvidence(t *testing.T) {
+	var method, path, content string
+	var fields map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		fields = r.MultipartForm.Value
+		content = ""
+		if files := r.MultipartForm.File["content"]; len(files) > 0 {
+			f, _ := files[0].Open()
+			raw, _ := ioutil.ReadAll(f)
+			f.Close()
+			content = string(raw)
+		}
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	description := "updated"
+	err := network.UpdateEvidence(network.EvidenceUpdate{
+		OperationSlug: "op",
+		EvidenceUUID:  "abc-123",
+		Description:   &description,
+		TagsToAdd:     []int64{1, 2},
+		Content:       bytes.NewReader([]byte("new content")),
+		Filename:      "rec.cast",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "PUT", method)
+	require.Equal(t, "/api/operations/op/evidence/abc-123", path)
+	require.Equal(t, []string{"updated"}, fields["description"])
+	require.Equal(t, []string{"[1,2]"}, fields["tagsToAdd"])
+	require.Equal(t, []string{"[]"}, fields["tagsToRemove"])
+	require.Equal(t, "new content", content)
+
+	// unchanged details are not sent
+	err = network.UpdateEvidence(network.EvidenceUpdate{OperationSlug: "op", EvidenceUUID: "abc-123", TagsToRemove: []int64{3}})
+	require.NoError(t, err)
+	require.NotContains(t, fields, "description")
+	require.Equal(t, []string{"[3]"}, fields["tagsToRemove"])
+	require.Equal(t, "", content)
+}
+
+func TestDeleteEvidence(t *testing.T) {
+	var method, path string
+	var body []byte
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	require.NoError(t, network.DeleteEvidence("op", "abc-123"))
+	require.Equal(t, "DELETE", method)
+	require.Equal(t, "/api/operations/op/evidence/abc-123", path)
+	require.JSONEq(t, `{"deleteAssociatedFindings": false}`, string(body))
+
+	status = http.StatusMethodNotAllowed
+	err := network.DeleteEvidence("op", "abc-123")
+	require.True(t, errors.Is(err, network.ErrEvidenceActionNotSupported))
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/theparanoids/ashirt-server/backend/dtos"
@@ -11,12 +12,19 @@ var ErrCannotConnect = errors.New("Unable to connect to the server")
 
 // GetOperations retrieves all of the operations that are exposed to backend tools (api routes)
 func GetOperations() ([]dtos.Operation, error) {
+	return GetOperationsWithContext(context.Background())
+}
+
+// GetOperationsWithContext is identical to GetOperations, but can be cancelled via the provided
+// context
+func GetOperationsWithContext(ctx context.Context) ([]dtos.Operation, error) {
 	var ops []dtos.Operation
 
-	resp, err := makeJSONRequest("GET", apiURL+"/operations", http.NoBody)
+	resp, err := makeJSONRequest(ctx, "GET", apiURL+"/operations", http.NoBody)
 	if err != nil {
 		return ops, errors.Append(err, ErrCannotConnect)
 	}
+	defer resp.Body.Close()
 
 	if err = evaluateResponseStatusCode(resp.StatusCode); err != nil {
 		return ops, err
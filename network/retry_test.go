@@ -0,0 +1,248 @@
+package network_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/network"
+)
+
+// useRetryPolicy sets up the network package for quick retries, and restores the defaults
+// once the test completes
+func useRetryPolicy(t *testing.T, retries int, timeout time.Duration) {
+	network.SetMaxRetries(retries)
+	network.SetRetryDelay(time.Millisecond)
+	network.SetRequestTimeout(timeout)
+	t.Cleanup(func() {
+		network.SetMaxRetries(3)
+		network.SetRetryDelay(500 * time.Millisecond)
+		network.SetRequestTimeout(30 * time.Second)
+	})
+}
+
+// newFlakyServer fails the first failCount requests with the given status, then responds with
+// the provided response. The returned counter tracks the total number of requests received.
+func newFlakyServer(failCount int32, failStatus int, resp string) (*httptest.Server, *int32) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= failCount {
+			w.WriteHeader(failStatus)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(resp))
+	}))
+	return srv, &hits
+}
+
+func TestGetOperationsRetriesFlakyServer(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	srv, hits := newFlakyServer(2, http.StatusServiceUnavailable, `[{"slug": "s1", "name": "Jack"}]`)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	ops, err := network.GetOperations()
+
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	require.Equal(t, "s1", ops[0].Slug)
+	require.Equal(t, int32(3), atomic.LoadInt32(hits))
+}
+
+func TestGetTagsGivesUpAfterMaxRetries(t *testing.T) {
+	useRetryPolicy(t, 2, time.Second)
+	srv, hits := newFlakyServer(100, http.StatusBadGateway, `[]`)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.GetTags("op")
+
+	require.Error(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(hits))
+}
+
+func TestGetOperationsDoesNotRetryPermanentErrors(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	srv, hits := newFlakyServer(100, http.StatusUnauthorized, `[]`)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.GetOperations()
+
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(hits))
+}
+
+func TestGetOperationsRetriesServerErrors(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	srv, hits := newFlakyServer(1, http.StatusInternalServerError, `[]`)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.GetOperations()
+
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(hits))
+}
+
+func TestGetOperationsRetriesDroppedConnections(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.GetOperations()
+
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestGetOperationsDoesNotRetryInvalidURLs(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	network.SetRetryDelay(time.Minute)
+	network.SetBaseURL("ftp://ashirt.example.com")
+
+	start := time.Now()
+	_, err := network.GetOperations()
+
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second), "invalid URLs should not be retried")
+}
+
+func TestCreateTagIsNotRetried(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	srv, hits := newFlakyServer(1, http.StatusServiceUnavailable, `{"id": 1, "name": "t"}`)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.CreateTag("op", "t", "blue")
+
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(hits))
+}
+
+// newFlakyBodyServer fails the first failCount requests with a 503, then responds with a 200.
+// The body of every request received is recorded, in order.
+func newFlakyBodyServer(failCount int) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	bodies := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(content))
+		attempts := len(bodies)
+		mu.Unlock()
+		if attempts <= failCount {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return srv, &bodies
+}
+
+func TestUpdateTagRetriesWithSameBody(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	srv, bodies := newFlakyBodyServer(2)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	err := network.UpdateTag("op", 4, "renamed", "blue")
+
+	require.NoError(t, err)
+	require.Len(t, *bodies, 3)
+	require.JSONEq(t, `{"name": "renamed", "colorName": "blue"}`, (*bodies)[0])
+	require.Equal(t, (*bodies)[0], (*bodies)[1])
+	require.Equal(t, (*bodies)[0], (*bodies)[2])
+}
+
+func TestDeleteEvidenceRetriesWithSameBody(t *testing.T) {
+	useRetryPolicy(t, 3, time.Second)
+	srv, bodies := newFlakyBodyServer(1)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	err := network.DeleteEvidence("op", "uuid")
+
+	require.NoError(t, err)
+	require.Len(t, *bodies, 2)
+	require.JSONEq(t, `{"deleteAssociatedFindings": false}`, (*bodies)[0])
+	require.Equal(t, (*bodies)[0], (*bodies)[1])
+}
+
+func TestSlowServerTimesOut(t *testing.T) {
+	useRetryPolicy(t, 1, 50*time.Millisecond)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	start := time.Now()
+	_, err := network.GetOperations()
+
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, int32(2), atomic.LoadInt32(&hits), "timed out requests should be retried")
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestSlowServerRecovers(t *testing.T) {
+	useRetryPolicy(t, 2, 100*time.Millisecond)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		w.Write([]byte(`[{"id": 4, "name": "recovered"}]`))
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	tags, err := network.GetTags("op")
+
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	require.Equal(t, "recovered", tags[0].Name)
+}
+
+func TestCancelledContextStopsRetries(t *testing.T) {
+	useRetryPolicy(t, 100, time.Second)
+	network.SetRetryDelay(20 * time.Millisecond)
+	srv, hits := newFlakyServer(1000, http.StatusServiceUnavailable, `[]`)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := network.GetOperationsWithContext(ctx)
+
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Less(t, atomic.LoadInt32(hits), int32(100))
+}
@@ -2,9 +2,12 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"math/rand"
 	"net/http"
+	"strconv"
 
 	"github.com/theparanoids/ashirt-server/backend/dtos"
 	"github.com/theparanoids/aterm/errors"
@@ -12,12 +15,18 @@ import (
 
 // GetTags retrieves a list of all tags from the server for the given operation slug
 func GetTags(operationSlug string) ([]dtos.Tag, error) {
+	return GetTagsWithContext(context.Background(), operationSlug)
+}
+
+// GetTagsWithContext is identical to GetTags, but can be cancelled via the provided context
+func GetTagsWithContext(ctx context.Context, operationSlug string) ([]dtos.Tag, error) {
 	var tags []dtos.Tag
 
-	resp, err := makeJSONRequest("GET", apiURL+"/operations/"+operationSlug+"/tags", http.NoBody)
+	resp, err := makeJSONRequest(ctx, "GET", apiURL+"/operations/"+operationSlug+"/tags", http.NoBody)
 	if err != nil {
 		return tags, errors.Append(err, ErrCannotConnect)
 	}
+	defer resp.Body.Close()
 
 	if err = evaluateResponseStatusCode(resp.StatusCode); err != nil {
 		return tags, err
@@ -29,6 +38,12 @@ func GetTags(operationSlug string) ([]dtos.Tag, error) {
 // CreateTag generates a new tag on the backend. If successful, the new tag with tag ID will
 // be returned
 func CreateTag(operationSlug, name, colorName string) (*dtos.Tag, error) {
+	return CreateTagWithContext(context.Background(), operationSlug, name, colorName)
+}
+
+// CreateTagWithContext is identical to CreateTag, but can be cancelled via the provided context.
+// Note that tag creation is never retried, as it is not idempotent.
+func CreateTagWithContext(ctx context.Context, operationSlug, name, colorName string) (*dtos.Tag, error) {
 	type TagInput struct {
 		Name      string `json:"name"`
 		ColorName string `json:"colorName"`
@@ -40,11 +55,12 @@ func CreateTag(operationSlug, name, colorName string) (*dtos.Tag, error) {
 		return nil, errors.Wrap(err, "Unable to create tag")
 	}
 
-	resp, err := makeJSONRequest("POST", apiURL+"/operations/"+operationSlug+"/tags", bytes.NewReader(content))
+	resp, err := makeJSONRequest(ctx, "POST", apiURL+"/operations/"+operationSlug+"/tags", bytes.NewReader(content))
 
 	if err != nil {
 		return nil, errors.Append(err, ErrCannotConnect)
 	}
+	defer resp.Body.Close()
 
 	if err = evaluateResponseStatusCode(resp.StatusCode); err != nil {
 		return nil, err
@@ -56,6 +72,57 @@ func CreateTag(operationSlug, name, colorName string) (*dtos.Tag, error) {
 	return &tag, err
 }
 
+// ErrTagActionNotSupported is returned when the server does not support changing or deleting tags
+// via the API (i.e. older servers). As the server responds in the same way, this is also returned
+// if the operation or tag does not exist.
+var ErrTagActionNotSupported = errors.New("The server does not support changing tags via the API")
+
+// UpdateTag renames (and/or recolors) the tag with the given ID. A blank colorName leaves the
+// color unchanged.
+func UpdateTag(operationSlug string, tagID int64, name, colorName string) error {
+	return UpdateTagWithContext(context.Background(), operationSlug, tagID, name, colorName)
+}
+
+// UpdateTagWithContext is identical to UpdateTag, but can be cancelled via the provided context
+func UpdateTagWithContext(ctx context.Context, operationSlug string, tagID int64, name, colorName string) error {
+	type TagInput struct {
+		Name      string `json:"name"`
+		ColorName string `json:"colorName,omitempty"`
+	}
+	content, err := json.Marshal(TagInput{Name: name, ColorName: colorName})
+	if err != nil {
+		return errors.Wrap(err, "Unable to update tag")
+	}
+	return sendTagChange(ctx, "PUT", operationSlug, tagID, bytes.NewReader(content))
+}
+
+// DeleteTag removes the tag with the given ID from the operation (and from all of its evidence)
+func DeleteTag(operationSlug string, tagID int64) error {
+	return DeleteTagWithContext(context.Background(), operationSlug, tagID)
+}
+
+// DeleteTagWithContext is identical to DeleteTag, but can be cancelled via the provided context
+func DeleteTagWithContext(ctx context.Context, operationSlug string, tagID int64) error {
+	// the request signer requires a re-readable body for all but GET requests, which http.NoBody
+	// is not
+	return sendTagChange(ctx, "DELETE", operationSlug, tagID, bytes.NewReader(nil))
+}
+
+// sendTagChange sends a request (that has no meaningful response) for a single tag
+func sendTagChange(ctx context.Context, method, operationSlug string, tagID int64, body io.Reader) error {
+	url := apiURL + "/operations/" + operationSlug + "/tags/" + strconv.FormatInt(tagID, 10)
+	resp, err := makeJSONRequest(ctx, method, url, body)
+	if err != nil {
+		return errors.Append(err, ErrCannotConnect)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return ErrTagActionNotSupported
+	}
+	return evaluateResponseStatusCode(resp.StatusCode)
+}
+
 func RandomTagColor() string {
 	allTagColors := []string{
 		"blue",
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/theparanoids/aterm/common"
+	"github.com/theparanoids/aterm/errors"
+)
+
+// ConfigureTransport replaces the transport used for all ASHIRT requests (not github requests) with
+// one built from the provided options. If an error is returned, the existing transport is left
+// in place.
+func ConfigureTransport(opts common.TransportOptions) error {
+	transport, err := NewTransport(opts)
+	if err != nil {
+		return err
+	}
+	client.Transport = transport
+	return nil
+}
+
+// NewTransport builds an http.Transport from the provided options.
+func NewTransport(opts common.TransportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse proxy URL")
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, errors.New(`Unsupported proxy scheme "` + proxyURL.Scheme + `" (expected http, https, or socks5)`)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	if opts.CACertPath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(opts.CACertPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to read CA bundle")
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates could be parsed from the CA bundle at " + opts.CACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.ClientCertPath != "" || opts.ClientKeyPath != "" {
+		if opts.ClientCertPath == "" || opts.ClientKeyPath == "" {
+			return nil, errors.New("Both a client certificate and a client key must be provided")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertPath, opts.ClientKeyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,172 @@
+package network_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/common"
+	"github.com/theparanoids/aterm/network"
+)
+
+var checkConnectionHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"ok": true}`))
+})
+
+// useTransport applies the given transport options, and restores the default transport once the
+// test completes
+func useTransport(t *testing.T, opts common.TransportOptions) {
+	require.NoError(t, network.ConfigureTransport(opts))
+	t.Cleanup(func() { network.ConfigureTransport(common.TransportOptions{}) })
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), strings.ToLower(strings.ReplaceAll(blockType, " ", "_"))+".pem")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// makeClientCert generates a self-signed client certificate, returning the parsed certificate,
+// and the paths to the PEM-encoded certificate and key
+func makeClientCert(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "aterm-test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return cert, writePEM(t, "CERTIFICATE", der), writePEM(t, "EC PRIVATE KEY", keyDer)
+}
+
+func TestUntrustedServerReportsUnknownAuthority(t *testing.T) {
+	useRetryPolicy(t, 2, time.Second)
+	useTransport(t, common.TransportOptions{})
+	srv := httptest.NewTLSServer(checkConnectionHandler)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	suggestion, err := network.TestConnection()
+
+	require.ErrorIs(t, err, network.ErrTLSUnknownAuthority)
+	require.Contains(t, suggestion, "caCertPath")
+}
+
+func TestCABundleTrustsServer(t *testing.T) {
+	srv := httptest.NewTLSServer(checkConnectionHandler)
+	defer srv.Close()
+	useTransport(t, common.TransportOptions{CACertPath: writePEM(t, "CERTIFICATE", srv.Certificate().Raw)})
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.TestConnection()
+
+	require.NoError(t, err)
+}
+
+func TestHostnameMismatch(t *testing.T) {
+	srv := httptest.NewTLSServer(checkConnectionHandler)
+	defer srv.Close()
+	useTransport(t, common.TransportOptions{CACertPath: writePEM(t, "CERTIFICATE", srv.Certificate().Raw)})
+	// the test certificate is only valid for example.com and loopback IPs
+	network.SetBaseURL(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+
+	_, err := network.TestConnection()
+
+	require.ErrorIs(t, err, network.ErrTLSHostnameMismatch)
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	useTransport(t, common.TransportOptions{InsecureSkipVerify: true})
+	srv := httptest.NewTLSServer(checkConnectionHandler)
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	_, err := network.TestConnection()
+
+	require.NoError(t, err)
+}
+
+func TestHTTPSAgainstPlainServer(t *testing.T) {
+	useTransport(t, common.TransportOptions{})
+	srv := httptest.NewServer(checkConnectionHandler)
+	defer srv.Close()
+	network.SetBaseURL(strings.Replace(srv.URL, "http://", "https://", 1))
+
+	_, err := network.TestConnection()
+
+	require.ErrorIs(t, err, network.ErrTLSNotTLS)
+}
+
+func TestMutualTLS(t *testing.T) {
+	clientCert, certPath, keyPath := makeClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	srv := httptest.NewUnstartedServer(checkConnectionHandler)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+	caPath := writePEM(t, "CERTIFICATE", srv.Certificate().Raw)
+
+	useTransport(t, common.TransportOptions{CACertPath: caPath})
+	_, err := network.TestConnection()
+	require.ErrorIs(t, err, network.ErrTLSHandshakeFailed)
+
+	useTransport(t, common.TransportOptions{CACertPath: caPath, ClientCertPath: certPath, ClientKeyPath: keyPath})
+	_, err = network.TestConnection()
+	require.NoError(t, err)
+}
+
+func TestHTTPProxy(t *testing.T) {
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		checkConnectionHandler(w, r)
+	}))
+	defer proxy.Close()
+	useTransport(t, common.TransportOptions{ProxyURL: proxy.URL})
+	network.SetBaseURL("http://ashirt.invalid")
+
+	_, err := network.TestConnection()
+
+	require.NoError(t, err)
+	require.Equal(t, "http://ashirt.invalid/api/checkconnection", proxiedURL)
+}
+
+func TestInvalidTransportOptions(t *testing.T) {
+	_, err := network.NewTransport(common.TransportOptions{ProxyURL: "ftp://proxy.example.com"})
+	require.Error(t, err)
+
+	_, err = network.NewTransport(common.TransportOptions{ClientCertPath: "/only/a/cert.pem"})
+	require.Error(t, err)
+
+	_, err = network.NewTransport(common.TransportOptions{CACertPath: filepath.Join(t.TempDir(), "missing.pem")})
+	require.Error(t, err)
+
+	_, err = network.NewTransport(common.TransportOptions{ProxyURL: "socks5://127.0.0.1:1080"})
+	require.NoError(t, err)
+}
@@ -1,7 +1,7 @@
 package network
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -25,13 +25,20 @@ const (
 )
 
 // UploadInput provides a manifest for outgoing evidence.
+//
+// Content must be seekable, as the content is read twice: once to sign the request, and once more
+// to actually send it. Neither pass holds the full content in memory. Content may be nil for
+// evidence without any content (i.e. ContentTypeNone), in which case no file is sent.
+// OnProgress, if provided, is called as the content is sent with the number of content bytes
+// sent so far, and the total content size.
 type UploadInput struct {
 	OperationSlug string
 	Description   string
 	ContentType   string
 	Filename      string
 	TagIDs        []int64
-	Content       io.Reader
+	Content       io.ReadSeeker
+	OnProgress    func(sent, total int64)
 }
 
 const ErrCouldNotInitMsg = "Unable to initialize Request"
@@ -39,67 +46,185 @@ const ErrCouldNotInitMsg = "Unable to initialize Request"
 // UploadToAshirt uploads a terminal recording to the AShirt service. The remote service must
 // be configured by calling network.SetBaseURL(string) before uploading.
 func UploadToAshirt(ui UploadInput) (*dtos.Evidence, error) {
+	return UploadToAshirtWithContext(context.Background(), ui)
+}
+
+// UploadToAshirtWithContext is identical to UploadToAshirt, but the upload can be cancelled via
+// the provided context. When cancelled, the returned error will match context.Canceled
+// (via errors.Is)
+//
+// Uploads are never retried. The configured upload timeout (see SetUploadTimeout) is applied here.
+func UploadToAshirtWithContext(ctx context.Context, ui UploadInput) (*dtos.Evidence, error) {
 	url := apiURL + "/operations/" + ui.OperationSlug + "/evidence"
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	if uploadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, uploadTimeout)
+		defer cancel()
+	}
+
 	jsonTags, _ := json.Marshal(ui.TagIDs)
-	fields := map[string]string{
-		"notes":       ui.Description,
-		"contentType": ui.ContentType,
-		"tagIds":      string(jsonTags),
+	resp, err := sendMultipartForm(ctx, "POST", url, multipartForm{
+		fields: [][2]string{
+			{"notes", ui.Description},
+			{"contentType", ui.ContentType},
+			{"tagIds", string(jsonTags)},
+		},
+		fileField:  "file",
+		filename:   ui.Filename,
+		content:    ui.Content,
+		onProgress: ui.OnProgress,
+	})
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range fields {
-		err := writer.WriteField(k, v)
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		raw, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "Server did not accept request: Unable to read error response")
+		}
+		var parsed map[string]string
+		err = json.Unmarshal(raw, &parsed)
 		if err != nil {
 			return nil, err
 		}
+		reason, ok := parsed["error"]
+		if !ok {
+			reason = "(unknown server error)"
+		}
+		return nil, fmt.Errorf("Unable to upload file: %s", reason)
 	}
+	var evi dtos.Evidence
+	return &evi, errors.MaybeWrap(readResponseBody(&evi, resp.Body), "Upload success, but unable to parse response")
+}
 
-	part, err := writer.CreateFormFile("file", ui.Filename)
-	if err != nil {
-		return nil, errors.Wrap(err, ErrCouldNotInitMsg)
+// multipartForm is a form to send with sendMultipartForm. Fields are written in a fixed order,
+// so that every pass produces an identical body. A nil content sends no file.
+type multipartForm struct {
+	fields     [][2]string
+	fileField  string
+	filename   string
+	content    io.ReadSeeker
+	onProgress func(sent, total int64)
+}
+
+// sendMultipartForm sends the form as a multipart/form-data request. As with uploads, the content
+// is read twice (once to sign the request, and once to send it), and is streamed rather than held
+// in memory. Requests are never retried.
+func sendMultipartForm(ctx context.Context, method, url string, form multipartForm) (*http.Response, error) {
+	var total int64
+	if form.content != nil {
+		var err error
+		if total, err = form.content.Seek(0, io.SeekEnd); err != nil {
+			return nil, errors.Wrap(err, ErrCouldNotInitMsg)
+		}
 	}
-	_, err = io.Copy(part, ui.Content)
-	if err != nil {
-		return nil, errors.Wrap(err, "Could not copy content")
+
+	// the boundary must be identical between the signing pass and the sending pass
+	boundary := multipart.NewWriter(ioutil.Discard).Boundary()
+	newBody := func(onProgress func(int64, int64)) (io.ReadCloser, error) {
+		if form.content != nil {
+			if _, err := form.content.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		return streamMultipartBody(ctx, form, boundary, total, onProgress), nil
 	}
-	err = writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrCouldNotInitMsg)
 	}
-
-	req, err := http.NewRequest("POST", url, body)
+	req.GetBody = func() (io.ReadCloser, error) { return newBody(nil) }
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	err = addAuthentication(req)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrCouldNotInitMsg)
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	err = addAuthentication(req)
+	// signing reads the entire body, which may take a while -- check if we were cancelled in the
+	// meantime
+	if err = ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "Upload cancelled")
+	}
+	req.ContentLength = -1 // unknown; sent chunked
+	req.Body, err = newBody(form.onProgress)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrCouldNotInitMsg)
 	}
 
 	resp, err := client.Do(req)
+	return resp, errors.MaybeWrap(err, "Unable to send request")
+}
 
-	if err != nil {
-		return nil, errors.Wrap(err, "Unable to send request")
-	}
-	if resp.StatusCode != 201 {
-		defer resp.Body.Close()
-		raw, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, errors.Wrap(err, "Server did not accept request: Unable to read error response")
-		}
-		var parsed map[string]string
-		err = json.Unmarshal(raw, &parsed)
+// streamMultipartBody encodes the provided form, and returns a reader for the encoded form.
+// Encoding happens in a separate goroutine as the reader is consumed, so only a small portion of
+// the content is ever held in memory. Any encoding error is returned from the reader's Read
+// method. Encoding stops early if the provided context is cancelled.
+func streamMultipartBody(ctx context.Context, form multipartForm, boundary string, total int64, onProgress func(int64, int64)) io.ReadCloser {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	writer.SetBoundary(boundary)
+
+	go func() {
+		pw.CloseWithError(writeMultipartBody(ctx, writer, form, total, onProgress))
+	}()
+
+	return pr
+}
+
+func writeMultipartBody(ctx context.Context, writer *multipart.Writer, form multipartForm, total int64, onProgress func(int64, int64)) error {
+	for _, field := range form.fields {
+		err := writer.WriteField(field[0], field[1])
 		if err != nil {
-			return nil, err
+			return err
 		}
-		reason, ok := parsed["error"]
-		if !ok {
-			reason = "(unknown server error)"
-		}
-		return nil, fmt.Errorf("Unable to upload file: %s", reason)
 	}
-	var evi dtos.Evidence
-	return &evi, errors.MaybeWrap(readResponseBody(&evi, resp.Body), "Upload success, but unable to parse response")
+
+	if form.content == nil {
+		return errors.MaybeWrap(writer.Close(), ErrCouldNotInitMsg)
+	}
+	part, err := writer.CreateFormFile(form.fileField, form.filename)
+	if err != nil {
+		return errors.Wrap(err, ErrCouldNotInitMsg)
+	}
+	var content io.Reader = &contextReader{ctx: ctx, source: form.content}
+	if onProgress != nil {
+		onProgress(0, total)
+		content = &progressReader{source: content, total: total, onProgress: onProgress}
+	}
+	_, err = io.Copy(part, content)
+	if err != nil {
+		return errors.Wrap(err, "Could not copy content")
+	}
+	return errors.MaybeWrap(writer.Close(), ErrCouldNotInitMsg)
+}
+
+// progressReader wraps an io.Reader, reporting the running total of bytes read after each read
+type progressReader struct {
+	source     io.Reader
+	sent       int64
+	total      int64
+	onProgress func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.source.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.onProgress(p.sent, p.total)
+	}
+	return n, err
+}
+
+// contextReader wraps an io.Reader, failing any read once the provided context is done
+type contextReader struct {
+	ctx    context.Context
+	source io.Reader
+}
+
+func (c *contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.source.Read(b)
 }
@@ -2,10 +2,23 @@ package network_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/ashirt-server/signer"
 	"github.com/theparanoids/aterm/network"
 )
 
@@ -68,3 +81,141 @@ func TestUploadFailedWithUnknownJSON(t *testing.T) {
 	_, err := network.UploadToAshirt(uploadInput)
 	require.Error(t, err)
 }
+
+func TestUploadStreamsLargeFile(t *testing.T) {
+	const contentSize = 64 * 1024 * 1024
+	secret := []byte("shhhh")
+
+	// build a large, non-repeating file on disk, so that the content is never held in memory here
+	f, err := ioutil.TempFile(t.TempDir(), "large_*.cast")
+	require.NoError(t, err)
+	defer f.Close()
+	expectedHash := sha256.New()
+	_, err = io.CopyN(io.MultiWriter(f, expectedHash), rand.New(rand.NewSource(1)), contentSize)
+	require.NoError(t, err)
+
+	var receivedSize int64
+	var receivedHash []byte
+	var receivedFields = map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// tee the body to disk so that the signature can be checked after parsing the form
+		bodyCopy, err := ioutil.TempFile(t.TempDir(), "body_*")
+		require.NoError(t, err)
+		defer bodyCopy.Close()
+
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		reader := multipart.NewReader(io.TeeReader(r.Body, bodyCopy), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			if part.FormName() == "file" {
+				h := sha256.New()
+				receivedSize, err = io.Copy(h, part)
+				require.NoError(t, err)
+				receivedHash = h.Sum(nil)
+			} else {
+				val, _ := ioutil.ReadAll(part)
+				receivedFields[part.FormName()] = string(val)
+			}
+		}
+
+		bodyCopy.Seek(0, io.SeekStart)
+		mac := signer.BuildRequestHMAC(r, bodyCopy, secret)
+		if r.Header.Get("Authorization") != "access:"+base64.StdEncoding.EncodeToString(mac) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "bad signature"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uuid": "abc-123", "description": "big", "occurredAt": "` + Now() + `"}`))
+	}))
+	defer srv.Close()
+
+	network.SetBaseURL(srv.URL)
+	network.SetAccessKey("access")
+	require.NoError(t, network.SetSecretKey(base64.StdEncoding.EncodeToString(secret)))
+
+	var lastSent, lastTotal int64
+	evi, err := network.UploadToAshirt(network.UploadInput{
+		OperationSlug: "large",
+		Description:   "big",
+		ContentType:   network.ContentTypeTerminalRecording,
+		Filename:      "large.cast",
+		TagIDs:        []int64{1, 2},
+		Content:       f,
+		OnProgress: func(sent, total int64) {
+			require.GreaterOrEqual(t, sent, lastSent)
+			lastSent, lastTotal = sent, total
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "abc-123", evi.UUID)
+	require.Equal(t, int64(contentSize), receivedSize)
+	require.Equal(t, expectedHash.Sum(nil), receivedHash)
+	require.Equal(t, "big", receivedFields["notes"])
+	require.Equal(t, network.ContentTypeTerminalRecording, receivedFields["contentType"])
+	require.Equal(t, "[1,2]", receivedFields["tagIds"])
+	require.Equal(t, int64(contentSize), lastSent)
+	require.Equal(t, int64(contentSize), lastTotal)
+}
+
+func TestUploadCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uuid": "abc-123"}`))
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	content := bytes.NewReader(make([]byte, 8*1024*1024))
+	_, err := network.UploadToAshirtWithContext(ctx, network.UploadInput{
+		OperationSlug: "cancelled",
+		ContentType:   network.ContentTypeTerminalRecording,
+		Filename:      "cancelled.cast",
+		Content:       content,
+		OnProgress: func(sent, total int64) {
+			if sent > total/2 {
+				cancel()
+			}
+		},
+	})
+
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestUploadWithoutContent(t *testing.T) {
+	var receivedFiles int
+	var receivedFields url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		receivedFiles = len(r.MultipartForm.File)
+		receivedFields = r.MultipartForm.Value
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uuid": "abc-123"}`))
+	}))
+	defer srv.Close()
+	network.SetBaseURL(srv.URL)
+
+	evi, err := network.UploadToAshirt(network.UploadInput{
+		OperationSlug: "note",
+		Description:   "just a note",
+		ContentType:   network.ContentTypeNone,
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "abc-123", evi.UUID)
+	require.Equal(t, 0, receivedFiles)
+	require.Equal(t, "just a note", receivedFields.Get("notes"))
+	require.Equal(t, network.ContentTypeNone, receivedFields.Get("contentType"))
+}
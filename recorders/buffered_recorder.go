@@ -16,21 +16,30 @@ import (
 // recommended for use.
 type BufferedRecorder struct {
 	startTime time.Time
+	clock     clockwork.Clock
 	events    []common.Event
 	metadata  formatters.Metadata
 }
 
 // NewBufferedRecorder is a constructor for a BufferedRecorder
 func NewBufferedRecorder(clock clockwork.Clock, shell string) BufferedRecorder {
+	return NewBufferedRecorderWithMetadata(clock, formatters.Metadata{
+		Term:  os.Getenv("TERM"),
+		Shell: shell,
+	})
+}
+
+// NewBufferedRecorderWithMetadata is identical to NewBufferedRecorder, but allows the caller to
+// provide all of the metadata for the recording (see NewStreamingRecorderWithMetadata). The start
+// time is always set to now.
+func NewBufferedRecorderWithMetadata(clock clockwork.Clock, metadata formatters.Metadata) BufferedRecorder {
 	now := clock.Now()
+	metadata.StartTimeUnix = now.Unix()
 	return BufferedRecorder{
 		startTime: now,
+		clock:     clock,
 		events:    make([]common.Event, 0),
-		metadata: formatters.Metadata{
-			StartTimeUnix: now.Unix(),
-			Term:          os.Getenv("TERM"),
-			Shell:         shell,
-		},
+		metadata:  metadata,
 	}
 }
 
@@ -43,18 +52,21 @@ func (r *BufferedRecorder) AddEvent(eType common.EventType, data string, evtTime
 	})
 }
 
+// UpdateMetadata changes the metadata provided to the header and footer (see
+// StreamingRecorder.UpdateMetadata)
+func (r *BufferedRecorder) UpdateMetadata(update func(*formatters.Metadata)) {
+	update(&r.metadata)
+}
+
 // GetEventCount returns the number of encoutnered events in this stream
 func (r *BufferedRecorder) GetEventCount() int {
 	return len(r.events)
 }
 
-// GetDurationInSeconds returns the difference between the very first action and the very last action.
-// If not events have been recorded yet, returns 0
+// GetDurationInSeconds returns the elapsed time from the start of the recording, including any
+// time after the last event (as with StreamingRecorder)
 func (r *BufferedRecorder) GetDurationInSeconds() float64 {
-	if len(r.events) == 0 {
-		return 0
-	}
-	return (r.events[len(r.events)-1]).When.Seconds()
+	return r.clock.Now().Sub(r.startTime).Seconds()
 }
 
 // GetStartTime returns the start of the recording, in unix time
@@ -62,7 +74,8 @@ func (r *BufferedRecorder) GetStartTime() int64 {
 	return r.startTime.Unix()
 }
 
-// Output writes the recorded events to the provided TerminalWriter
+// Output writes the recorded events to the provided TerminalWriter. The recording's duration runs
+// until Output is called.
 func (r *BufferedRecorder) Output(dst write.TerminalWriter) {
 	r.metadata.DurationSeconds = r.GetDurationInSeconds()
 	dst.WriteHeader(r.metadata)
@@ -29,15 +29,22 @@ type StreamingRecorder struct {
 // Note: start time is set to now. Unfortunately, this cannot be made lazy, due to a shell prompt
 // coming up immediately
 func NewStreamingRecorder(writer write.TerminalWriter, clock clockwork.Clock, shell string) StreamingRecorder {
+	return NewStreamingRecorderWithMetadata(writer, clock, formatters.Metadata{
+		Term:  os.Getenv("TERM"),
+		Shell: shell,
+	})
+}
+
+// NewStreamingRecorderWithMetadata is identical to NewStreamingRecorder, but allows the caller to
+// provide all of the metadata for the recording (e.g. the command being recorded, or extra
+// environment details). The start time is always set to now.
+func NewStreamingRecorderWithMetadata(writer write.TerminalWriter, clock clockwork.Clock, metadata formatters.Metadata) StreamingRecorder {
+	metadata.StartTimeUnix = clock.Now().Unix()
 	rtn := StreamingRecorder{
 		startTime: clock.Now(),
 		clock:     clock,
 		writer:    writer,
-		metadata: formatters.Metadata{
-			StartTimeUnix: clock.Now().Unix(),
-			Term:          os.Getenv("TERM"),
-			Shell:         shell,
-		},
+		metadata:  metadata,
 	}
 
 	rtn.writer.WriteHeader(rtn.metadata)
@@ -54,6 +61,13 @@ func (r *StreamingRecorder) AddEvent(eType common.EventType, data string, evtTim
 	})
 }
 
+// UpdateMetadata changes the metadata provided to the footer (and so, for writers that finalize
+// their header, the header as well). This is intended for details only known once recording ends,
+// e.g. how the recorded process exited.
+func (r *StreamingRecorder) UpdateMetadata(update func(*formatters.Metadata)) {
+	update(&r.metadata)
+}
+
 // GetEventCount returns -1, as we don't know how many events we have, or will, process.
 func (r *StreamingRecorder) GetEventCount() int {
 	return -1
@@ -69,7 +83,10 @@ func (r *StreamingRecorder) GetStartTime() int64 {
 	return r.startTime.Unix()
 }
 
-// Output writes the footer to the StreamingRecorder's TerminalWriter (and ignores the passed parameter)
+// Output writes the footer to the StreamingRecorder's TerminalWriter (and ignores the passed
+// parameter). The footer's metadata includes the recording's duration, i.e. the time from the
+// start of the stream until now, which includes any time after the last event.
 func (r *StreamingRecorder) Output(_ write.TerminalWriter) {
+	r.metadata.DurationSeconds = r.GetDurationInSeconds()
 	r.writer.WriteFooter(r.metadata)
 }
@@ -1,6 +1,7 @@
 package recorders
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
@@ -33,6 +34,25 @@ func TestStreamingRecorderConstructor(t *testing.T) {
 	assert.Equal(t, *writer.HeaderMetadata, expectedMetadata)
 }
 
+func TestStreamingRecorderWithMetadataConstructor(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	writer := write.NewSaveTermWrier()
+	rec := NewStreamingRecorderWithMetadata(writer, clock, formatters.Metadata{
+		Shell:   "someShell",
+		Command: "ls -la",
+		Env:     map[string]string{"LANG": "C"},
+	})
+
+	expectedMetadata := formatters.Metadata{
+		StartTimeUnix: clock.Now().Unix(),
+		Shell:         "someShell",
+		Command:       "ls -la",
+		Env:           map[string]string{"LANG": "C"},
+	}
+	assert.Equal(t, rec.metadata, expectedMetadata)
+	assert.Equal(t, *writer.HeaderMetadata, expectedMetadata)
+}
+
 func TestStreamingRecorderAddEvent(t *testing.T) {
 	rec, write, clock := makeStreamingRecorder()
 
@@ -92,3 +112,58 @@ func TestStreamingRecorderOutput(t *testing.T) {
 	rec.Output(write.NilTermWriter{})
 	assert.Equal(t, *writer.FooterMetadata, expectedMetadata)
 }
+
+func TestStreamingRecorderOutputDuration(t *testing.T) {
+	rec, writer, clock := makeStreamingRecorder()
+
+	clock.Advance(2 * time.Second)
+	rec.AddEvent("o", "someData", clock.Now())
+	clock.Advance(3 * time.Second) // e.g. waiting at a prompt
+
+	rec.Output(write.NilTermWriter{})
+	assert.Equal(t, float64(5), writer.FooterMetadata.DurationSeconds)
+}
+
+func TestStreamedAndBufferedRecordingsMatch(t *testing.T) {
+	dir := t.TempDir()
+	metadata := formatters.Metadata{Shell: "someShell", Command: "ls -la", Env: map[string]string{"LANG": "C"}}
+	exitCode := 2
+	events := []common.Event{
+		{Type: "o", Data: "$ ", When: 500 * time.Millisecond},
+		{Type: "o", Data: "done\r\n", When: 2 * time.Second},
+	}
+	record := func(name string, buffered bool) string {
+		clock := clockwork.NewFakeClock()
+		writer, err := write.NewStreamingFileWriter(dir, name, formatters.ASCIICast, true)
+		assert.NoError(t, err)
+
+		var rec interface {
+			Recorder
+			UpdateMetadata(func(*formatters.Metadata))
+		}
+		if buffered {
+			bufferedRec := NewBufferedRecorderWithMetadata(clock, metadata)
+			rec = &bufferedRec
+		} else {
+			streamingRec := NewStreamingRecorderWithMetadata(writer, clock, metadata)
+			rec = &streamingRec
+		}
+		start := clock.Now()
+		for _, evt := range events {
+			clock.Advance(start.Add(evt.When).Sub(clock.Now()))
+			rec.AddEvent(evt.Type, evt.Data, clock.Now())
+		}
+		clock.Advance(time.Second) // the duration includes any time after the last event
+		rec.UpdateMetadata(func(m *formatters.Metadata) { m.ExitCode = &exitCode })
+		rec.Output(writer)
+		assert.NoError(t, writer.Close())
+
+		content, err := ioutil.ReadFile(writer.Filepath())
+		assert.NoError(t, err)
+		return string(content)
+	}
+
+	streamed := record("streamed.cast", false)
+	assert.Equal(t, record("buffered.cast", true), streamed)
+	assert.Contains(t, streamed, `"duration":3,"command":"ls -la","exit_code":2`)
+}
@@ -0,0 +1,40 @@
+package write
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashChain links lines into a chain of SHA-256 hashes, so that changing, removing or adding any
+// line changes every following link. The first link is the hash of the first line, and each
+// following link is the hash of the previous link followed by the line. Lines include their
+// trailing newline. The zero value is an empty chain.
+type HashChain struct {
+	links [][]byte
+}
+
+// Add links the line into the chain, and returns the new link
+func (c *HashChain) Add(line []byte) []byte {
+	hash := sha256.New()
+	if len(c.links) > 0 {
+		hash.Write(c.links[len(c.links)-1])
+	}
+	hash.Write(line)
+	link := hash.Sum(nil)
+	c.links = append(c.links, link)
+	return link
+}
+
+// Len returns the number of links in the chain
+func (c *HashChain) Len() int {
+	return len(c.links)
+}
+
+// HexLinks returns the hex encoded links of the chain, in order
+func (c *HashChain) HexLinks() []string {
+	links := make([]string, len(c.links))
+	for i, link := range c.links {
+		links[i] = hex.EncodeToString(link)
+	}
+	return links
+}
@@ -0,0 +1,83 @@
+package write
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/theparanoids/aterm/errors"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+// UpdateASCIICastHeader rewrites the header (i.e. the first line) of the asciicast file at the
+// provided path, after applying the provided changes. Events are copied as-is, and the recording
+// is replaced as a whole (see replaceHeader).
+func UpdateASCIICastHeader(path string, update func(*formatters.ASCIICastHeader)) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	reader := bufio.NewReader(src)
+	headerLine, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	src.Close() // the recording is reopened when replaced
+	var header formatters.ASCIICastHeader
+	if err := json.Unmarshal(headerLine, &header); err != nil {
+		return errors.Wrap(err, "Unable to parse recording header")
+	}
+	update(&header)
+	encoded, err := formatters.AddNewline(json.Marshal(header))
+	if err != nil {
+		return err
+	}
+	return replaceHeader(path, int64(len(headerLine)), encoded)
+}
+
+// replaceHeader rewrites the file at the provided path, with the provided header in place of the
+// first headerLength bytes. The new content is written to a temporary file, which then replaces
+// the original, so that the file is never left partially written.
+func replaceHeader(path string, headerLength int64, header []byte) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := src.Seek(headerLength, io.SeekStart); err != nil {
+		return err
+	}
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(header)
+	if err == nil {
+		_, err = io.Copy(tmp, src)
+	}
+	if err == nil {
+		err = tmp.Chmod(info.Mode())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "Unable to rewrite recording")
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,42 @@
+package write
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theparanoids/aterm/formatters"
+)
+
+func TestUpdateASCIICastHeader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.cast")
+	events := "[0.5,\"o\",\"hello\"]\n[1.5,\"o\",\"world\"]\n"
+	original := "{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":100,\"title\":\"t\",\"env\":{\"SHELL\":\"/bin/sh\"}}\n" + events
+	require.NoError(t, ioutil.WriteFile(path, []byte(original), 0600))
+
+	err := UpdateASCIICastHeader(path, func(h *formatters.ASCIICastHeader) {
+		exitCode := 3
+		h.Duration = 1.5
+		h.ExitCode = &exitCode
+	})
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	expected := "{\"version\":2,\"width\":80,\"height\":24,\"timestamp\":100,\"duration\":1.5,\"exit_code\":3,\"title\":\"t\",\"env\":{\"SHELL\":\"/bin/sh\"}}\n" + events
+	require.Equal(t, expected, string(content))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file should be removed")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a recording\n"), 0600))
+	require.Error(t, UpdateASCIICastHeader(path, func(h *formatters.ASCIICastHeader) {}))
+}
@@ -6,16 +6,33 @@ import (
 
 	"github.com/theparanoids/aterm/common"
 	"github.com/theparanoids/aterm/formatters"
+	"github.com/theparanoids/aterm/systemstate"
 )
 
 // StreamingFileWriter is the preferred TerminalWriter that writes to a file as soon as
 // an event/header/footer comes in. The file is kept open until Close is called.
 //
+// As the header is written before the recording's final details (e.g. its duration) are known,
+// the header is written again, with the metadata provided to WriteFooter, when the file is closed.
+// This leaves the file as it would be had the whole recording been written at once.
+//
 // Note: This currently ignores any write errors.
 type StreamingFileWriter struct {
 	outStream   io.Writer
 	backingFile FileLike
 	formatter   formatters.Formatter
+	state       *streamState
+}
+
+// streamState notes what has been written, so that the header can be replaced once the recording
+// ends, and so that lines can be linked into a hash chain as they are written
+type streamState struct {
+	header []byte
+	// width and height note the terminal's size when the header was first written, which the
+	// replacement header keeps
+	width, height uint16
+	final         *formatters.Metadata
+	chain         *HashChain
 }
 
 // NewStreamingFileWriter generates a new StreamingFileWriter, which can be used as a TerminalWriter
@@ -32,6 +49,7 @@ func NewStreamingFileWriter(filedir, filename string, formatter formatters.Forma
 		formatter:   formatter,
 		backingFile: file,
 		outStream:   writer,
+		state:       &streamState{},
 	}, err
 }
 
@@ -42,14 +60,26 @@ func (fw StreamingFileWriter) WriteHeader(m formatters.Metadata) {
 	if len(encoded) > 0 {
 		noErrorWrite(encoded, err, fw.outStream.Write)
 	}
+	if fw.state != nil && err == nil {
+		fw.state.header = encoded
+		fw.state.width, fw.state.height = m.Width, m.Height
+		if m.Width == 0 && m.Height == 0 {
+			fw.state.width, fw.state.height = systemstate.TermWidth(), systemstate.TermHeight()
+		}
+	}
 }
 
 // WriteFooter attempts to write a footer (per the provided formatter) directly to the backing file.
-// Note that since this is streamed, this must be called after header and all events (i.e. last)
+// Note that since this is streamed, this must be called after header and all events (i.e. last).
+// The provided metadata replaces the header's metadata when the file is closed.
 func (fw StreamingFileWriter) WriteFooter(m formatters.Metadata) {
 	encoded, err := fw.formatter.WriteFooter(m)
 	if len(encoded) > 0 {
 		noErrorWrite(encoded, err, fw.outStream.Write)
+		fw.chain(encoded, err)
+	}
+	if fw.state != nil {
+		fw.state.final = &m
 	}
 }
 
@@ -57,15 +87,59 @@ func (fw StreamingFileWriter) WriteFooter(m formatters.Metadata) {
 func (fw StreamingFileWriter) WriteEvent(evt common.Event) {
 	encoded, err := fw.formatter.WriteEvent(evt)
 	noErrorWrite(encoded, err, fw.outStream.Write)
+	fw.chain(encoded, err)
+}
+
+// ChainLines starts a hash chain of the lines written from now on (i.e. each event, then the
+// footer, if any). As the header is only final once the writer is closed, the header is linked
+// into the chain last, when the writer is closed. The chain is built from what was written, rather
+// than from the file, so later changes to the file do not affect the chain.
+func (fw StreamingFileWriter) ChainLines() *HashChain {
+	fw.state.chain = &HashChain{}
+	return fw.state.chain
+}
+
+func (fw StreamingFileWriter) chain(encoded []byte, err error) {
+	if err == nil && fw.state != nil && fw.state.chain != nil {
+		fw.state.chain.Add(encoded)
+	}
 }
 
 // Close is a required call to both flush the buffered writer (if signaled via the constructor/hand created)
-// and to close the file itself.
+// and to close the file itself. If a footer was written, the header is then rewritten with the
+// footer's metadata (see replaceHeader), and finally linked into the hash chain (see ChainLines).
 func (fw StreamingFileWriter) Close() error {
 	if w, ok := fw.outStream.(*bufio.Writer); ok {
 		w.Flush()
 	}
-	return fw.backingFile.Close()
+	if err := fw.backingFile.Close(); err != nil {
+		return err
+	}
+	if err := fw.finalizeHeader(); err != nil {
+		return err
+	}
+	if fw.state != nil && fw.state.chain != nil {
+		fw.state.chain.Add(fw.state.header)
+		fw.state.chain = nil // the chain is complete
+	}
+	return nil
+}
+
+// finalizeHeader rewrites the header with the final metadata, if a footer was written. The
+// terminal's size is kept from the original header, as the terminal may have been resized since.
+func (fw StreamingFileWriter) finalizeHeader() error {
+	if fw.state == nil || fw.state.final == nil {
+		return nil
+	}
+	final := *fw.state.final
+	final.Width, final.Height = fw.state.width, fw.state.height
+	encoded, err := fw.formatter.WriteHeader(final)
+	if err != nil {
+		return err
+	}
+	length := int64(len(fw.state.header))
+	fw.state.header, fw.state.final = encoded, nil // the header is only replaced once
+	return replaceHeader(fw.Filepath(), length, encoded)
 }
 
 // Filepath retrieves the path to the streamed file.
@@ -3,12 +3,17 @@ package write
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/theparanoids/aterm/common"
 	"github.com/theparanoids/aterm/formatters"
+	"github.com/theparanoids/aterm/systemstate"
 )
 
 func makeTestStreamingFileWriter() (StreamingFileWriter, *bytes.Buffer) {
@@ -89,6 +94,77 @@ func TestStreamingFileWriterCloseBuffered(t *testing.T) {
 	assert.Equal(t, buf.Len(), len([]byte(sampleMetadata.String())), "Check that BufferedWriter has been flushed on close")
 }
 
+func TestStreamingFileWriterFinalizesHeader(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewStreamingFileWriter(dir, "test.cast", formatters.ASCIICast, true)
+	require.NoError(t, err)
+
+	exitCode := 1
+	m := formatters.Metadata{StartTimeUnix: 100, Title: "scan"}
+	writer.WriteHeader(m)
+	writer.WriteEvent(common.Event{Type: "o", Data: "hello", When: 1500 * time.Millisecond})
+	m.DurationSeconds, m.ExitCode = 1.5, &exitCode
+	writer.WriteFooter(m)
+	require.NoError(t, writer.Close())
+
+	content, err := ioutil.ReadFile(writer.Filepath())
+	require.NoError(t, err)
+	lines := strings.Split(string(content), "\n")
+	require.Len(t, lines, 3)
+	var header formatters.ASCIICastHeader
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	require.Equal(t, 1.5, header.Duration)
+	require.Equal(t, &exitCode, header.ExitCode)
+	require.Equal(t, "scan", header.Title)
+	require.Equal(t, `[1.5,"o","hello"]`, lines[1])
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file should be removed")
+}
+
+func TestStreamingFileWriterFinalizedHeaderKeepsSize(t *testing.T) {
+	writer, err := NewStreamingFileWriter(t.TempDir(), "test.cast", formatters.ASCIICast, true)
+	require.NoError(t, err)
+
+	systemstate.UpdateTermWidth(80)
+	systemstate.UpdateTermHeight(24)
+	m := formatters.Metadata{StartTimeUnix: 100}
+	writer.WriteHeader(m)
+	systemstate.UpdateTermWidth(120) // resized during the recording
+	systemstate.UpdateTermHeight(40)
+	m.DurationSeconds = 1
+	writer.WriteFooter(m)
+	require.NoError(t, writer.Close())
+
+	content, err := ioutil.ReadFile(writer.Filepath())
+	require.NoError(t, err)
+	var header formatters.ASCIICastHeader
+	require.NoError(t, json.Unmarshal([]byte(strings.Split(string(content), "\n")[0]), &header))
+	require.Equal(t, uint16(80), header.Width)
+	require.Equal(t, uint16(24), header.Height)
+	require.Equal(t, float64(1), header.Duration)
+}
+
+func TestStreamingFileWriterChainLines(t *testing.T) {
+	writer, err := NewStreamingFileWriter(t.TempDir(), "test.cast", formatters.ASCIICast, true)
+	require.NoError(t, err)
+	chain := writer.ChainLines()
+	writer.WriteHeader(formatters.Metadata{StartTimeUnix: 100})
+	writer.WriteEvent(common.Event{Type: "o", Data: "hello", When: time.Second})
+	writer.WriteFooter(formatters.Metadata{StartTimeUnix: 100, DurationSeconds: 1})
+	require.Equal(t, 1, chain.Len(), "the header should not be linked until closed")
+	require.NoError(t, writer.Close())
+
+	content, err := ioutil.ReadFile(writer.Filepath())
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(content), "\n")
+	var expected HashChain
+	expected.Add([]byte(lines[1]))
+	expected.Add([]byte(lines[0]))
+	require.Equal(t, expected.HexLinks(), chain.HexLinks())
+}
+
 type DummyFile struct {
 	DummyPath string
 	IsClosed  bool